@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/supaleon/vanilla/internal/ast"
+	"github.com/supaleon/vanilla/internal/checker"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+func (s *server) completion(params *TextDocumentPositionParams) (*CompletionList, error) {
+	p, u, off, err := s.at(params)
+	if err != nil || u == nil {
+		return &CompletionList{Items: []CompletionItem{}}, err
+	}
+	return &CompletionList{Items: p.last.complete(u, off)}, nil
+}
+
+// complete returns the completions of the word ending at offset off of the
+// template of u:
+//
+//   - the fields of the Go struct after `user.` in code blocks and attribute
+//     expressions;
+//   - the props and loop variables in scope elsewhere in code blocks;
+//   - the imported components after `<`;
+//   - the props of the component in the start tag of a component element.
+func (a *analysis) complete(u *unit, off int) []CompletionItem {
+	text := u.doc.text
+	start := off
+	for start > 0 {
+		r, size := utf8.DecodeLastRune(text[:start])
+		if !isWordRune(r) {
+			break
+		}
+		start -= size
+	}
+	c := &completer{u: u, replace: Range{u.doc.position(start), u.doc.position(off)}, items: []CompletionItem{}}
+
+	loc := u.loc(off)
+	if m := u.component.ESModule; m != nil && loc < m.Range().End {
+		return c.items // the script is left to the editor
+	}
+	if start > 0 && text[start-1] == '<' {
+		for _, imp := range a.graph.Imports(u.path) {
+			if imp.Target != nil {
+				c.add(imp.Name, CompletionClass, imp.Target.Path, "")
+			}
+		}
+		return c.items
+	}
+
+	path := enclosing(u, loc)
+	for i := len(path) - 1; i >= 0; i-- {
+		switch n := path[i].(type) {
+		case *ast.Ident:
+			if sel, ok := path[i-1].(*ast.Selector); ok && sel.Sel == n {
+				c.fields(a, u.info.TypeOf(sel.X))
+				return c.items
+			}
+			if b, ok := path[i-1].(*ast.ForBlock); ok && (b.Key == n || b.Value == n) {
+				return c.items // a new name
+			}
+			c.names(path, loc)
+			return c.items
+		case *ast.BadExpr, *ast.Interpolation, *ast.ConditionalText, *ast.FormatSpec, *ast.IfBlock, *ast.ForBlock:
+			c.names(path, loc)
+			return c.items
+		case *ast.Attribute:
+			if loc <= n.NameLoc+token.Loc(len(n.Name)) {
+				c.props(a, path[i-1].(*ast.Element), n)
+			}
+			return c.items
+		case *ast.Element:
+			if loc > tagName(n).End && loc <= startTagEnd(u, n) {
+				c.props(a, n, nil)
+			}
+			return c.items
+		case *ast.Text, *ast.Comment:
+			return c.items
+		}
+	}
+	return c.items
+}
+
+// isWordRune reports whether r can be part of a name completed.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// startTagEnd returns the location of the `>` ending the start tag of e, or
+// of the end of e if there is none.
+func startTagEnd(u *unit, e *ast.Element) token.Loc {
+	end := tagName(e).End
+	for _, attr := range e.Attrs {
+		end = max(end, attr.End)
+	}
+	if end >= e.End {
+		return e.End
+	}
+	if i := strings.IndexByte(string(u.doc.text[u.offset(end):u.offset(e.End)]), '>'); i >= 0 {
+		return end + token.Loc(i)
+	}
+	return e.End
+}
+
+// completer collects the completion items of a query.
+type completer struct {
+	u       *unit
+	replace Range // of the word completed
+	items   []CompletionItem
+}
+
+func (c *completer) add(label string, kind int, detail, doc string) {
+	for _, item := range c.items {
+		if item.Label == label {
+			return // shadowed
+		}
+	}
+	item := CompletionItem{
+		Label:    label,
+		Kind:     kind,
+		Detail:   detail,
+		SortText: fmt.Sprintf("%04d", len(c.items)),
+		TextEdit: &TextEdit{Range: c.replace, NewText: label},
+	}
+	if doc != "" {
+		item.Documentation = &MarkupContent{Kind: "markdown", Value: strings.TrimSpace(doc)}
+	}
+	c.items = append(c.items, item)
+}
+
+// names adds the loop variables in scope at the location loc of the path of
+// nodes enclosing it, innermost first, followed by the props.
+func (c *completer) names(path []ast.Node, loc token.Loc) {
+	info := c.u.info
+	for i := len(path) - 1; i >= 0; i-- {
+		b, ok := path[i].(*ast.ForBlock)
+		if !ok || loc <= b.X.Range().End {
+			continue // not in the body
+		}
+		for _, id := range []*ast.Ident{b.Key, b.Value} {
+			if id != nil && id.Name != "_" {
+				c.add(id.Name, CompletionVariable, typeString(info.TypeOf(id)), "")
+			}
+		}
+	}
+	if m := c.u.component.ESModule; m != nil {
+		for _, d := range m.Props {
+			c.add(d.Name.Name, CompletionVariable, typeString(info.Props[d.Name.Name]), "")
+		}
+	}
+}
+
+// fields adds the fields of the Go struct type t, named as in templates.
+func (c *completer) fields(a *analysis, t types.Type) {
+	if t == nil {
+		return
+	}
+	for _, f := range checker.Fields(t) {
+		c.add(fieldName(f.Name()), CompletionField, typeString(f.Type()), a.loader.Doc(f))
+	}
+}
+
+// fieldName returns the name of the Go field of the given name in templates:
+// its first letter in lower case, aka `name` for `Name`, unless it starts an
+// initialism, aka `URL`.
+func fieldName(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	if next, _ := utf8.DecodeRuneInString(name[size:]); unicode.IsUpper(next) {
+		return name
+	}
+	return string(unicode.ToLower(r)) + name[size:]
+}
+
+// props adds the props of the component of the element e which are not set
+// by its attributes yet, but the attribute attr being edited.
+func (c *completer) props(a *analysis, e *ast.Element, attr *ast.Attribute) {
+	target := a.component(c.u, e)
+	if target == nil || target.component.ESModule == nil {
+		return
+	}
+	for _, d := range target.component.ESModule.Props {
+		if set := e.Attr(d.Name.Name); set != nil && set != attr {
+			continue
+		}
+		c.add(d.Name.Name, CompletionProperty, typeString(target.info.Props[d.Name.Name]), "")
+	}
+}
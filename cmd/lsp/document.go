@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+// document is the text of a file, whose lines are held by a [token.File] to
+// convert the byte positions of the compiler to the UTF-16 positions of the
+// protocol and back.
+type document struct {
+	uri     string
+	version int
+	text    []byte
+	file    *token.File
+}
+
+// newDocument returns the document of the given text.
+func newDocument(uri string, version int, text []byte) *document {
+	d := &document{uri: uri, version: version, text: text}
+	d.file = token.NewFileSet().AddFile(uri, -1, len(text))
+	d.file.SetLinesForContent(text)
+	return d
+}
+
+// lineBounds returns the offsets of the start and the end, before the line
+// terminator, of the 0-based line of d, clamped to the lines of d.
+func (d *document) lineBounds(line int) (start, end int) {
+	n := d.file.LineCount()
+	if n == 0 || line < 0 {
+		return 0, 0
+	}
+	if line >= n {
+		return len(d.text), len(d.text)
+	}
+	start = d.file.Offset(d.file.LineStart(line + 1))
+	end = len(d.text)
+	if line+1 < n {
+		end = d.file.Offset(d.file.LineStart(line + 2))
+	}
+	if end > start && d.text[end-1] == '\n' {
+		end--
+	}
+	if end > start && d.text[end-1] == '\r' {
+		end--
+	}
+	return start, end
+}
+
+// offset returns the byte offset of the position p of d. A character past the
+// end of its line denotes the end of the line.
+func (d *document) offset(p Position) int {
+	start, end := d.lineBounds(p.Line)
+	off, units := start, 0
+	for off < end && units < p.Character {
+		r, size := utf8.DecodeRune(d.text[off:end])
+		units += utf16Len(r)
+		off += size
+	}
+	return off
+}
+
+// position returns the position of the byte at offset off of d.
+func (d *document) position(off int) Position {
+	off = min(max(off, 0), len(d.text))
+	line := 0
+	switch n := d.file.LineCount(); {
+	case off == len(d.text) && off > 0 && d.text[off-1] == '\n':
+		line = n // the empty last line, which has no entry
+	case n > 0:
+		line = d.file.Position(d.file.Location(off)).Line - 1
+	}
+	start, _ := d.lineBounds(line)
+	units := 0
+	for i := start; i < off; {
+		r, size := utf8.DecodeRune(d.text[i:off])
+		units += utf16Len(r)
+		i += size
+	}
+	return Position{Line: line, Character: units}
+}
+
+// offsetOf returns the byte offset of the compiler position pos of d, whose
+// column counts bytes.
+func (d *document) offsetOf(pos token.Position) int {
+	if pos.Line <= 0 {
+		return 0
+	}
+	start, end := d.lineBounds(pos.Line - 1)
+	return min(start+max(pos.Column-1, 0), end)
+}
+
+// utf16Len returns the number of UTF-16 code units encoding r; an invalid
+// byte counts as one, as its replacement character.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// applyChanges applies the changes of a didChange notification to the text
+// of d, in order, and returns the new document of the given version.
+func (d *document) applyChanges(version int, changes []TextDocumentContentChangeEvent) (*document, error) {
+	cur := d
+	for _, c := range changes {
+		text := []byte(c.Text)
+		if c.Range != nil {
+			start, end := cur.offset(c.Range.Start), cur.offset(c.Range.End)
+			if start > end {
+				return nil, fmt.Errorf("invalid range %v of change", *c.Range)
+			}
+			text = make([]byte, 0, len(cur.text)-(end-start)+len(c.Text))
+			text = append(text, cur.text[:start]...)
+			text = append(text, c.Text...)
+			text = append(text, cur.text[end:]...)
+		}
+		cur = newDocument(d.uri, version, text)
+	}
+	if cur == d {
+		cur = newDocument(d.uri, version, d.text)
+	}
+	return cur, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+func TestDocumentPosition(t *testing.T) {
+	// é is 2 bytes and 1 UTF-16 unit, 😀 4 bytes and 2 units
+	d := newDocument("file:///a.html", 1, []byte("ab\r\né😀x\n\nend\n"))
+	tests := []struct {
+		off int
+		pos Position
+	}{
+		{0, Position{0, 0}},
+		{2, Position{0, 2}},
+		{4, Position{1, 0}},
+		{6, Position{1, 1}},  // after é
+		{10, Position{1, 3}}, // after 😀
+		{11, Position{1, 4}},
+		{12, Position{2, 0}},
+		{13, Position{3, 0}},
+		{17, Position{4, 0}},
+	}
+	for _, test := range tests {
+		if got := d.position(test.off); got != test.pos {
+			t.Errorf("position(%d) = %v, want %v", test.off, got, test.pos)
+		}
+		if got := d.offset(test.pos); got != test.off {
+			t.Errorf("offset(%v) = %d, want %d", test.pos, got, test.off)
+		}
+	}
+	for pos, want := range map[Position]int{
+		{0, 10}: 2,  // past the end of the line, before \r\n
+		{1, 2}:  10, // inside 😀, rounded up
+		{9, 0}:  17, // past the last line
+	} {
+		if got := d.offset(pos); got != want {
+			t.Errorf("offset(%v) = %d, want %d", pos, got, want)
+		}
+	}
+	if got, want := d.offsetOf(token.Position{Line: 2, Column: 7}), 10; got != want {
+		t.Errorf("offsetOf(2:7) = %d, want %d", got, want)
+	}
+}
+
+func TestApplyChanges(t *testing.T) {
+	d := newDocument("file:///a.html", 1, []byte("<p>😀 {a}</p>\n"))
+	d, err := d.applyChanges(2, []TextDocumentContentChangeEvent{
+		{Range: &Range{Position{0, 7}, Position{0, 8}}, Text: "name"},      // {a} -> {name}
+		{Range: &Range{Position{0, 3}, Position{0, 5}}, Text: "é"},         // 😀 -> é
+		{Range: &Range{Position{1, 0}, Position{1, 0}}, Text: "<br>\n"},    // insertion at the end
+		{Range: &Range{Position{0, 0}, Position{0, 0}}, Text: "<div>\n  "}, // insertion at the start
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(d.text), "<div>\n  <p>é {name}</p>\n<br>\n"; got != want || d.version != 2 {
+		t.Errorf("got %q version %d, want %q version 2", got, d.version, want)
+	}
+
+	d, err = d.applyChanges(3, []TextDocumentContentChangeEvent{{Text: "full"}})
+	if err != nil || string(d.text) != "full" {
+		t.Errorf("full change: got %q, %v", d.text, err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// conn reads and writes the JSON-RPC messages of a stream, each preceded by
+// a header holding its Content-Length, as in the base protocol of LSP.
+type conn struct {
+	r  *textproto.Reader
+	mu sync.Mutex // guards w
+	w  io.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{r: textproto.NewReader(bufio.NewReader(r)), w: w}
+}
+
+// read returns the next message of the stream. It returns io.EOF at the end
+// of the stream.
+func (c *conn) read() (*message, error) {
+	header, err := c.r.ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	n, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(c.r.R, body); err != nil {
+		return nil, err
+	}
+	m := new(message)
+	if err := json.Unmarshal(body, m); err != nil {
+		return nil, &responseError{codeParseError, err.Error()}
+	}
+	return m, nil
+}
+
+// write writes the message m.
+func (c *conn) write(m *message) error {
+	m.JSONRPC = "2.0"
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(body)
+	return err
+}
+
+// notify sends the notification of method with params.
+func (c *conn) notify(method string, params any) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&message{Method: method, Params: data})
+}
+
+// reply sends the response to the request of the given id: result, or err if
+// it is not nil, which is sent as is if it is a *responseError or as an
+// internal error otherwise.
+func (c *conn) reply(id json.RawMessage, result any, err error) error {
+	m := &message{ID: id}
+	if err != nil {
+		var re *responseError
+		if !errors.As(err, &re) {
+			re = &responseError{codeInternalError, err.Error()}
+		}
+		m.Error = re
+	} else {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		m.Result = data
+	}
+	return c.write(m)
+}
@@ -1 +1,41 @@
-package lsp
+// Command lsp is the language server of Vanilla components, which the editor
+// plugins run to check the components as they are edited.
+//
+// Usage:
+//
+//	lsp
+//
+// The server speaks the Language Server Protocol over its standard input and
+// output, and logs to its standard error. It publishes the diagnostics of the
+// scanner, the parser, the linker and the type checker for the components of
+// the project of every open document, the directory holding go.mod, whose
+// open documents are checked as edited rather than as saved. Documents are
+// synchronized incrementally; positions are converted between the byte
+// columns of the compiler and the UTF-16 columns of the protocol.
+//
+// From the results of the last check, the server completes the props, the
+// loop variables and the Go struct fields in code blocks, the imported
+// components in tags and their props as attributes; it shows the Go type and
+// the doc comment of a name on hover, and jumps from a component tag to its
+// file and from a field to its declaration in Go.
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+)
+
+func main() {
+	logger := log.New(os.Stderr, "vanilla-lsp: ", log.LstdFlags)
+	s := newServer(os.Stdin, os.Stdout, logger)
+	err := s.run()
+	switch {
+	case errors.Is(err, errExit) && s.shutdown, errors.Is(err, io.EOF):
+		os.Exit(0)
+	case errors.Is(err, errExit):
+		os.Exit(1) // exit without shutdown
+	}
+	logger.Fatal(err)
+}
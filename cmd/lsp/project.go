@@ -0,0 +1,319 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/supaleon/vanilla/internal/ast"
+	"github.com/supaleon/vanilla/internal/checker"
+	"github.com/supaleon/vanilla/internal/codegen"
+	"github.com/supaleon/vanilla/internal/linker"
+	"github.com/supaleon/vanilla/internal/options"
+	"github.com/supaleon/vanilla/internal/parser"
+	"github.com/supaleon/vanilla/internal/router"
+	"github.com/supaleon/vanilla/internal/scanner"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+// project is a Vanilla project: the directory holding go.mod, or the
+// directory of a component outside of any module.
+type project struct {
+	root   string
+	opts   *options.Options
+	optErr error // of the options file; opts are the defaults if set
+	loader *checker.GoLoader
+
+	published map[string]bool // files with diagnostics published
+	last      *analysis       // of the last check, nil before the first one
+}
+
+// analysis holds the results of a check of the components of a project, to
+// answer the queries about the documents checked.
+type analysis struct {
+	fset   *token.FileSet
+	loader *checker.GoLoader // of the Go types of the results
+	graph  *linker.Graph
+	units  map[string]*unit  // by file name
+	names  map[string]string // file names by path in fset
+}
+
+// unit is a component file checked by an analysis.
+type unit struct {
+	name      string // absolute file name
+	path      string // in the file set: relative to the source directory, or the file name of a file checked alone
+	doc       *document
+	file      *token.File // of doc in the file set
+	component *ast.Component
+	info      *checker.Info
+}
+
+// loc returns the location of the byte at offset off of the document of u.
+func (u *unit) loc(off int) token.Loc {
+	if u.file == nil {
+		return token.NoLoc
+	}
+	return u.file.Location(off)
+}
+
+// offset returns the byte offset of the location loc in the document of u.
+func (u *unit) offset(loc token.Loc) int {
+	return u.file.Offset(loc)
+}
+
+// byPath returns the unit of the given path in the file set of a, or nil.
+func (a *analysis) byPath(path string) *unit {
+	return a.units[a.names[path]]
+}
+
+func newProject(root string) *project {
+	p := &project{root: root, published: make(map[string]bool)}
+	p.reset()
+	return p
+}
+
+// reset loads the options of p again and forgets the Go packages it loaded.
+func (p *project) reset() {
+	p.opts, p.optErr = options.Load(p.root)
+	if p.optErr != nil {
+		p.opts = options.Default()
+	}
+	p.loader = checker.NewGoLoader()
+}
+
+// src returns the directory holding the pages directory of p, to which the
+// paths of the components are relative.
+func (p *project) src() string {
+	return filepath.Join(p.root, filepath.FromSlash(path.Dir(p.opts.PagesDir)))
+}
+
+// component returns the slash-separated path of the component file name
+// relative to the source directory of p, or "" if it is not in its pages
+// directory.
+func (p *project) component(name string) string {
+	rel, err := filepath.Rel(p.src(), name)
+	if err != nil {
+		return ""
+	}
+	rel = filepath.ToSlash(rel)
+	if !strings.HasPrefix(rel, path.Base(p.opts.PagesDir)+"/") || path.Ext(rel) != ".html" {
+		return ""
+	}
+	return rel
+}
+
+// findRoot returns the root of the project of the file name: the nearest
+// directory holding go.mod, or else the directory of the file.
+func findRoot(name string) string {
+	for dir := filepath.Dir(name); ; {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return filepath.Dir(name)
+		}
+		dir = parent
+	}
+}
+
+// diagnose checks the components of p, with the text of the open documents
+// docs by file name, and returns their diagnostics by file name, along with
+// the documents of the files of the diagnostics. Files outside of the pages
+// directory are checked on their own. The results are kept as the last
+// analysis of p.
+func (p *project) diagnose(docs map[string]*document) (map[string][]Diagnostic, map[string]*document) {
+	if p.optErr != nil {
+		p.reset() // the options file may be fixed
+	}
+	diags := make(map[string][]Diagnostic)
+	texts := make(map[string]*document)
+	report := func(name string, pos token.Position, severity int, code checker.Code, msg string) {
+		d := texts[name]
+		if d == nil {
+			return
+		}
+		start := d.offsetOf(pos)
+		diags[name] = append(diags[name], Diagnostic{
+			Range:    Range{d.position(start), d.position(wordEnd(d.text, start))},
+			Severity: severity,
+			Code:     string(code),
+			Source:   "vanilla",
+			Message:  msg,
+		})
+	}
+
+	var list checker.ErrorList
+	if errors.As(p.optErr, &list) {
+		name := filepath.Join(p.root, options.File)
+		if d, err := load(name, docs); err == nil {
+			texts[name] = d
+			for _, e := range list {
+				if e.Pos.Filename == options.File {
+					report(name, e.Pos, SeverityError, e.Code, e.Msg)
+				}
+			}
+		}
+	}
+
+	// components of the pages directory, and open files outside of it
+	src := p.src()
+	var paths []string
+	err := filepath.WalkDir(filepath.Join(src, filepath.FromSlash(path.Base(p.opts.PagesDir))), func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(name) != ".html" {
+			return err
+		}
+		paths = append(paths, p.component(name))
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return diags, texts
+	}
+	var alone []string
+	for name := range docs {
+		if findRoot(name) != p.root {
+			continue
+		}
+		if rel := p.component(name); rel != "" {
+			paths = append(paths, rel)
+		} else if filepath.Ext(name) == ".html" {
+			alone = append(alone, name)
+		}
+	}
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+
+	fset := token.NewFileSet()
+	a := &analysis{fset: fset, loader: p.loader, units: make(map[string]*unit), names: make(map[string]string)}
+	p.last = a
+	fileName := a.names // files alone are their own path
+	var files []*linker.File
+	broken := make(map[string]bool) // paths of the files which failed to parse
+	parse := func(name, rel string) *ast.Component {
+		d, err := load(name, docs)
+		if err != nil {
+			return nil
+		}
+		texts[name], fileName[rel] = d, name
+		base := fset.Base()
+		c, err := parser.ParseFile(fset, rel, d.text)
+		if c != nil {
+			a.units[name] = &unit{name: name, path: rel, doc: d, file: fset.File(token.Loc(base)), component: c}
+		}
+		var errs scanner.ErrorList
+		if errors.As(err, &errs) {
+			broken[rel] = true
+			for _, e := range errs {
+				report(name, e.Pos, SeverityError, "", e.Msg)
+			}
+		}
+		return c
+	}
+	for _, rel := range paths {
+		if c := parse(filepath.Join(src, filepath.FromSlash(rel)), rel); c != nil {
+			files = append(files, &linker.File{Path: rel, Component: c})
+		}
+	}
+
+	list = nil
+	infos := make(map[string]*checker.Info)
+	for _, f := range files {
+		list = append(list, checker.CheckLayout(fset, f.Path, f.Component)...)
+		info, errs := checker.Check(fset, fileName[f.Path], f.Component, p.loader)
+		infos[f.Path], a.byPath(f.Path).info = info, info
+		list = append(list, errs...)
+	}
+	g, errs := linker.Link(fset, files)
+	a.graph = g
+	list = append(list, errs...)
+	list = append(list, g.CheckProps(fset, infos)...)
+	_, errs = router.Build(src, paths, infos, p.loader)
+	list = append(list, errs...)
+	list = append(list, codegen.CheckNames(paths)...)
+	for _, name := range alone {
+		if c := parse(name, name); c != nil {
+			list = append(list, checker.CheckLayout(fset, name, c)...)
+			info, errs := checker.Check(fset, name, c, p.loader)
+			a.units[name].info = info
+			list = append(list, errs...)
+		}
+	}
+	list.Sort()
+	for _, e := range list {
+		name := fileName[e.Pos.Filename]
+		if name == "" || broken[e.Pos.Filename] {
+			continue // a Go file, or noise of a parse error
+		}
+		severity := SeverityError
+		if e.Code.IsWarning() {
+			severity = SeverityWarning
+		}
+		report(name, e.Pos, severity, e.Code, e.Msg)
+	}
+	return diags, texts
+}
+
+// load returns the open document of the file name, or else a document of its
+// content on disk.
+func load(name string, docs map[string]*document) (*document, error) {
+	if d := docs[name]; d != nil {
+		return d, nil
+	}
+	text, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return newDocument(fileURI(name), 0, text), nil
+}
+
+// wordEnd returns the end of the word of text starting at offset off, aka an
+// identifier, or of the character at off if it does not start a word. The
+// end of a line is not extended.
+func wordEnd(text []byte, off int) int {
+	end := off
+	for end < len(text) {
+		r, size := utf8.DecodeRune(text[end:])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '$' {
+			break
+		}
+		end += size
+	}
+	if end == off && off < len(text) && text[off] != '\n' && text[off] != '\r' {
+		_, size := utf8.DecodeRune(text[off:])
+		end += size
+	}
+	return end
+}
+
+// fileURI returns the file URI of the absolute file name.
+func fileURI(name string) string {
+	p := filepath.ToSlash(name)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p // aka /C:/dir
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
+
+// uriFile returns the file name of the file URI uri.
+func uriFile(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", errors.New("unsupported URI scheme " + u.Scheme)
+	}
+	p := u.Path
+	if runtime.GOOS == "windows" {
+		p = strings.TrimPrefix(p, "/")
+	}
+	return filepath.Clean(filepath.FromSlash(p)), nil
+}
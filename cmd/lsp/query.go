@@ -0,0 +1,306 @@
+package main
+
+import (
+	"go/types"
+	"path/filepath"
+	"strings"
+
+	"github.com/supaleon/vanilla/internal/ast"
+	"github.com/supaleon/vanilla/internal/linker"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+// at returns the project and the unit of the document of params, along with
+// the byte offset of its position. The unit is nil if the document is not a
+// checked component.
+func (s *server) at(params *TextDocumentPositionParams) (*project, *unit, int, error) {
+	name, err := uriFile(params.TextDocument.URI)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	p := s.project(name)
+	if p.last == nil {
+		p.diagnose(s.docs)
+	}
+	u := p.last.units[name]
+	if u == nil || u.file == nil || u.info == nil {
+		return p, nil, 0, nil
+	}
+	return p, u, u.doc.offset(params.Position), nil
+}
+
+// enclosing returns the nodes of the template of u enclosing the location
+// loc, outermost first. An expression encloses the location right after it,
+// where the cursor is once it is typed.
+func enclosing(u *unit, loc token.Loc) []ast.Node {
+	var path []ast.Node
+	ast.Inspect(u.component.Template, func(n ast.Node) bool {
+		r := n.Range()
+		_, isExpr := n.(ast.Expr)
+		if r.Start <= loc && (loc < r.End || isExpr && loc == r.End) {
+			path = append(path, n)
+			return true
+		}
+		return false
+	})
+	return path
+}
+
+// within reports whether the location loc is in the range r, or right after
+// it.
+func within(r token.Range, loc token.Loc) bool {
+	return r.Start <= loc && loc <= r.End
+}
+
+// tagName returns the range of the name of the start tag of e.
+func tagName(e *ast.Element) token.Range {
+	return token.Range{Start: e.Start + 1, End: e.Start + 1 + token.Loc(len(e.Name))}
+}
+
+// symbol is what a name of a component refers to. Only one of decl, field,
+// goType, target and file is set.
+type symbol struct {
+	rng    token.Range     // of the name in the unit of the query
+	decl   *ast.Ident      // a prop or a loop variable declared in the unit in
+	in     *unit           // of decl
+	field  *types.Var      // a field of a Go struct
+	goType *types.TypeName // an imported Go type
+	target *unit           // an imported component
+	file   string          // an imported Go file
+}
+
+// symbolAt returns the symbol named at the location loc of u, or nil.
+func (a *analysis) symbolAt(u *unit, loc token.Loc) *symbol {
+	if m := u.component.ESModule; m != nil && loc < m.Range().End {
+		return a.scriptSymbolAt(u, m, loc)
+	}
+	path := enclosing(u, loc)
+	for i := len(path) - 1; i >= 0; i-- {
+		switch n := path[i].(type) {
+		case *ast.Ident:
+			if sel, ok := path[i-1].(*ast.Selector); ok && sel.Sel == n {
+				if f := u.info.Fields[sel]; f != nil {
+					return &symbol{rng: n.Range(), field: f}
+				}
+				return nil
+			}
+			decl := u.info.Uses[n]
+			if b, ok := path[i-1].(*ast.ForBlock); ok && (b.Key == n || b.Value == n) {
+				decl = n
+			}
+			if decl == nil {
+				return nil
+			}
+			return &symbol{rng: n.Range(), decl: decl, in: u}
+		case *ast.Attribute:
+			e := path[i-1].(*ast.Element)
+			target := a.component(u, e)
+			if target == nil || target.component.ESModule == nil || !within(n.Range(), loc) || loc > n.NameLoc+token.Loc(len(n.Name)) {
+				return nil
+			}
+			if d := target.component.ESModule.Prop(n.Name); d != nil {
+				return &symbol{rng: token.Range{Start: n.NameLoc, End: n.NameLoc + token.Loc(len(n.Name))}, decl: d.Name, in: target}
+			}
+			return nil
+		case *ast.Element:
+			if target := a.component(u, n); target != nil && within(tagName(n), loc) {
+				return &symbol{rng: tagName(n), target: target}
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// scriptSymbolAt returns the symbol named at the location loc of the script
+// m of u, or nil.
+func (a *analysis) scriptSymbolAt(u *unit, m *ast.ESModule, loc token.Loc) *symbol {
+	for _, spec := range m.Imports {
+		switch spec.File {
+		case ast.FileComponent:
+			var target *unit
+			for _, imp := range a.graph.Imports(u.path) {
+				if imp.Spec == spec && imp.Target != nil {
+					target = a.byPath(imp.Target.Path)
+				}
+			}
+			switch {
+			case target == nil:
+			case within(spec.Range(), loc):
+				return &symbol{rng: spec.Range(), target: target}
+			case spec.Name != nil && within(spec.Name.Range(), loc):
+				return &symbol{rng: spec.Name.Range(), target: target}
+			}
+		case ast.FileGo:
+			if within(spec.Range(), loc) {
+				return &symbol{rng: spec.Range(), file: a.goFile(u, spec)}
+			}
+			for _, name := range spec.Names {
+				if obj := a.goType(u, name.Name); obj != nil && within(name.Range(), loc) {
+					return &symbol{rng: name.Range(), goType: obj}
+				}
+			}
+		}
+	}
+	for _, d := range m.Props {
+		if within(d.Name.Range(), loc) {
+			return &symbol{rng: d.Name.Range(), decl: d.Name, in: u}
+		}
+		if d.Type != nil && within(d.Type.Range(), loc) {
+			if obj := a.goType(u, d.Type.Name); obj != nil {
+				return &symbol{rng: d.Type.Range(), goType: obj}
+			}
+		}
+	}
+	return nil
+}
+
+// component returns the unit of the component of the element e of u, or nil
+// if e is not a component element.
+func (a *analysis) component(u *unit, e *ast.Element) *unit {
+	if !linker.IsComponentTag(e.Name) {
+		return nil
+	}
+	if f := a.graph.Resolve(u.path, e.Name); f != nil {
+		return a.byPath(f.Path)
+	}
+	return nil
+}
+
+// goFile returns the file name of the Go file imported by spec in u.
+func (a *analysis) goFile(u *unit, spec *ast.ImportSpec) string {
+	return filepath.Join(filepath.Dir(u.name), filepath.FromSlash(spec.Path))
+}
+
+// goType returns the Go type of the given name imported by u, or nil.
+func (a *analysis) goType(u *unit, name string) *types.TypeName {
+	for _, spec := range u.component.ESModule.Imports {
+		if spec.File != ast.FileGo {
+			continue
+		}
+		for _, id := range spec.Names {
+			if id.Name != name {
+				continue
+			}
+			pkg, _ := a.loader.Load(filepath.Dir(a.goFile(u, spec)))
+			if pkg == nil {
+				return nil
+			}
+			obj, _ := pkg.Scope().Lookup(name).(*types.TypeName)
+			return obj
+		}
+	}
+	return nil
+}
+
+// rangeOf returns the range of the document of u of the location range r.
+func (u *unit) rangeOf(r token.Range) Range {
+	return Range{u.doc.position(u.offset(r.Start)), u.doc.position(u.offset(r.End))}
+}
+
+// typeString returns the string of t, qualified by package names only.
+func typeString(t types.Type) string {
+	if t == nil {
+		return "invalid type"
+	}
+	return types.TypeString(t, (*types.Package).Name)
+}
+
+// ----------------------------------------------------------------------------
+// Hover
+
+func (s *server) hover(params *TextDocumentPositionParams) (*Hover, error) {
+	p, u, off, err := s.at(params)
+	if err != nil || u == nil {
+		return nil, err
+	}
+	sym := p.last.symbolAt(u, u.loc(off))
+	if sym == nil {
+		return nil, nil
+	}
+	text := p.last.describe(sym)
+	if text == "" {
+		return nil, nil
+	}
+	r := u.rangeOf(sym.rng)
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: &r}, nil
+}
+
+// describe returns the Markdown description of the symbol sym: its Go type
+// and the doc comment of its Go declaration.
+func (a *analysis) describe(sym *symbol) string {
+	var code, doc string
+	switch {
+	case sym.decl != nil:
+		if m := sym.in.component.ESModule; m != nil && m.Prop(sym.decl.Name) != nil && m.Prop(sym.decl.Name).Name == sym.decl {
+			t := sym.in.info.Props[sym.decl.Name]
+			code = "prop " + sym.decl.Name + " " + typeString(t)
+			if named, ok := t.(*types.Named); ok {
+				doc = a.loader.Doc(named.Obj())
+			}
+		} else {
+			code = "loop variable " + sym.decl.Name + " " + typeString(sym.in.info.TypeOf(sym.decl))
+		}
+	case sym.field != nil:
+		code = "field " + sym.field.Name() + " " + typeString(sym.field.Type())
+		doc = a.loader.Doc(sym.field)
+	case sym.goType != nil:
+		code = "type " + sym.goType.Name() + " " + typeString(sym.goType.Type().Underlying())
+		doc = a.loader.Doc(sym.goType)
+	case sym.target != nil:
+		var b strings.Builder
+		b.WriteString("component " + linker.ComponentName(sym.target.path) + " // " + sym.target.path)
+		if m := sym.target.component.ESModule; m != nil {
+			for _, d := range m.Props {
+				b.WriteString("\nprop " + d.Name.Name + " " + typeString(sym.target.info.Props[d.Name.Name]))
+			}
+		}
+		code = b.String()
+	default:
+		return ""
+	}
+	text := "```go\n" + code + "\n```"
+	if doc != "" {
+		text += "\n\n" + strings.TrimSpace(doc)
+	}
+	return text
+}
+
+// ----------------------------------------------------------------------------
+// Definition
+
+func (s *server) definition(params *TextDocumentPositionParams) (*Location, error) {
+	p, u, off, err := s.at(params)
+	if err != nil || u == nil {
+		return nil, err
+	}
+	sym := p.last.symbolAt(u, u.loc(off))
+	switch {
+	case sym == nil:
+		return nil, nil
+	case sym.decl != nil:
+		return &Location{URI: sym.in.doc.uri, Range: sym.in.rangeOf(sym.decl.Range())}, nil
+	case sym.field != nil:
+		return s.goLocation(p.last, sym.field)
+	case sym.goType != nil:
+		return s.goLocation(p.last, sym.goType)
+	case sym.target != nil:
+		return &Location{URI: sym.target.doc.uri}, nil
+	}
+	return &Location{URI: fileURI(sym.file)}, nil
+}
+
+// goLocation returns the location of the name of the declaration of the Go
+// object obj loaded by the loader of a.
+func (s *server) goLocation(a *analysis, obj types.Object) (*Location, error) {
+	pos := a.loader.FileSet().Position(obj.Pos())
+	if !pos.IsValid() {
+		return nil, nil
+	}
+	d, err := load(pos.Filename, s.docs)
+	if err != nil {
+		return nil, err
+	}
+	start := d.offsetOf(token.Position{Line: pos.Line, Column: pos.Column})
+	return &Location{URI: d.uri, Range: Range{d.position(start), d.position(start + len(obj.Name()))}}, nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const userGo = `package app
+
+// User is a user of the app.
+type User struct {
+	// Name is the name displayed to the other users.
+	Name    string
+	Tags    []string
+	Profile *Profile
+	URL     string
+}
+
+type Profile struct {
+	City string
+}
+`
+
+const cardHTML = `<script>
+    let title = prop("")
+    let count = prop(1)
+</script>
+
+<p>{title}</p>
+`
+
+const indexScript = `<script>
+    import Card from "./Card.html"
+    import {User} from "../user.go"
+    let user = prop(User())
+    let theme = prop("dark")
+</script>
+
+`
+
+// query writes a project of a page Index of the given template, whose cursor
+// is marked by `|`, and returns a server with Index open, along with the
+// params of the position of the cursor.
+func query(t *testing.T, tmpl string) (*server, *TextDocumentPositionParams) {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":          "module example.com/app\n\ngo 1.24\n",
+		"user.go":         userGo,
+		"pages/Card.html": cardHTML,
+	} {
+		name = filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	text := indexScript + tmpl
+	off := strings.Index(text, "|")
+	text = text[:off] + text[off+1:]
+	name := filepath.Join(root, "pages", "Index.html")
+	d := newDocument(fileURI(name), 1, []byte(text))
+
+	s := newServer(strings.NewReader(""), io.Discard, log.New(io.Discard, "", 0))
+	s.docs[name] = d
+	return s, &TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: d.uri}, Position: d.position(off)}
+}
+
+func TestCompletion(t *testing.T) {
+	tests := []struct {
+		tmpl, want string
+	}{
+		{"<div>{user.|}</div>", "name tags profile URL"},
+		{"<div>{user.na|}</div>", "name tags profile URL"},
+		{"<div>{user.profile.|}</div>", "city"},
+		{`<div class="{user.|}"></div>`, "name tags profile URL"},
+		{`<div hidden={!user.|}></div>`, "name tags profile URL"},
+		{"<div>{theme.|}</div>", ""},
+		{"<div>{th|}</div>", "user theme"},
+		{"<div>{for i, tag in user.tags}{t|}{/for}</div>", "i tag user theme"},
+		{"<div>{if |}{/if}</div>", "user theme"},
+		{"<div><C|</div>", "Card"},
+		{"<div><|</div>", "Card"},
+		{"<div><Card |></Card></div>", "title count"},
+		{`<div><Card title="a" c|></Card></div>`, "count"},
+		{`<div><Card title="a|"></Card></div>`, ""},
+		{"<div><p |></p></div>", ""},
+		{"<div>te|xt</div>", ""},
+	}
+	for _, test := range tests {
+		s, params := query(t, test.tmpl)
+		list, err := s.completion(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var labels []string
+		for _, item := range list.Items {
+			labels = append(labels, item.Label)
+		}
+		if got := strings.Join(labels, " "); got != test.want {
+			t.Errorf("%s: got completions %q, want %q", test.tmpl, got, test.want)
+		}
+	}
+
+	s, params := query(t, "<div>{user.na|}</div>")
+	list, err := s.completion(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := list.Items[0]
+	start := Position{params.Position.Line, params.Position.Character - 2}
+	if item.TextEdit == nil || item.TextEdit.Range != (Range{start, params.Position}) || item.Detail != "string" ||
+		item.Documentation == nil || item.Documentation.Value != "Name is the name displayed to the other users." {
+		t.Errorf("got completion %+v", item)
+	}
+}
+
+func TestHover(t *testing.T) {
+	tests := []struct {
+		tmpl, want string
+	}{
+		{"<div>{user.na|me}</div>", "```go\nfield Name string\n```\n\nName is the name displayed to the other users."},
+		{"<div>{us|er.name}</div>", "```go\nprop user app.User\n```\n\nUser is a user of the app."},
+		{"<div>{for i, tag in user.tags}{ta|g}{/for}</div>", "```go\nloop variable tag string\n```"},
+		{`<div><Ca|rd title="a"></Card></div>`, "```go\ncomponent Card // pages/Card.html\nprop title string\nprop count int32\n```"},
+		{`<div><Card co|unt={1}></Card></div>`, "```go\nprop count int32\n```"},
+		{"<div>te|xt</div>", ""},
+	}
+	for _, test := range tests {
+		s, params := query(t, test.tmpl)
+		h, err := s.hover(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if h != nil {
+			got = h.Contents.Value
+		}
+		if got != test.want {
+			t.Errorf("%s: got hover\n%s\nwant\n%s", test.tmpl, got, test.want)
+		}
+	}
+
+	// in the script
+	s, params := query(t, "<div>|</div>")
+	params.Position = Position{Line: 3, Character: 22} // User of prop(User())
+	h, err := s.hover(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "```go\ntype User struct{Name string; Tags []string; Profile *app.Profile; URL string}\n```\n\nUser is a user of the app."; h == nil || h.Contents.Value != want {
+		t.Errorf("hover of a Go type: got %+v, want %s", h, want)
+	}
+	if want := (Range{Position{3, 20}, Position{3, 24}}); h != nil && *h.Range != want {
+		t.Errorf("hover of a Go type: got range %v, want %v", *h.Range, want)
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	tests := []struct {
+		tmpl      string
+		file      string
+		line, col int // 0-based
+	}{
+		{"<div>{user.na|me}</div>", "user.go", 5, 1},
+		{"<div>{user.profile.ci|ty}</div>", "user.go", 12, 1},
+		{"<div>{us|er.name}</div>", "pages/Index.html", 3, 8},
+		{"<div>{for i, tag in user.tags}{ta|g}{/for}</div>", "pages/Index.html", 7, 13},
+		{`<div><Ca|rd title="a"></Card></div>`, "pages/Card.html", 0, 0},
+		{`<div><Card co|unt={1}></Card></div>`, "pages/Card.html", 2, 8},
+	}
+	for _, test := range tests {
+		s, params := query(t, test.tmpl)
+		loc, err := s.definition(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loc == nil {
+			t.Errorf("%s: got no definition", test.tmpl)
+			continue
+		}
+		name, _ := uriFile(loc.URI)
+		if !strings.HasSuffix(filepath.ToSlash(name), "/"+test.file) || loc.Range.Start != (Position{test.line, test.col}) {
+			t.Errorf("%s: got definition %s:%v, want %s:%d:%d", test.tmpl, name, loc.Range.Start, test.file, test.line, test.col)
+		}
+	}
+
+	s, params := query(t, "<div>te|xt</div>")
+	if loc, err := s.definition(params); err != nil || loc != nil {
+		t.Errorf("definition of text: got %v, %v", loc, err)
+	}
+	for _, pos := range []Position{{1, 24}, {2, 12}} { // "./Card.html" and User
+		params.Position = pos
+		loc, err := s.definition(params)
+		if err != nil || loc == nil {
+			t.Errorf("definition at %v: got %v, %v", pos, loc, err)
+		}
+	}
+}
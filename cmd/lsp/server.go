@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"path/filepath"
+	"slices"
+
+	"github.com/supaleon/vanilla/internal/options"
+)
+
+// errExit is returned by [server.run] once the client asks the server to
+// exit.
+var errExit = errors.New("exit")
+
+// server is a language server of Vanilla components. It handles the messages
+// of its client in order, one at a time.
+type server struct {
+	conn *conn
+	log  *log.Logger
+
+	initialized bool
+	shutdown    bool                 // shutdown requested, so that exit succeeds
+	docs        map[string]*document // open documents by file name
+	projects    map[string]*project  // by root
+}
+
+func newServer(r io.Reader, w io.Writer, logger *log.Logger) *server {
+	return &server{
+		conn:     newConn(r, w),
+		log:      logger,
+		docs:     make(map[string]*document),
+		projects: make(map[string]*project),
+	}
+}
+
+// run serves the messages of the client until it asks the server to exit, in
+// which case it returns errExit, or until the end of the stream.
+func (s *server) run() error {
+	for {
+		m, err := s.conn.read()
+		var re *responseError
+		switch {
+		case errors.As(err, &re):
+			if err := s.conn.reply(json.RawMessage("null"), nil, re); err != nil {
+				return err
+			}
+			continue
+		case err != nil:
+			return err
+		}
+		if err := s.handle(m); err != nil {
+			return err
+		}
+	}
+}
+
+// handle handles the request or notification m. It returns an error only if
+// the server must stop.
+func (s *server) handle(m *message) error {
+	if m.Method == "" {
+		return nil // a response, the server sends no requests
+	}
+	result, err := s.dispatch(m)
+	if m.Method == "exit" {
+		return errExit
+	}
+	if m.ID == nil {
+		if err != nil {
+			s.log.Printf("%s: %v", m.Method, err)
+		}
+		return nil
+	}
+	return s.conn.reply(m.ID, result, err)
+}
+
+// dispatch calls the handler of the method of m and returns its result.
+func (s *server) dispatch(m *message) (any, error) {
+	switch {
+	case m.Method == "initialize":
+		if s.initialized {
+			return nil, &responseError{codeInvalidRequest, "server already initialized"}
+		}
+		s.initialized = true
+		return s.initialize()
+	case m.Method == "exit":
+		return nil, nil
+	case !s.initialized:
+		return nil, &responseError{codeServerNotInitialized, "server not initialized"}
+	case s.shutdown:
+		return nil, &responseError{codeInvalidRequest, "server is shutting down"}
+	}
+
+	switch m.Method {
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := unmarshal(m.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.didOpen(&params)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := unmarshal(m.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.didChange(&params)
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := unmarshal(m.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.didClose(&params)
+	case "textDocument/didSave":
+		var params DidSaveTextDocumentParams
+		if err := unmarshal(m.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.didSave(&params)
+	case "workspace/didChangeWatchedFiles":
+		var params DidChangeWatchedFilesParams
+		if err := unmarshal(m.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.didChangeWatchedFiles(&params)
+	case "textDocument/completion", "textDocument/hover", "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err := unmarshal(m.Params, &params); err != nil {
+			return nil, err
+		}
+		switch m.Method {
+		case "textDocument/completion":
+			return s.completion(&params)
+		case "textDocument/hover":
+			return s.hover(&params)
+		}
+		return s.definition(&params)
+	}
+	if m.ID == nil {
+		return nil, nil // aka $/cancelRequest
+	}
+	return nil, &responseError{codeMethodNotFound, "method not found: " + m.Method}
+}
+
+// unmarshal decodes the params of a message into v.
+func unmarshal(params json.RawMessage, v any) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{codeInvalidParams, err.Error()}
+	}
+	return nil
+}
+
+func (s *server) initialize() (*InitializeResult, error) {
+	return &InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync: &TextDocumentSyncOptions{
+				OpenClose: true,
+				Change:    SyncIncremental,
+				Save:      &SaveOptions{},
+			},
+			CompletionProvider: &CompletionOptions{TriggerCharacters: []string{".", "<", "{"}},
+			HoverProvider:      true,
+			DefinitionProvider: true,
+		},
+		ServerInfo: &ServerInfo{Name: "vanilla-lsp"},
+	}, nil
+}
+
+func (s *server) didOpen(params *DidOpenTextDocumentParams) error {
+	item := params.TextDocument
+	name, err := uriFile(item.URI)
+	if err != nil {
+		return err
+	}
+	s.docs[name] = newDocument(item.URI, item.Version, []byte(item.Text))
+	return s.publish(s.project(name))
+}
+
+func (s *server) didChange(params *DidChangeTextDocumentParams) error {
+	name, err := uriFile(params.TextDocument.URI)
+	if err != nil {
+		return err
+	}
+	d := s.docs[name]
+	if d == nil {
+		return errors.New("change of a document which is not open: " + params.TextDocument.URI)
+	}
+	if d, err = d.applyChanges(params.TextDocument.Version, params.ContentChanges); err != nil {
+		return err
+	}
+	s.docs[name] = d
+	return s.publish(s.project(name))
+}
+
+func (s *server) didClose(params *DidCloseTextDocumentParams) error {
+	name, err := uriFile(params.TextDocument.URI)
+	if err != nil {
+		return err
+	}
+	delete(s.docs, name)
+	// the diagnostics of the file on disk replace those of the document
+	return s.publish(s.project(name))
+}
+
+func (s *server) didSave(params *DidSaveTextDocumentParams) error {
+	name, err := uriFile(params.TextDocument.URI)
+	if err != nil {
+		return err
+	}
+	return s.changed([]string{name})
+}
+
+func (s *server) didChangeWatchedFiles(params *DidChangeWatchedFilesParams) error {
+	var names []string
+	for _, c := range params.Changes {
+		if name, err := uriFile(c.URI); err == nil {
+			names = append(names, name)
+		}
+	}
+	return s.changed(names)
+}
+
+// changed checks the projects of the files of the given names again, which
+// changed on disk. The Go packages of the projects are loaded again if Go
+// files changed, and their options if the options file did.
+func (s *server) changed(names []string) error {
+	var projects []*project
+	for _, name := range names {
+		p := s.project(name)
+		if filepath.Ext(name) == ".go" || filepath.Base(name) == options.File {
+			p.reset()
+		}
+		if !slices.Contains(projects, p) {
+			projects = append(projects, p)
+		}
+	}
+	for _, p := range projects {
+		if err := s.publish(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// project returns the project of the file name.
+func (s *server) project(name string) *project {
+	root := findRoot(name)
+	p := s.projects[root]
+	if p == nil {
+		p = newProject(root)
+		s.projects[root] = p
+	}
+	return p
+}
+
+// publish checks the project p and publishes its diagnostics, clearing those
+// of the files which have none anymore.
+func (s *server) publish(p *project) error {
+	diags, texts := p.diagnose(s.docs)
+	var names []string
+	for name := range p.published {
+		if diags[name] == nil {
+			names = append(names, name)
+		}
+	}
+	for name := range s.docs {
+		if _, ok := diags[name]; !ok && texts[name] != nil {
+			names = append(names, name) // open documents get empty diagnostics
+		}
+	}
+	for name := range diags {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range slices.Compact(names) {
+		params := &PublishDiagnosticsParams{URI: fileURI(name), Diagnostics: diags[name]}
+		if d := s.docs[name]; d != nil {
+			params.URI, params.Version = d.uri, &d.version
+		}
+		if params.Diagnostics == nil {
+			params.Diagnostics = []Diagnostic{}
+			delete(p.published, name)
+		} else {
+			p.published[name] = true
+		}
+		if err := s.conn.notify("textDocument/publishDiagnostics", params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
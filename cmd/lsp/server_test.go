@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// client is the client of a server running in the background.
+type client struct {
+	t        *testing.T
+	conn     *conn
+	id       int
+	messages chan *message // read in the background, so that the server never blocks
+	done     chan error    // result of the run of the server
+}
+
+func startServer(t *testing.T) *client {
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	s := newServer(sr, sw, log.New(io.Discard, "", 0))
+	c := &client{t: t, conn: newConn(cr, cw), messages: make(chan *message, 100), done: make(chan error, 1)}
+	go func() {
+		err := s.run()
+		sw.Close()
+		c.done <- err
+	}()
+	go func() {
+		defer close(c.messages)
+		for {
+			m, err := c.conn.read()
+			if err != nil {
+				return
+			}
+			c.messages <- m
+		}
+	}()
+	t.Cleanup(func() { cw.Close() })
+	return c
+}
+
+// call sends the request of method with params and decodes its result into
+// result.
+func (c *client) call(method string, params, result any) error {
+	c.t.Helper()
+	c.id++
+	id, _ := json.Marshal(c.id)
+	data, _ := json.Marshal(params)
+	if err := c.conn.write(&message{ID: id, Method: method, Params: data}); err != nil {
+		c.t.Fatal(err)
+	}
+	for {
+		m := c.read()
+		if m.Method != "" {
+			continue // a notification
+		}
+		if m.Error != nil {
+			return m.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(m.Result, result); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	if err := c.conn.notify(method, params); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) read() *message {
+	c.t.Helper()
+	select {
+	case m, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("server closed the connection")
+		}
+		return m
+	case <-time.After(time.Minute):
+		c.t.Fatal("timeout waiting for a message of the server")
+	}
+	return nil
+}
+
+// diagnostics returns the diagnostics published next for the file name.
+func (c *client) diagnostics(name string) *PublishDiagnosticsParams {
+	c.t.Helper()
+	for {
+		m := c.read()
+		if m.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var params PublishDiagnosticsParams
+		if err := json.Unmarshal(m.Params, &params); err != nil {
+			c.t.Fatal(err)
+		}
+		if params.URI == fileURI(name) {
+			return &params
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":            "module example.com/app\n\ngo 1.24\n",
+		"pages/Card.html":   "<script>\n    let title = prop(\"\")\n</script>\n\n<p>{title}</p>\n",
+		"pages/Index.html":  "<script>\n    import Card from \"./Card.html\"\n</script>\n\n<div><Card title=\"a\"></Card></div>\n",
+		"pages/Layout.html": "<script>\n</script>\n\n<html><body><slot/></body></html>\n",
+	} {
+		name = filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	index := filepath.Join(root, "pages", "Index.html")
+	uri := fileURI(index)
+
+	c := startServer(t)
+	if err := c.call("textDocument/hover", struct{}{}, nil); !isCode(err, codeServerNotInitialized) {
+		t.Errorf("request before initialize: got %v, want code %d", err, codeServerNotInitialized)
+	}
+	var init InitializeResult
+	if err := c.call("initialize", &InitializeParams{RootURI: fileURI(root)}, &init); err != nil {
+		t.Fatal(err)
+	}
+	if sync := init.Capabilities.TextDocumentSync; sync == nil || sync.Change != SyncIncremental {
+		t.Errorf("got text document sync %+v, want incremental", sync)
+	}
+	if caps := init.Capabilities; caps.CompletionProvider == nil || !caps.HoverProvider || !caps.DefinitionProvider {
+		t.Errorf("got capabilities %+v, want completion, hover and definition", caps)
+	}
+	c.notify("initialized", struct{}{})
+
+	// an undefined name after characters of 2 and 1 UTF-16 units
+	text := "<script>\n</script>\n\n<div>😀é {missing}</div>\n"
+	c.notify("textDocument/didOpen", &DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, LanguageID: "html", Version: 1, Text: text}})
+	got := c.diagnostics(index)
+	if got.Version == nil || *got.Version != 1 || len(got.Diagnostics) != 1 {
+		t.Fatalf("got diagnostics %+v, want 1 of version 1", got)
+	}
+	want := Range{Position{3, 10}, Position{3, 17}}
+	if d := got.Diagnostics[0]; d.Range != want || d.Severity != SeverityError || d.Code == "" || d.Source != "vanilla" {
+		t.Errorf("got diagnostic %+v, want an error at %v", d, want)
+	}
+
+	// the name is fixed by an incremental change
+	c.notify("textDocument/didChange", &DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Range: &Range{Position{3, 9}, Position{3, 18}}, Text: "text"}},
+	})
+	if got := c.diagnostics(index); *got.Version != 2 || len(got.Diagnostics) != 0 {
+		t.Errorf("after the fix: got diagnostics %+v, want none", got)
+	}
+
+	// a parse error of Card is reported until it is closed
+	card := filepath.Join(root, "pages", "Card.html")
+	c.notify("textDocument/didOpen", &DidOpenTextDocumentParams{TextDocument: TextDocumentItem{
+		URI: fileURI(card), Version: 1, Text: "<script>\n    let heading = prop(\"\")\n</script>\n\n<p>{heading</p>\n",
+	}})
+	if got := c.diagnostics(card); len(got.Diagnostics) == 0 || got.Diagnostics[0].Range.Start.Line != 4 {
+		t.Errorf("got diagnostics of Card %+v, want a parse error on line 4", got)
+	}
+
+	c.notify("textDocument/didClose", &DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: fileURI(card)}})
+	if got := c.diagnostics(card); len(got.Diagnostics) != 0 {
+		t.Errorf("after closing Card: got diagnostics %+v, want none", got)
+	}
+
+	if err := c.call("shutdown", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; !errors.Is(err, errExit) {
+		t.Errorf("got %v, want errExit", err)
+	}
+}
+
+func isCode(err error, code int) bool {
+	var re *responseError
+	return errors.As(err, &re) && re.Code == code
+}
+
+func TestURI(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "my pages", "Index.html")
+	uri := fileURI(name)
+	if got, err := uriFile(uri); err != nil || got != name {
+		t.Errorf("uriFile(%q) = %q, %v; want %q", uri, got, err, name)
+	}
+	if _, err := uriFile("untitled:Untitled-1"); err == nil {
+		t.Error("uriFile of an untitled URI: got no error")
+	}
+	if got := fileURI("/a/b c.html"); !reflect.DeepEqual(got, "file:///a/b%20c.html") {
+		t.Errorf("fileURI = %q", got)
+	}
+}
@@ -1,10 +1,309 @@
+// Command vanilla creates, checks, builds and runs Vanilla projects.
+//
+// Usage:
+//
+//	vanilla new [--module path] dir
+//	vanilla check [dir]
+//	vanilla build [--static] [--out path] [dir]
+//	vanilla dev [--port port] [dir]
+//	vanilla cache clean
+//
+// The exit code is 1 if the project has errors, aka of its components or of
+// its Go code, 2 if the command line is invalid and 3 on internal failures,
+// so that CI can tell a broken project from a broken toolchain.
+//
+// The commands take the settings of the project from its vanilla.options.json
+// file and from the VANILLA_* environment variables overriding it, aka
+// VANILLA_DEV_PORT for dev.port; the flags take precedence over both.
 package main
 
-import _ "github.com/tdewolff/parse/v2"
-import _ "github.com/evanw/esbuild/pkg/api"
-import _ "golang.org/x/net/html"
-import _ "github.com/tdewolff/hasher"
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+
+	"github.com/supaleon/vanilla/internal/build"
+	"github.com/supaleon/vanilla/internal/build/cache"
+	"github.com/supaleon/vanilla/internal/checker"
+	"github.com/supaleon/vanilla/internal/dev"
+	"github.com/supaleon/vanilla/internal/options"
+	"github.com/supaleon/vanilla/internal/scanner"
+)
+
+const usage = `usage: vanilla <command> [arguments]
+
+commands:
+  new [--module path] dir           create a project in dir
+  check [dir]                       check the components of the project
+  build [--static] [--out path] [dir]
+                                    build the binary of the project into its
+                                    binDir, or render it into a static site
+  dev [--port port] [dir]           serve the project, rebuilding it and reloading
+                                    its pages on changes
+  cache clean                       remove the entries of the build cache
+`
+
+// Exit codes.
+const (
+	exitErrors   = 1 // the project has errors
+	exitUsage    = 2 // invalid command line
+	exitInternal = 3 // internal failure
+)
+
+// usageError is an error of the command line.
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+var commands = map[string]func(args []string) error{
+	"new":   newCmd,
+	"check": checkCmd,
+	"build": buildCmd,
+	"dev":   devCmd,
+	"cache": cacheCmd,
+}
 
 func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(exitUsage)
+	}
+	cmd := commands[os.Args[1]]
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "vanilla: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(exitUsage)
+	}
+	if err := cmd(os.Args[2:]); err != nil {
+		printError(os.Stderr, err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// exitCode returns the exit code of a command failing with err.
+func exitCode(err error) int {
+	var (
+		usageErr  usageError
+		parseErrs scanner.ErrorList
+		checkErrs checker.ErrorList
+		staticErr *build.StaticError
+		goErr     *build.GoError
+		exitErr   *exec.ExitError
+	)
+	switch {
+	case errors.As(err, &usageErr), errors.Is(err, flag.ErrHelp):
+		return exitUsage
+	case errors.Is(err, exec.ErrNotFound):
+		return exitInternal // no go command
+	case errors.As(err, &parseErrs), errors.As(err, &checkErrs), errors.As(err, &staticErr),
+		errors.As(err, &goErr), errors.As(err, &exitErr), errors.Is(err, fs.ErrNotExist):
+		return exitErrors
+	}
+	return exitInternal
+}
+
+// printError prints err to w, one line per error of a list.
+func printError(w io.Writer, err error) {
+	var list checker.ErrorList
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+	case errors.As(err, &list):
+		for _, e := range list {
+			fmt.Fprintln(w, e)
+		}
+	default:
+		scanner.PrintError(w, err)
+	}
+}
+
+// parseFlags parses the arguments of a command with flags, and returns the
+// project directory, the only positional argument, "." if absent.
+func parseFlags(flags *flag.FlagSet, args []string) (string, error) {
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			flags.SetOutput(os.Stderr)
+			flags.Usage()
+			return "", err
+		}
+		return "", usageError{"vanilla " + flags.Name() + ": " + err.Error()}
+	}
+	switch flags.NArg() {
+	case 0:
+		return ".", nil
+	case 1:
+		return flags.Arg(0), nil
+	}
+	return "", usageError{"vanilla " + flags.Name() + ": too many arguments"}
+}
+
+// openCache opens the default build cache, or returns nil if it cannot be
+// opened, in which case every component is compiled.
+func openCache() *cache.Cache {
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		return nil
+	}
+	c, err := cache.Open(dir)
+	if err != nil {
+		return nil
+	}
+	return c
+}
+
+// config returns the build configuration of the project in dir with the
+// options it loads.
+func config(dir string) (*build.Config, error) {
+	opts, err := options.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &build.Config{Root: dir, Options: opts}, nil
+}
+
+// printWarnings prints the warnings of a build to standard error.
+func printWarnings(list checker.ErrorList) {
+	for _, e := range list {
+		fmt.Fprintln(os.Stderr, e)
+	}
+}
+
+func newCmd(args []string) error {
+	flags := flag.NewFlagSet("new", flag.ContinueOnError)
+	module := flags.String("module", "", "module path of the project; the name of dir if empty")
+	dir, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return usageError{"vanilla new: missing project directory"}
+	}
+	if *module == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		*module = filepath.Base(abs)
+	}
+	if err := newProject(dir, *module); err != nil {
+		return err
+	}
+	fmt.Printf("created %s in %s\n", *module, dir)
+	if vanillaVersion() == "" {
+		fmt.Printf("run `go get github.com/supaleon/vanilla` in %s to add the vanilla module\n", dir)
+	}
+	return nil
+}
+
+func checkCmd(args []string) error {
+	dir, err := parseFlags(flag.NewFlagSet("check", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+	conf, err := config(dir)
+	if err != nil {
+		return err
+	}
+	warnings, err := build.Check(conf)
+	if err != nil {
+		return err
+	}
+	printWarnings(warnings)
+	return nil
+}
+
+func buildCmd(args []string) error {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	static := flags.Bool("static", false, "render every page into a static site")
+	out := flags.String("out", "", "path of the binary, or directory of the static site (default <binDir>/<name>, or static.outDir if set, else <binDir>/static)")
+	dir, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	conf, err := config(dir)
+	if err != nil {
+		return err
+	}
+	conf.Production, conf.Cache = true, openCache()
+	if *static {
+		if *out == "" {
+			*out = filepath.Join(dir, filepath.FromSlash(conf.Options.StaticDir()))
+		}
+		m, err := build.Static(conf, *out)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d pages, %d assets written to %s\n", len(m.Pages), len(m.Assets), *out)
+		return nil
+	}
+	if *out != "" {
+		// relative to the current directory rather than to the project
+		abs, err := filepath.Abs(*out)
+		if err != nil {
+			return err
+		}
+		*out = abs
+	}
+	output, res, err := build.Binary(conf, *out)
+	if err != nil {
+		return err
+	}
+	printWarnings(res.Warnings)
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(dir, output)
+	}
+	fmt.Println(output)
+	return nil
+}
+
+func devCmd(args []string) error {
+	flags := flag.NewFlagSet("dev", flag.ContinueOnError)
+	port := flags.Int("port", 0, "port of the server (default dev.port)")
+	dir, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	conf, err := config(dir)
+	if err != nil {
+		return err
+	}
+	if *port == 0 {
+		*port = conf.Options.Dev.Port
+	}
+	conf.Cache = openCache()
+	l, err := net.Listen("tcp", ":"+strconv.Itoa(*port))
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return dev.Serve(ctx, l, conf, os.Stderr)
+}
 
+func cacheCmd(args []string) error {
+	if len(args) != 1 || args[0] != "clean" {
+		return usageError{"usage: vanilla cache clean"}
+	}
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		return err
+	}
+	c, err := cache.Open(dir)
+	if err != nil {
+		return err
+	}
+	return c.Clean()
 }
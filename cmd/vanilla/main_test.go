@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/supaleon/vanilla/internal/build"
+	"github.com/supaleon/vanilla/internal/checker"
+	"github.com/supaleon/vanilla/internal/scanner"
+)
+
+func TestNew(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "app")
+	if err := newProject(dir, "example.com/app"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"bin", "pages/Index.html", "pages/Layout.html", "public", "package.json", "vanilla.options.json", "go.mod", "main.go"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			t.Error(err)
+		}
+	}
+	warnings, err := build.Check(&build.Config{Root: dir})
+	if err != nil || len(warnings) > 0 {
+		t.Errorf("checking the new project: %v %v", warnings, err)
+	}
+
+	err = newProject(dir, "example.com/app")
+	if code := exitCode(err); code != exitUsage {
+		t.Errorf("new project in a non-empty directory: got %v, exit code %d; want %d", err, code, exitUsage)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{usageError{"vanilla build: too many arguments"}, exitUsage},
+		{scanner.ErrorList{{Msg: "expected '>'"}}, exitErrors},
+		{checker.ErrorList{{Code: checker.BundleFailed}}, exitErrors},
+		{&build.StaticError{}, exitErrors},
+		{&build.GoError{Args: []string{"build"}, Err: &exec.ExitError{}}, exitErrors},
+		{fmt.Errorf("compiling: %w", os.ErrNotExist), exitErrors},
+		{&exec.Error{Name: "go", Err: exec.ErrNotFound}, exitInternal},
+		{errors.New("disk full"), exitInternal},
+	}
+	for _, test := range tests {
+		if code := exitCode(test.err); code != test.code {
+			t.Errorf("exitCode(%v) = %d, want %d", test.err, code, test.code)
+		}
+	}
+}
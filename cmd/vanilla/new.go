@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+
+	"github.com/supaleon/vanilla/internal/options"
+)
+
+const vanillaModule = "github.com/supaleon/vanilla"
+
+// scaffold returns the files of a new project of the given module path, by
+// slash-separated path relative to its root.
+func scaffold(module string) map[string]string {
+	name := path.Base(module)
+	opts := options.Default()
+	gomod := "module " + module + "\n\ngo 1.24\n"
+	if v := vanillaVersion(); v != "" {
+		gomod += "\nrequire " + vanillaModule + " " + v + "\n"
+	}
+	return map[string]string{
+		"go.mod":               gomod,
+		".gitignore":           "/" + opts.BinDir + "/\n/gen/\n/node_modules/\n",
+		"package.json":         fmt.Sprintf("{\n  \"name\": %q,\n  \"private\": true,\n  \"type\": \"module\"\n}\n", name),
+		"vanilla.options.json": "{}\n",
+		"public/robots.txt":    "User-agent: *\nAllow: /\n",
+		"pages/Layout.html": `<script>
+</script>
+
+<html>
+<head>
+    <meta charset="utf-8">
+    <title>` + name + `</title>
+</head>
+<body>
+    <slot/>
+</body>
+</html>
+`,
+		"pages/Index.html": `<script>
+    let title = prop("Hello, Vanilla!")
+</script>
+
+<main>
+    <h1>{title}</h1>
+</main>
+`,
+		"main.go": `package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	_ "` + module + `/gen"
+	"` + vanillaModule + `"
+)
+
+func main() {
+	addr := ":8080"
+	if port := os.Getenv("PORT"); port != "" {
+		addr = ":" + port
+	}
+	log.Printf("listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, vanilla.NewRouter(vanilla.NewRenderer())))
+}
+`,
+	}
+}
+
+// vanillaVersion returns the version of the vanilla module of the running
+// binary, or "" for a development build.
+func vanillaVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, m := range append([]*debug.Module{&info.Main}, info.Deps...) {
+		if m.Path == vanillaModule && m.Version != "(devel)" {
+			return m.Version
+		}
+	}
+	return ""
+}
+
+// newProject writes a new project of the given module path into dir, which
+// must not exist or be empty. The default binDir directory of the artifacts is
+// created empty.
+func newProject(dir, module string) error {
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return usageError{dir + " is not empty"}
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if strings.Trim(module, "/") == "" || strings.ContainsAny(module, " \t\n\"\\") {
+		return usageError{"invalid module path " + fmt.Sprintf("%q", module)}
+	}
+	for name, content := range scaffold(module) {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	return os.MkdirAll(filepath.Join(dir, filepath.FromSlash(options.Default().BinDir)), 0o755)
+}
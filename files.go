@@ -0,0 +1,219 @@
+package vanilla
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AssetsPath is the URL path of the bundles of the scripts and stylesheets of
+// the components.
+const AssetsPath = "/assets/"
+
+var (
+	filesMu sync.RWMutex
+	files   fs.FS
+)
+
+// RegisterFiles makes the static files fsys of a project available to
+// [NewRouter]. Generated code registers the files embedded in the binary when
+// its package is initialized; see [FileServer] for their layout.
+func RegisterFiles(fsys fs.FS) {
+	filesMu.Lock()
+	defer filesMu.Unlock()
+	files = fsys
+}
+
+// Files returns the registered static files, or nil.
+func Files() fs.FS {
+	filesMu.RLock()
+	defer filesMu.RUnlock()
+	return files
+}
+
+// FileServer returns a handler serving the static files fsys for GET and HEAD
+// requests. The bundles are served on [AssetsPath] from the assets directory
+// of fsys, the other paths from its public directory.
+//
+// Responses carry the MIME type of the file extension and a strong ETag
+// derived from the hash of the content. Bundles whose names hold the hash of
+// their content, as with the assets.hash option, are cached by clients for a
+// year as they never change; other files are revalidated. If the client
+// accepts it, a file is served from its precompressed variant of the same
+// name with a .br or .gz extension, if any.
+func FileServer(fsys fs.FS) http.Handler {
+	return &fileServer{fsys: fsys}
+}
+
+type fileServer struct {
+	fsys  fs.FS
+	etags sync.Map // by file name
+}
+
+// encodings are the content encodings of the precompressed variants, by
+// order of preference.
+var encodings = []struct{ name, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+func (s *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	p := path.Clean("/" + r.URL.Path)
+	name, hashed := "public"+p, false
+	if strings.HasPrefix(p, AssetsPath) {
+		name, hashed = p[1:], contentHashed(p)
+	}
+	content, err := s.open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer content.Close()
+	etag, err := s.etag(name, content)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	h := w.Header()
+	ctype := mime.TypeByExtension(path.Ext(name))
+	if ctype != "" {
+		h.Set("Content-Type", ctype)
+		// Variants are only served with a known type, so that it is not
+		// sniffed from compressed content.
+		for _, enc := range encodings {
+			v, err := s.open(name + enc.ext)
+			if err != nil {
+				continue
+			}
+			defer v.Close()
+			if h.Get("Vary") == "" {
+				h.Set("Vary", "Accept-Encoding")
+			}
+			if h.Get("Content-Encoding") != "" || !acceptsEncoding(r.Header.Get("Accept-Encoding"), enc.name) {
+				continue
+			}
+			content = v
+			etag = etag[:len(etag)-1] + "-" + enc.name + `"`
+			h.Set("Content-Encoding", enc.name)
+		}
+	}
+	h.Set("ETag", etag)
+	if hashed {
+		h.Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		h.Set("Cache-Control", "no-cache")
+	}
+	http.ServeContent(w, r, name, time.Time{}, content)
+}
+
+// contentHashed reports whether the file name of the bundle p holds the hash
+// of its content, as esbuild names it: a stem ending in a dash and eight
+// base32 characters, aka PagesIndex-5GQK3TJW.js.
+func contentHashed(p string) bool {
+	stem, _, _ := strings.Cut(path.Base(p), ".")
+	i := strings.LastIndexByte(stem, '-')
+	if i < 0 || len(stem)-i-1 != 8 {
+		return false
+	}
+	for _, c := range stem[i+1:] {
+		if (c < 'A' || c > 'Z') && (c < '2' || c > '7') {
+			return false
+		}
+	}
+	return true
+}
+
+// seekFile is a file of a fs.FS which supports seeking.
+type seekFile interface {
+	fs.File
+	io.ReadSeeker
+}
+
+// open opens the regular file of the given name. Files which do not support
+// seeking are read into memory.
+func (s *fileServer) open(name string) (seekFile, error) {
+	if !fs.ValidPath(name) {
+		return nil, fs.ErrInvalid
+	}
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err == nil && !fi.Mode().IsRegular() {
+		err = fs.ErrNotExist
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if sf, ok := f.(seekFile); ok {
+		return sf, nil
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return memFile{bytes.NewReader(data), fi}, nil
+}
+
+// memFile is a file read into memory.
+type memFile struct {
+	*bytes.Reader
+	fi fs.FileInfo
+}
+
+func (f memFile) Stat() (fs.FileInfo, error) { return f.fi, nil }
+func (f memFile) Close() error               { return nil }
+
+// etag returns the quoted ETag of the file f of the given name, hashing its
+// content the first time.
+func (s *fileServer) etag(name string, f io.ReadSeeker) (string, error) {
+	if etag, ok := s.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := strconv.Quote(hex.EncodeToString(h.Sum(nil)[:16]))
+	s.etags.Store(name, etag)
+	return etag, nil
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header value accept
+// allows the content encoding enc, aka "gzip".
+func acceptsEncoding(accept, enc string) bool {
+	for _, item := range strings.Split(accept, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), enc) {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		v, err := strconv.ParseFloat(q, 64)
+		return err == nil && v > 0
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package vanilla
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFileServer(t *testing.T) {
+	fsys := fstest.MapFS{
+		"assets/Index-5GQK3TJW.js":    {Data: []byte("console.log(1)")},
+		"assets/Index-5GQK3TJW.js.br": {Data: []byte("br")},
+		"assets/Index-5GQK3TJW.js.gz": {Data: []byte("gz")},
+		"assets/About.css":            {Data: []byte("p{}")},
+		"public/robots.txt":           {Data: []byte("User-agent: *\n")},
+		"public/data":                 {Data: []byte("%PDF-1.4")},
+		"public/data.gz":              {Data: []byte("gz")},
+		"public/img/logo.svg":         {Data: []byte("<svg/>")},
+		"secret.txt":                  {Data: []byte("secret")},
+	}
+	h := FileServer(fsys)
+	tests := []struct {
+		method, path, accept string
+		code                 int
+		body, ctype, enc     string
+		cache, vary          string
+	}{
+		{"GET", "/assets/Index-5GQK3TJW.js", "", 200, "console.log(1)", "text/javascript; charset=utf-8", "", "public, max-age=31536000, immutable", "Accept-Encoding"},
+		{"GET", "/assets/Index-5GQK3TJW.js", "gzip, deflate, br", 200, "br", "text/javascript; charset=utf-8", "br", "public, max-age=31536000, immutable", "Accept-Encoding"},
+		{"GET", "/assets/Index-5GQK3TJW.js", "gzip;q=1, br;q=0", 200, "gz", "text/javascript; charset=utf-8", "gzip", "public, max-age=31536000, immutable", "Accept-Encoding"},
+		{"HEAD", "/assets/Index-5GQK3TJW.js", "", 200, "", "text/javascript; charset=utf-8", "", "public, max-age=31536000, immutable", "Accept-Encoding"},
+		{"GET", "/assets/About.css", "", 200, "p{}", "text/css; charset=utf-8", "", "no-cache", ""},
+		{"GET", "/robots.txt", "gzip", 200, "User-agent: *\n", "text/plain; charset=utf-8", "", "no-cache", ""},
+		{"GET", "/img/logo.svg", "", 200, "<svg/>", "image/svg+xml", "", "no-cache", ""},
+		{"GET", "/data", "gzip", 200, "%PDF-1.4", "application/pdf", "", "no-cache", ""},
+		{"GET", "/img", "", 404, "404 page not found\n", "text/plain; charset=utf-8", "", "", ""},
+		{"GET", "/../secret.txt", "", 404, "404 page not found\n", "text/plain; charset=utf-8", "", "", ""},
+		{"GET", "/assets/missing.js", "", 404, "404 page not found\n", "text/plain; charset=utf-8", "", "", ""},
+		{"POST", "/robots.txt", "", 405, "Method Not Allowed\n", "text/plain; charset=utf-8", "", "", ""},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		if test.accept != "" {
+			req.Header.Set("Accept-Encoding", test.accept)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		res := rec.Result()
+		got := []string{rec.Body.String(), res.Header.Get("Content-Type"), res.Header.Get("Content-Encoding"), res.Header.Get("Cache-Control"), res.Header.Get("Vary")}
+		want := []string{test.body, test.ctype, test.enc, test.cache, test.vary}
+		if res.StatusCode != test.code || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] || got[3] != want[3] || got[4] != want[4] {
+			t.Errorf("%s %s (Accept-Encoding %q):\ngot  %d %q\nwant %d %q", test.method, test.path, test.accept, res.StatusCode, got, test.code, want)
+		}
+	}
+
+	// ETags are strong, per encoding, and revalidate
+	etag := func(path, accept string) string {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Encoding", accept)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Header().Get("ETag")
+	}
+	plain, br := etag("/assets/Index-5GQK3TJW.js", ""), etag("/assets/Index-5GQK3TJW.js", "br")
+	if len(plain) != 34 || plain[0] != '"' || br != plain[:33]+`-br"` {
+		t.Errorf("got ETags %s and %s", plain, br)
+	}
+	req := httptest.NewRequest("GET", "/assets/Index-5GQK3TJW.js", nil)
+	req.Header.Set("If-None-Match", plain)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match: got status %d, want 304", rec.Code)
+	}
+	if etag("/robots.txt", "") == plain {
+		t.Error("got the same ETag for different content")
+	}
+}
+
+func TestContentHashed(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/assets/PagesIndex-5GQK3TJW.js", true},
+		{"/assets/PagesIndex-5GQK3TJW.js.map", true},
+		{"/assets/chunks/chunk-ABCDEFGH.js", true},
+		{"/assets/PagesIndex.js", false},
+		{"/assets/PagesIndex-5gqk3tjw.js", false},
+		{"/assets/PagesIndex-5GQK3TJ.js", false},
+		{"/assets/files/logo-ABCDEFG1.svg", false},
+	}
+	for _, test := range tests {
+		if got := contentHashed(test.path); got != test.want {
+			t.Errorf("contentHashed(%q) = %v; want %v", test.path, got, test.want)
+		}
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		accept, enc string
+		want        bool
+	}{
+		{"gzip, deflate, br", "br", true},
+		{"gzip, deflate, br", "gzip", true},
+		{"GZIP", "gzip", true},
+		{"deflate", "gzip", false},
+		{"br;q=0", "br", false},
+		{"br; q=0.5", "br", true},
+		{"", "gzip", false},
+	}
+	for _, test := range tests {
+		if got := acceptsEncoding(test.accept, test.enc); got != test.want {
+			t.Errorf("acceptsEncoding(%q, %q) = %v; want %v", test.accept, test.enc, got, test.want)
+		}
+	}
+}
@@ -3,10 +3,9 @@ module github.com/supaleon/vanilla
 go 1.24
 
 require (
-	github.com/evanw/esbuild v0.25.8 // indirect
-	github.com/julienschmidt/httprouter v1.3.0 // indirect
-	github.com/tdewolff/hasher v0.0.0-20210521220142-bc97f602bca2 // indirect
-	github.com/tdewolff/parse/v2 v2.8.1 // indirect
-	golang.org/x/net v0.42.0 // indirect
-	golang.org/x/text v0.27.0 // indirect
+	github.com/andybalholm/brotli v1.2.0
+	github.com/evanw/esbuild v0.25.8
+	github.com/julienschmidt/httprouter v1.3.0
+	github.com/tdewolff/parse/v2 v2.8.1
+	golang.org/x/sys v0.34.0
 )
@@ -1,15 +1,23 @@
 package ast
 
 import (
+	"slices"
+
 	"github.com/supaleon/vanilla/internal/token"
 )
 
+// Template is the template code block of a component.
+// Nodes holds every top-level node following the script code block;
+// a well-formed component has exactly one top-level element.
 type Template struct {
+	Nodes []Node
 }
 
 func (t *Template) Range() token.Range {
-	//todo
-	return token.Range{}
+	if len(t.Nodes) == 0 {
+		return token.Range{}
+	}
+	return token.Range{Start: t.Nodes[0].Range().Start, End: t.Nodes[len(t.Nodes)-1].Range().End}
 }
 
 type Component struct {
@@ -18,6 +26,25 @@ type Component struct {
 }
 
 func (c *Component) Range() token.Range {
-	//todo
-	return token.Range{}
+	r := c.Template.Range()
+	if c.ESModule != nil {
+		if r.End == token.NoLoc {
+			return c.ESModule.Range()
+		}
+		r.Start = c.ESModule.Range().Start
+	}
+	return r
+}
+
+// Slots returns the names of the <slot> elements of the template of c in
+// source order, without duplicates; "" names the default slot.
+func (c *Component) Slots() []string {
+	var names []string
+	Inspect(c.Template, func(n Node) bool {
+		if e, ok := n.(*Element); ok && e.Name == "slot" && !slices.Contains(names, e.SlotName()) {
+			names = append(names, e.SlotName())
+		}
+		return true
+	})
+	return names
 }
@@ -1,6 +1,8 @@
 package ast
 
 import (
+	"path"
+
 	"github.com/supaleon/vanilla/internal/token"
 )
 
@@ -13,14 +15,100 @@ const (
 	ImportDynamic
 )
 
+// ImportForm is the syntactic form of an import statement.
+type ImportForm int
+
+const (
+	// ImportBare aka `import "./Item.html"`, also known as effect import.
+	ImportBare ImportForm = iota
+	// ImportDefault aka `import Card from "./Card.html"`.
+	ImportDefault
+	// ImportNamed aka `import {User} from "./user.go"`.
+	ImportNamed
+)
+
+// FileKind is the kind of imported file, determined by its extension.
+type FileKind int
+
+const (
+	FileOther     FileKind = iota
+	FileComponent          // .html
+	FileGo                 // .go
+	FileCSS                // .css
+	FileJS                 // .js
+)
+
+// FileKindOf returns the kind of the file at the import path.
+func FileKindOf(importPath string) FileKind {
+	switch path.Ext(importPath) {
+	case ".html":
+		return FileComponent
+	case ".go":
+		return FileGo
+	case ".css":
+		return FileCSS
+	case ".js":
+		return FileJS
+	}
+	return FileOther
+}
+
 type ImportSpec struct {
-	Kind ImportKind
+	Kind    ImportKind
+	Form    ImportForm
+	File    FileKind
+	Path    string    // unquoted import path, aka `./Card.html`
+	PathLoc token.Loc // position of the quoted import path
+	Name    *Ident    // default binding, aka `Card` in `import Card from "./Card.html"`; or nil
+	Names   []*Ident  // named bindings, aka `User` in `import {User} from "./user.go"`
+}
+
+func (s *ImportSpec) Range() token.Range {
+	return token.Range{Start: s.PathLoc, End: s.PathLoc + token.Loc(len(s.Path)+2)}
+}
+
+// PropKind is the kind of initializer of a prop declaration.
+type PropKind int
+
+const (
+	PropGoType PropKind = iota // prop(User())
+	PropBool                   // prop(false)
+	PropInt                    // prop(1)
+	PropFloat                  // prop(0.8)
+	PropString                 // prop("dark")
+	PropArray                  // prop([])
+	PropObject                 // prop({})
+)
+
+// PropDecl is a component property declaration, aka `let user = prop(User())`.
+type PropDecl struct {
+	Name  *Ident
+	Kind  PropKind
+	Type  *Ident // Go type name for PropGoType, aka `User` in `prop(User())`; or nil
+	Value string // literal source for PropBool, PropInt, PropFloat and PropString, aka `"dark"`
+	End   token.Loc
+}
+
+func (d *PropDecl) Range() token.Range {
+	return token.Range{Start: d.Name.NameLoc, End: d.End}
 }
 
 type ESModule struct {
+	Script  *Element // the top-level <script> element
 	Imports []*ImportSpec
+	Props   []*PropDecl
 }
 
 func (e *ESModule) Range() token.Range {
-	return token.Range{}
+	return e.Script.Range()
+}
+
+// Prop returns the prop declaration of the given name, or nil.
+func (e *ESModule) Prop(name string) *PropDecl {
+	for _, d := range e.Props {
+		if d.Name.Name == name {
+			return d
+		}
+	}
+	return nil
 }
@@ -0,0 +1,171 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+// Expr is the interface of all expression nodes.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+type (
+	// BadExpr is a placeholder for an expression containing syntax errors.
+	BadExpr struct {
+		From, To token.Loc
+	}
+
+	// Ident is an identifier, e.g. `user`, or a loop variable name,
+	// e.g. `index` in `{for index, value in user.tags}`.
+	Ident struct {
+		NameLoc token.Loc
+		Name    string
+	}
+
+	// BasicLit is a literal of basic type, aka `42`, `0.8`, `"abc"`, `'c'`, `true` or `false`.
+	BasicLit struct {
+		ValueLoc token.Loc
+		Kind     token.Token // token.INT, token.FLOAT, token.STRING, token.CHAR, token.TRUE or token.FALSE
+		Value    string      // literal string; e.g. 42, 0x7f, 3.14, 1e-9, 'a', "foo"
+	}
+
+	// Paren is a parenthesized expression, aka `(a || b)`.
+	Paren struct {
+		LParen token.Loc
+		X      Expr
+		RParen token.Loc
+	}
+
+	// Selector is a property access, aka `user.name`.
+	Selector struct {
+		X   Expr
+		Sel *Ident
+	}
+
+	// Index is an index access, aka `user.tags[0]`.
+	Index struct {
+		X      Expr
+		LBrack token.Loc
+		Index  Expr
+		RBrack token.Loc
+	}
+
+	// Call is a call of a built-in function, aka `len(user.tags)`.
+	Call struct {
+		Fun    *Ident
+		LParen token.Loc
+		Args   []Expr
+		RParen token.Loc
+	}
+
+	// Unary is a unary expression, aka `!user.active` or `-1`.
+	Unary struct {
+		OpLoc token.Loc
+		Op    token.Token // token.NOT or token.SUB
+		X     Expr
+	}
+
+	// Binary is a logical or comparison expression, aka `user.likes > 0`.
+	Binary struct {
+		X     Expr
+		OpLoc token.Loc
+		Op    token.Token
+		Y     Expr
+	}
+
+	// Range is a numerical range of a {for} block, aka `1..9`.
+	Range struct {
+		Low   Expr
+		OpLoc token.Loc
+		High  Expr
+	}
+)
+
+func (x *BadExpr) Range() token.Range { return token.Range{Start: x.From, End: x.To} }
+func (x *Ident) Range() token.Range {
+	return token.Range{Start: x.NameLoc, End: x.NameLoc + token.Loc(len(x.Name))}
+}
+func (x *BasicLit) Range() token.Range {
+	return token.Range{Start: x.ValueLoc, End: x.ValueLoc + token.Loc(len(x.Value))}
+}
+func (x *Paren) Range() token.Range { return token.Range{Start: x.LParen, End: x.RParen + 1} }
+func (x *Selector) Range() token.Range {
+	return token.Range{Start: x.X.Range().Start, End: x.Sel.Range().End}
+}
+func (x *Index) Range() token.Range { return token.Range{Start: x.X.Range().Start, End: x.RBrack + 1} }
+func (x *Call) Range() token.Range  { return token.Range{Start: x.Fun.NameLoc, End: x.RParen + 1} }
+func (x *Unary) Range() token.Range { return token.Range{Start: x.OpLoc, End: x.X.Range().End} }
+func (x *Binary) Range() token.Range {
+	return token.Range{Start: x.X.Range().Start, End: x.Y.Range().End}
+}
+func (x *Range) Range() token.Range {
+	return token.Range{Start: x.Low.Range().Start, End: x.High.Range().End}
+}
+
+// exprNode() ensures that only expression nodes can be assigned to an Expr.
+func (*BadExpr) exprNode()  {}
+func (*Ident) exprNode()    {}
+func (*BasicLit) exprNode() {}
+func (*Paren) exprNode()    {}
+func (*Selector) exprNode() {}
+func (*Index) exprNode()    {}
+func (*Call) exprNode()     {}
+func (*Unary) exprNode()    {}
+func (*Binary) exprNode()   {}
+func (*Range) exprNode()    {}
+
+// ExprString returns the (possibly shortened) string representation for x,
+// e.g. `len(user.tags) > 0`. Bad expressions are written as `BadExpr`.
+func ExprString(x Expr) string {
+	var b strings.Builder
+	writeExpr(&b, x)
+	return b.String()
+}
+
+func writeExpr(b *strings.Builder, x Expr) {
+	switch x := x.(type) {
+	case *Ident:
+		b.WriteString(x.Name)
+	case *BasicLit:
+		b.WriteString(x.Value)
+	case *Paren:
+		b.WriteByte('(')
+		writeExpr(b, x.X)
+		b.WriteByte(')')
+	case *Selector:
+		writeExpr(b, x.X)
+		b.WriteByte('.')
+		b.WriteString(x.Sel.Name)
+	case *Index:
+		writeExpr(b, x.X)
+		b.WriteByte('[')
+		writeExpr(b, x.Index)
+		b.WriteByte(']')
+	case *Call:
+		b.WriteString(x.Fun.Name)
+		b.WriteByte('(')
+		for i, arg := range x.Args {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeExpr(b, arg)
+		}
+		b.WriteByte(')')
+	case *Unary:
+		b.WriteString(x.Op.String())
+		writeExpr(b, x.X)
+	case *Binary:
+		writeExpr(b, x.X)
+		b.WriteString(" " + x.Op.String() + " ")
+		writeExpr(b, x.Y)
+	case *Range:
+		writeExpr(b, x.Low)
+		b.WriteString("..")
+		writeExpr(b, x.High)
+	default:
+		b.WriteString("BadExpr")
+	}
+}
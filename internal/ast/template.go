@@ -0,0 +1,156 @@
+package ast
+
+import (
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+// Text is a run of plain text, including the raw content of tags like <style>.
+type Text struct {
+	Start token.Loc
+	Value string
+}
+
+func (t *Text) Range() token.Range {
+	return token.Range{Start: t.Start, End: t.Start + token.Loc(len(t.Value))}
+}
+
+// Comment is an HTML comment, aka `<!--x-->`.
+type Comment struct {
+	Start token.Loc
+	Value string // including `<!--` and `-->`
+}
+
+func (c *Comment) Range() token.Range {
+	return token.Range{Start: c.Start, End: c.Start + token.Loc(len(c.Value))}
+}
+
+// AttrKind is the kind of attribute value.
+type AttrKind uint8
+
+const (
+	// AttrStatic aka `class="dark"`, `id=main` or a boolean attribute like `hidden`.
+	AttrStatic AttrKind = iota
+	// AttrExpr aka `disabled={!user.active}`.
+	AttrExpr
+	// AttrInterp aka `class="dark {theme} {user.pro: pro}"`.
+	AttrInterp
+)
+
+// Attribute is an element attribute, aka `class="dark {theme}"`.
+type Attribute struct {
+	NameLoc token.Loc
+	Name    string
+	Kind    AttrKind
+	Quote   byte      // `'` or `"`; 0 for an unquoted value or no value at all
+	Value   []Node    // *Text parts and code blocks; nil for a boolean attribute.
+	End     token.Loc // position after the value, or the name for a boolean attribute.
+}
+
+func (a *Attribute) Range() token.Range {
+	return token.Range{Start: a.NameLoc, End: a.End}
+}
+
+// Element is an HTML element or a component element.
+type Element struct {
+	Start       token.Loc // position of `<`
+	Name        string
+	Attrs       []*Attribute
+	SelfClosing bool // `<br/>`
+	Children    []Node
+	End         token.Loc // position after `>` of the end tag, or the start tag if there is no end tag.
+}
+
+func (e *Element) Range() token.Range {
+	return token.Range{Start: e.Start, End: e.End}
+}
+
+// Attr returns the attribute of the given name of e, or nil.
+func (e *Element) Attr(name string) *Attribute {
+	for _, a := range e.Attrs {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// SlotName returns the name of the slot of a <slot> element, aka `footer` for
+// `<slot name="footer">`, or of the slot targeted by an element passed to a
+// component, aka `footer` for `<p slot="footer">`; "" for the default slot.
+func (e *Element) SlotName() string {
+	attr := "slot"
+	if e.Name == "slot" {
+		attr = "name"
+	}
+	if a := e.Attr(attr); a != nil && len(a.Value) == 1 {
+		if t, ok := a.Value[0].(*Text); ok {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+// Interpolation is an expression code block, aka `{user.name}`.
+type Interpolation struct {
+	LBrace token.Loc
+	X      Expr
+	RBrace token.Loc
+}
+
+func (i *Interpolation) Range() token.Range {
+	return token.Range{Start: i.LBrace, End: i.RBrace + 1}
+}
+
+// ConditionalText is a code block rendering Text if X is true, aka `{user.pro: Pro}`.
+type ConditionalText struct {
+	LBrace token.Loc
+	X      Expr
+	Colon  token.Loc
+	Text   string // text after the colon, leading whitespace removed.
+	RBrace token.Loc
+}
+
+func (c *ConditionalText) Range() token.Range {
+	return token.Range{Start: c.LBrace, End: c.RBrace + 1}
+}
+
+// FormatSpec is a code block rendering X in the given format, aka `{user.score %.2f}`.
+type FormatSpec struct {
+	LBrace  token.Loc
+	X       Expr
+	Percent token.Loc
+	Format  string // format after the percent sign, leading whitespace removed.
+	RBrace  token.Loc
+}
+
+func (f *FormatSpec) Range() token.Range {
+	return token.Range{Start: f.LBrace, End: f.RBrace + 1}
+}
+
+// IfBlock is a conditional block, aka `{if cond}...{else}...{/if}`.
+type IfBlock struct {
+	Start   token.Loc // position of `{` of `{if`
+	Cond    Expr
+	Then    []Node
+	ElseLoc token.Loc // position of `{` of `{else}`; or NoLoc
+	Else    []Node
+	End     token.Loc // position after `}` of `{/if}`
+}
+
+func (b *IfBlock) Range() token.Range {
+	return token.Range{Start: b.Start, End: b.End}
+}
+
+// ForBlock is a loop block, aka `{for index, value in user.tags}...{/for}`.
+type ForBlock struct {
+	Start token.Loc // position of `{` of `{for`
+	Key   *Ident
+	Value *Ident // nil for `{for i in x}`
+	X     Expr
+	Body  []Node
+	End   token.Loc // position after `}` of `{/for}`
+}
+
+func (b *ForBlock) Range() token.Range {
+	return token.Range{Start: b.Start, End: b.End}
+}
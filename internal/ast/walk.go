@@ -0,0 +1,73 @@
+package ast
+
+// Inspect traverses an AST in depth-first order: It starts by calling f(node);
+// node must not be nil. If f returns true, Inspect invokes f recursively for
+// each of the non-nil children of node.
+//
+// Attribute values and code block expressions are children of their element
+// and code block; the script code block is not traversed into.
+func Inspect(node Node, f func(Node) bool) {
+	if !f(node) {
+		return
+	}
+	switch n := node.(type) {
+	case *Component:
+		if n.ESModule != nil {
+			Inspect(n.ESModule, f)
+		}
+		Inspect(n.Template, f)
+	case *Template:
+		inspectList(n.Nodes, f)
+	case *Element:
+		for _, a := range n.Attrs {
+			Inspect(a, f)
+		}
+		inspectList(n.Children, f)
+	case *Attribute:
+		inspectList(n.Value, f)
+	case *Interpolation:
+		Inspect(n.X, f)
+	case *ConditionalText:
+		Inspect(n.X, f)
+	case *FormatSpec:
+		Inspect(n.X, f)
+	case *IfBlock:
+		Inspect(n.Cond, f)
+		inspectList(n.Then, f)
+		inspectList(n.Else, f)
+	case *ForBlock:
+		Inspect(n.Key, f)
+		if n.Value != nil {
+			Inspect(n.Value, f)
+		}
+		Inspect(n.X, f)
+		inspectList(n.Body, f)
+
+	// Expressions
+	case *Paren:
+		Inspect(n.X, f)
+	case *Selector:
+		Inspect(n.X, f)
+		Inspect(n.Sel, f)
+	case *Index:
+		Inspect(n.X, f)
+		Inspect(n.Index, f)
+	case *Call:
+		Inspect(n.Fun, f)
+		inspectList(n.Args, f)
+	case *Unary:
+		Inspect(n.X, f)
+	case *Binary:
+		Inspect(n.X, f)
+		Inspect(n.Y, f)
+	case *Range:
+		Inspect(n.Low, f)
+		Inspect(n.High, f)
+	}
+}
+
+func inspectList[N Node](list []N, f func(Node) bool) {
+	for _, n := range list {
+		Inspect(n, f)
+	}
+}
@@ -0,0 +1,82 @@
+package build
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// GoError reports a failure of the go command on a project, aka a compilation
+// error of its Go code.
+type GoError struct {
+	Args   []string // arguments of the go command
+	Output []byte   // standard error of the go command
+	Err    error
+}
+
+func (e *GoError) Error() string {
+	msg := "go " + strings.Join(e.Args, " ") + ": " + e.Err.Error()
+	if out := bytes.TrimSpace(e.Output); len(out) > 0 {
+		msg += "\n" + string(out)
+	}
+	return msg
+}
+
+func (e *GoError) Unwrap() error {
+	return e.Err
+}
+
+// Binary compiles the project of conf and builds its main package, at its
+// root, into a single executable embedding the bundles and static files. The
+// executable is written to the file output, relative to the root unless it is
+// absolute, or to the bin directory of the options after the last element of
+// the module path if output is ""; its path is returned along with the result
+// of the compilation.
+//
+// A failure of the go command is returned as a *GoError.
+func Binary(conf *Config, output string) (string, *Result, error) {
+	res, err := Compile(conf)
+	if err != nil {
+		return "", nil, err
+	}
+	if output == "" {
+		output = filepath.Join(filepath.FromSlash(conf.options().BinDir), path.Base(res.Module))
+		if runtime.GOOS == "windows" {
+			output += ".exe"
+		}
+	}
+	file := output
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(conf.Root, file)
+	}
+	if err := GoBuild(conf.Root, file); err != nil {
+		return "", nil, err
+	}
+	return output, res, nil
+}
+
+// GoBuild builds the main package at root, once compiled, into the executable
+// file output, relative to the current directory unless it is absolute. A
+// failure of the go command is returned as a *GoError.
+func GoBuild(root, output string) error {
+	file, err := filepath.Abs(output)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	args := []string{"build", "-trimpath", "-o", file, "."}
+	var stderr bytes.Buffer
+	cmd := exec.Command("go", args...)
+	cmd.Dir = root
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return &GoError{Args: args, Output: stderr.Bytes(), Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package build
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestBinary(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping go build in short mode")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOPROXY", "off")
+
+	root := writeProject(t, map[string]string{
+		"pages/Index.html":  "<script>\n</script>\n\n<p>home</p>",
+		"public/robots.txt": "User-agent: *\n",
+		"main.go": `package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+
+	_ "example.com/app/gen"
+	"github.com/supaleon/vanilla"
+)
+
+func main() {
+	h := vanilla.NewRouter(vanilla.NewRenderer())
+	for _, path := range []string{"/", "/robots.txt"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		fmt.Printf("%s %d %q\n", path, rec.Code, rec.Body.String())
+	}
+}
+`,
+	})
+	output, _, err := Binary(&Config{Root: root, Production: true}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join("bin", "app"); output != want && output != want+".exe" {
+		t.Errorf("got output %s, want %s", output, want)
+	}
+	out, err := exec.Command(filepath.Join(root, output)).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s: %v\n%s", output, err, out)
+	}
+	if want := "/ 200 \"<p>home</p>\"\n/robots.txt 200 \"User-agent: *\\n\"\n"; string(out) != want {
+		t.Errorf("%s: got\n%s\nwant\n%s", output, out, want)
+	}
+
+	// errors of the Go code are reported as such
+	if err := os.WriteFile(filepath.Join(root, "broken.go"), []byte("package main\n\nvar x int = \"\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = Binary(&Config{Root: root}, "")
+	var goErr *GoError
+	if !errors.As(err, &goErr) {
+		t.Errorf("got error %v, want a *GoError", err)
+	}
+}
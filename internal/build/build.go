@@ -1 +1,386 @@
+// Package build compiles Vanilla projects. It parses and checks the
+// components of the pages/ tree of a project, links them, derives their
+// routes and generates the Go package rendering them, which the project
+// imports for its side effects.
 package build
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/supaleon/vanilla/internal/build/cache"
+	"github.com/supaleon/vanilla/internal/checker"
+	"github.com/supaleon/vanilla/internal/codegen"
+	"github.com/supaleon/vanilla/internal/linker"
+	"github.com/supaleon/vanilla/internal/options"
+	"github.com/supaleon/vanilla/internal/parser"
+	"github.com/supaleon/vanilla/internal/router"
+	"github.com/supaleon/vanilla/internal/scanner"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+// Config configures the compilation of a project.
+type Config struct {
+	Root       string // project root, holding go.mod
+	Out        string // slash-separated directory of the generated package relative to Root; "gen" if empty
+	Production bool   // minify the bundles and precompress the static files
+
+	// Options holds the options of the project; the defaults if nil.
+	Options *options.Options
+
+	// Cache holds the generated code of the components of previous builds,
+	// so that only the components which changed and their dependents are
+	// checked and generated again; nil to compile every component.
+	Cache *cache.Cache
+}
+
+func (conf *Config) out() string {
+	if conf.Out == "" {
+		return "gen"
+	}
+	return conf.Out
+}
+
+func (conf *Config) options() *options.Options {
+	if conf.Options == nil {
+		return options.Default()
+	}
+	return conf.Options
+}
+
+// srcDir returns the directory holding the pages directory of the project at
+// root, to which the paths of the components are relative.
+func (conf *Config) srcDir(root string) string {
+	return filepath.Join(root, filepath.FromSlash(path.Dir(conf.options().PagesDir)))
+}
+
+// Result is the result of a successful compilation.
+type Result struct {
+	Module   string            // module path of the project, aka "example.com/app"
+	Package  string            // import path of the generated package, aka "example.com/app/gen"
+	Routes   *router.Table     // routes of the pages
+	Files    []string          // generated files relative to the root, sorted
+	Bundles  []string          // slash-separated files written by the bundler relative to the root, sorted
+	Compiled []string          // paths of the components generated rather than reused from the cache, sorted
+	Warnings checker.ErrorList // sorted by position
+}
+
+// generatedHeader starts the files generated by codegen.
+var generatedHeader = []byte("// Code generated by vanilla from ")
+
+// Compile compiles the components of the project into the generated package.
+// Generated files of components which no longer exist are removed. The
+// scripts and stylesheets of the pages and layouts are bundled into the
+// assets directory of the package, whose previous content is removed, and
+// embedded in the package along with a copy of the public/ directory. Static
+// files are precompressed for production. With a cache, the components whose
+// inputs did not change since a cached build are neither checked nor
+// generated again.
+//
+// Errors of the components are returned as a [scanner.ErrorList] if some
+// cannot be parsed, or a [checker.ErrorList] otherwise; in both cases nothing
+// is generated.
+func Compile(conf *Config) (*Result, error) {
+	root, err := filepath.Abs(conf.Root)
+	if err != nil {
+		return nil, err
+	}
+	module, err := modulePath(root)
+	if err != nil {
+		return nil, err
+	}
+	opts, src := conf.options(), conf.srcDir(root)
+	pr, err := parseProject(src)
+	if err != nil {
+		return nil, err
+	}
+	paths, fset, files, sources := pr.paths, pr.fset, pr.files, pr.sources
+
+	var list checker.ErrorList
+	for _, f := range files {
+		list = append(list, checker.CheckLayout(fset, f.Path, f.Component)...)
+	}
+	g, errs := linker.Link(fset, files)
+	list = append(list, errs...)
+	linked := errs.Err() == nil
+	// components of the same Go name would share their bundles and generated
+	// file, the last one silently replacing the others
+	if errs := codegen.CheckNames(paths); len(errs) > 0 {
+		list = append(list, errs...)
+		list.Sort()
+		return nil, list
+	}
+
+	out := conf.out()
+	b := &bundler{root: root, src: src, opts: opts, fset: fset, g: g, sources: sources}
+	assets, bundles, err := b.bundle(files, out, conf.Production)
+	if errors.As(err, &errs) {
+		list = append(list, errs...)
+		linked = false
+	} else if err != nil {
+		return nil, err
+	}
+
+	// components whose entry is cached are neither checked nor generated;
+	// the others are checked along with the components they use
+	keys := make(map[string]cache.Key)
+	cached := make(map[string]*entry)
+	if conf.Cache != nil && linked {
+		k, err := newKeyer(conf, root, out, paths, g, sources, assets)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if k == nil {
+				break
+			}
+			keys[f.Path] = k.key(f.Path)
+			if e := get(conf.Cache, keys[f.Path]); e != nil {
+				cached[f.Path] = e
+			}
+		}
+	}
+	checked := make(map[string]bool)
+	var queue []string
+	for _, f := range files {
+		if cached[f.Path] == nil {
+			checked[f.Path] = true
+			queue = append(queue, f.Path)
+		}
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, dep := range g.Dependencies(p) {
+			if !checked[dep] {
+				checked[dep] = true
+				queue = append(queue, dep)
+			}
+		}
+	}
+
+	warnings := make(map[string]checker.ErrorList) // of the checks of each compiled component
+	infos := make(map[string]*checker.Info)
+	loader := checker.NewGoLoader()
+	for _, f := range files {
+		if !checked[f.Path] {
+			continue
+		}
+		info, errs := checker.Check(fset, filepath.Join(src, filepath.FromSlash(f.Path)), f.Component, loader)
+		infos[f.Path] = info
+		if cached[f.Path] == nil {
+			list = append(list, errs...)
+			warnings[f.Path] = append(warnings[f.Path], errs...)
+		}
+	}
+	for _, e := range g.CheckProps(fset, infos) {
+		if cached[e.Pos.Filename] == nil {
+			list = append(list, e)
+			warnings[e.Pos.Filename] = append(warnings[e.Pos.Filename], e)
+		}
+	}
+	routes, errs := router.Build(src, paths, infos, loader)
+	list = append(list, errs...)
+	list.Sort()
+	if err := list.Err(); err != nil {
+		return nil, err
+	}
+
+	embed, err := embedFiles(root, opts.PublicDir, out, conf.Production)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{Module: module, Package: path.Join(module, out), Routes: routes, Bundles: bundles}
+	gen := &codegen.Config{Package: path.Base(out), Routes: routes, Assets: assets, BaseURL: opts.BaseURL}
+	srcs := make(map[string][]byte)
+	if embed != nil {
+		srcs[path.Join(out, embedFile)] = embed
+	}
+	generated := make(map[string][]byte)
+	for _, f := range files {
+		name := path.Join(out, strings.ToLower(codegen.Name(f.Path))+".go")
+		if e := cached[f.Path]; e != nil {
+			srcs[name] = e.Source
+			list = append(list, e.Warnings...)
+			continue
+		}
+		src, err := gen.Generate(fset, g, infos, f)
+		var errs checker.ErrorList
+		switch {
+		case errors.As(err, &errs):
+			list = append(list, errs...)
+		case err != nil:
+			return nil, err
+		default:
+			srcs[name] = src
+			generated[f.Path] = src
+			res.Compiled = append(res.Compiled, f.Path)
+		}
+	}
+	list.Sort()
+	if err := list.Err(); err != nil {
+		return nil, err
+	}
+	res.Warnings = list
+	if err := writeFiles(root, out, srcs); err != nil {
+		return nil, err
+	}
+	for name := range srcs {
+		res.Files = append(res.Files, name)
+	}
+	slices.Sort(res.Files)
+	if conf.Cache != nil {
+		for p, src := range generated {
+			if key, ok := keys[p]; ok {
+				put(conf.Cache, key, &entry{Source: src, Warnings: warnings[p]})
+			}
+		}
+	}
+	return res, nil
+}
+
+// project holds the parsed components of a project.
+type project struct {
+	paths   []string // sorted
+	fset    *token.FileSet
+	files   []*linker.File    // in the order of paths
+	sources map[string][]byte // by path
+}
+
+// parseProject parses the components of the pages/ tree of dir. Errors of the
+// components are returned as a [scanner.ErrorList].
+func parseProject(dir string) (*project, error) {
+	paths, err := componentPaths(dir)
+	if err != nil {
+		return nil, err
+	}
+	pr := &project{paths: paths, fset: token.NewFileSet(), sources: make(map[string][]byte, len(paths))}
+	var list scanner.ErrorList
+	for _, p := range paths {
+		src, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(p)))
+		if err != nil {
+			return nil, err
+		}
+		pr.sources[p] = src
+		c, err := parser.ParseFile(pr.fset, p, src)
+		var errs scanner.ErrorList
+		switch {
+		case errors.As(err, &errs):
+			list = append(list, errs...)
+		case err != nil:
+			return nil, err
+		default:
+			pr.files = append(pr.files, &linker.File{Path: p, Component: c})
+		}
+	}
+	if len(list) > 0 {
+		list.Sort()
+		return nil, list
+	}
+	return pr, nil
+}
+
+// Check parses and checks the components of the project of conf, as
+// [Compile] does, without bundling nor generating anything. It returns the
+// warnings of the checks, or their errors as [Compile] does.
+func Check(conf *Config) (checker.ErrorList, error) {
+	root, err := filepath.Abs(conf.Root)
+	if err != nil {
+		return nil, err
+	}
+	src := conf.srcDir(root)
+	pr, err := parseProject(src)
+	if err != nil {
+		return nil, err
+	}
+	var list checker.ErrorList
+	infos := make(map[string]*checker.Info)
+	loader := checker.NewGoLoader()
+	for _, f := range pr.files {
+		list = append(list, checker.CheckLayout(pr.fset, f.Path, f.Component)...)
+		info, errs := checker.Check(pr.fset, filepath.Join(src, filepath.FromSlash(f.Path)), f.Component, loader)
+		list = append(list, errs...)
+		infos[f.Path] = info
+	}
+	g, errs := linker.Link(pr.fset, pr.files)
+	list = append(list, errs...)
+	list = append(list, g.CheckProps(pr.fset, infos)...)
+	_, errs = router.Build(src, pr.paths, infos, loader)
+	list = append(list, errs...)
+	list = append(list, codegen.CheckNames(pr.paths)...)
+	list.Sort()
+	if err := list.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// componentPaths returns the slash-separated paths of the component files of
+// the pages/ tree of dir relative to dir, sorted.
+func componentPaths(dir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(filepath.Join(dir, "pages"), func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(name) != ".html" {
+			return err
+		}
+		rel, err := filepath.Rel(dir, name)
+		paths = append(paths, filepath.ToSlash(rel))
+		return err
+	})
+	return paths, err
+}
+
+// writeFiles writes the generated files srcs by path relative to root, and
+// removes the other generated files of the directory out.
+func writeFiles(root, out string, srcs map[string][]byte) error {
+	dir := filepath.Join(root, filepath.FromSlash(out))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := path.Join(out, e.Name())
+		if _, ok := srcs[name]; ok || e.IsDir() || filepath.Ext(name) != ".go" {
+			continue
+		}
+		file := filepath.Join(dir, e.Name())
+		if data, err := os.ReadFile(file); err == nil && bytes.HasPrefix(data, generatedHeader) {
+			if err := os.Remove(file); err != nil {
+				return err
+			}
+		}
+	}
+	for name, src := range srcs {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if old, err := os.ReadFile(file); err == nil && bytes.Equal(old, src) {
+			continue // keep the modification time for the go command
+		}
+		if err := os.WriteFile(file, src, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// modulePath returns the module path declared by the go.mod file of root.
+func modulePath(root string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return "", err
+	}
+	for line := range strings.Lines(string(data)) {
+		if mod, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+			return strings.Trim(strings.TrimSpace(mod), `"`), nil
+		}
+	}
+	return "", errors.New(filepath.Join(root, "go.mod") + ": no module declaration")
+}
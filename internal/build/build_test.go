@@ -0,0 +1,66 @@
+package build
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/supaleon/vanilla/internal/checker"
+	"github.com/supaleon/vanilla/internal/scanner"
+)
+
+func TestCheck(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"pages/Index.html": `<script>
+    import Card from "./Card.html"
+</script>
+
+<Card>unused</Card>`,
+		"pages/Card.html":        "<script>\n</script>\n\n<p>card</p>",
+		"pages/about/Index.html": "<script>\n</script>\n\n<p>{missing}</p>",
+	})
+	conf := &Config{Root: root}
+	_, err := Check(conf)
+	var errs checker.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Pos.Filename != "pages/Index.html" || errs[1].Pos.Filename != "pages/about/Index.html" {
+		t.Fatalf("got %v, want a warning of pages/Index.html and an error of pages/about/Index.html", err)
+	}
+
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("pages/about/Index.html", "<script>\n</script>\n\n<p>about</p>")
+	warnings, err := Check(conf)
+	if err != nil || len(warnings) != 1 || warnings[0].Code != checker.UnusedContent {
+		t.Errorf("got %v, %v, want a warning", warnings, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "gen")); !os.IsNotExist(err) {
+		t.Errorf("Check generated files: %v", err)
+	}
+
+	write("pages/about/Index.html", "<script>\n</script>\n\n<p>{</p>")
+	var parseErrs scanner.ErrorList
+	if _, err := Check(conf); !errors.As(err, &parseErrs) {
+		t.Errorf("got %v, want a scanner.ErrorList", err)
+	}
+}
+
+func TestCompileDuplicateNames(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"pages/Index.html":     "<script>\n</script>\n\n<p>home</p>",
+		"pages/BlogCard.html":  "<script>\n</script>\n\n<p>blog card</p>",
+		"pages/blog/Card.html": "<script>\n</script>\n\n<p>card of the blog</p>",
+	})
+	_, err := Compile(&Config{Root: root})
+	var errs checker.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Code != checker.DuplicateName || errs[0].Pos.Filename != "pages/blog/Card.html" {
+		t.Fatalf("got %v, want a duplicate name error of pages/blog/Card.html", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "gen")); !os.IsNotExist(err) {
+		t.Errorf("Compile generated files: %v", err)
+	}
+}
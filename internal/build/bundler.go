@@ -1 +1,368 @@
 package build
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/supaleon/vanilla"
+	"github.com/supaleon/vanilla/internal/ast"
+	"github.com/supaleon/vanilla/internal/checker"
+	"github.com/supaleon/vanilla/internal/codegen"
+	"github.com/supaleon/vanilla/internal/linker"
+	"github.com/supaleon/vanilla/internal/options"
+	"github.com/supaleon/vanilla/internal/router"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+// NB: every page and layout is an entry point of esbuild, so that the
+// Renderer can compose the bundles of a page and of its layouts at runtime.
+// The module of a component is the text of its script code block, loaded by a
+// plugin, followed by bare imports of the components it uses; with code
+// splitting, the modules shared by several entry points go to chunks.
+
+// assetsDir is the directory of the bundles in the generated package.
+const assetsDir = "assets"
+
+// bundler bundles the scripts and stylesheets of the components of a project.
+type bundler struct {
+	root    string // project root
+	src     string // directory of the pages directory, see [Config.srcDir]
+	opts    *options.Options
+	fset    *token.FileSet
+	g       *linker.Graph
+	sources map[string][]byte  // by component path
+	scripts map[string]*Source // by component path, split by [bundler.bundle]
+}
+
+// bundle writes the bundles of the pages and layouts of files into the
+// directory out/assets of root, replacing its previous content, and returns
+// their URLs by component path along with the written files relative to root.
+// The scripts and stylesheets are minified if production is set and the
+// options allow it. Without hashing, the URLs of production bundles are
+// versioned by a query of the hash of their content, while the URLs of others
+// are stable, so that the generated code does not change with them. Errors
+// are returned as a [checker.ErrorList] positioned in the components if
+// possible.
+func (b *bundler) bundle(files []*linker.File, out string, production bool) (map[string]*codegen.Assets, []string, error) {
+	var entries []api.EntryPoint
+	for _, f := range files {
+		if path.Base(f.Path) == checker.LayoutFile || router.IsPage(f.Path) {
+			entries = append(entries, api.EntryPoint{InputPath: f.Path, OutputPath: codegen.Name(f.Path)})
+		}
+	}
+	dir := filepath.Join(b.root, filepath.FromSlash(out), assetsDir)
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil, nil
+	}
+	b.scripts = make(map[string]*Source, len(b.sources))
+	for p, src := range b.sources {
+		script, _, err := preprocess(p, src)
+		if err != nil {
+			return nil, nil, err
+		}
+		b.scripts[p] = script
+	}
+
+	entryNames, sourcemap := "[name]", api.SourceMapNone
+	if b.opts.Assets.Hash {
+		entryNames = "[name]-[hash]"
+	}
+	if b.opts.Assets.SourceMaps {
+		sourcemap = api.SourceMapLinked
+	}
+	minify := production && b.opts.Assets.Minify
+	publicPath := b.opts.BaseURL + strings.TrimPrefix(vanilla.AssetsPath, "/")
+	plugins := []api.Plugin{{Name: "vanilla", Setup: b.setup}}
+	if b.opts.Tailwind.Enabled {
+		plugins = append(plugins, api.Plugin{Name: "tailwind", Setup: b.tailwind(minify)})
+	}
+	res := api.Build(api.BuildOptions{
+		AbsWorkingDir:       b.src,
+		EntryPointsAdvanced: entries,
+		Outdir:              dir,
+		EntryNames:          entryNames,
+		ChunkNames:          "chunks/[name]-[hash]",
+		AssetNames:          "files/[name]-[hash]",
+		PublicPath:          publicPath,
+		Bundle:              true,
+		Splitting:           true,
+		Format:              api.FormatESModule,
+		Platform:            api.PlatformBrowser,
+		Sourcemap:           sourcemap,
+		MinifyWhitespace:    minify,
+		MinifyIdentifiers:   minify,
+		MinifySyntax:        minify,
+		Loader: map[string]api.Loader{
+			".png": api.LoaderFile, ".jpg": api.LoaderFile, ".jpeg": api.LoaderFile,
+			".gif": api.LoaderFile, ".webp": api.LoaderFile, ".svg": api.LoaderFile,
+			".woff": api.LoaderFile, ".woff2": api.LoaderFile, ".ttf": api.LoaderFile,
+		},
+		Plugins:  plugins,
+		Metafile: true,
+		Write:    true,
+		LogLevel: api.LogLevelSilent,
+	})
+	if len(res.Errors) > 0 {
+		return nil, nil, b.errors(res.Errors)
+	}
+
+	var meta struct {
+		Outputs map[string]struct {
+			EntryPoint string `json:"entryPoint"`
+			CSSBundle  string `json:"cssBundle"`
+			Imports    []any  `json:"imports"`
+			Inputs     map[string]struct {
+				BytesInOutput int `json:"bytesInOutput"`
+			} `json:"inputs"`
+		} `json:"outputs"`
+	}
+	if err := json.Unmarshal([]byte(res.Metafile), &meta); err != nil {
+		return nil, nil, err
+	}
+	hashes := make(map[string]string) // of the written files by path
+	for _, f := range res.OutputFiles {
+		hashes[f.Path] = f.Hash
+	}
+	url := func(file string) string {
+		file = filepath.Join(b.src, filepath.FromSlash(file))
+		rel, _ := filepath.Rel(dir, file)
+		u := publicPath + filepath.ToSlash(rel)
+		if !b.opts.Assets.Hash && production {
+			u += "?v=" + hashes[file]
+		}
+		return u
+	}
+	assets := make(map[string]*codegen.Assets)
+	empty := make(map[string]bool) // entry point scripts without code
+	for file, o := range meta.Outputs {
+		if o.EntryPoint == "" {
+			continue
+		}
+		a := &codegen.Assets{}
+		size := 0
+		for _, in := range o.Inputs {
+			size += in.BytesInOutput
+		}
+		if size > 0 || len(o.Imports) > 0 {
+			a.Scripts = []string{url(file)}
+		} else {
+			file = filepath.Join(b.src, filepath.FromSlash(file))
+			empty[file], empty[file+".map"] = true, true
+		}
+		if o.CSSBundle != "" {
+			a.Styles = []string{url(o.CSSBundle)}
+		}
+		if a.Scripts != nil || a.Styles != nil {
+			assets[o.EntryPoint] = a
+		}
+	}
+	var written []string
+	for _, f := range res.OutputFiles {
+		if empty[f.Path] {
+			if err := os.Remove(f.Path); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		rel, err := filepath.Rel(b.root, f.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		written = append(written, filepath.ToSlash(rel))
+	}
+	slices.Sort(written)
+	return assets, written, nil
+}
+
+// setup registers the loader of the component modules.
+func (b *bundler) setup(build api.PluginBuild) {
+	build.OnLoad(api.OnLoadOptions{Filter: `\.html$`, Namespace: "file"}, func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+		rel, err := filepath.Rel(b.src, args.Path)
+		if err != nil {
+			return api.OnLoadResult{}, err
+		}
+		f := b.g.File(filepath.ToSlash(rel))
+		if f == nil {
+			return api.OnLoadResult{}, fmt.Errorf("%s is not a component of the project", rel)
+		}
+		src := b.module(f)
+		if b.opts.Assets.SourceMaps {
+			src += b.sourceMap(f)
+		}
+		return api.OnLoadResult{Contents: &src, ResolveDir: filepath.Dir(args.Path), Loader: api.LoaderJS}, nil
+	})
+}
+
+// module returns the ES module of the component f: the text of its script
+// code block, see [preprocess], followed by bare imports of the components it
+// uses. Prop declarations and the imports of Go types and components are
+// blanked, keeping the lines and columns of the text, which the script maps
+// back to f.
+func (b *bundler) module(f *linker.File) string {
+	script := b.scripts[f.Path]
+	buf := bytes.Clone(script.Text)
+	if m := f.Component.ESModule; m != nil && len(buf) > 0 {
+		file := b.fset.File(m.Script.Start)
+		start := script.Offset(0)
+		off := func(loc token.Loc) int { return file.Offset(loc) - start }
+		for _, spec := range m.Imports {
+			if spec.Kind == ast.ImportSTMT && (spec.File == ast.FileGo || spec.File == ast.FileComponent) {
+				blankImport(buf, off(spec.PathLoc), len(spec.Path)+2)
+			}
+		}
+		for _, d := range m.Props {
+			blankProp(buf, off(d.Name.NameLoc), off(d.End))
+		}
+	}
+
+	for _, dep := range b.g.Dependencies(f.Path) {
+		rel, err := filepath.Rel(path.Dir(f.Path), dep)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if !strings.HasPrefix(rel, "../") {
+			rel = "./" + rel
+		}
+		buf = fmt.Appendf(buf, "\nimport %q;", rel)
+	}
+	return string(buf)
+}
+
+// sourceMap returns the comment of the inline source map of the module of the
+// component f, see [bundler.module], which maps the start of each word of its
+// script back to f, so that the source maps of the bundles point into f.
+func (b *bundler) sourceMap(f *linker.File) string {
+	script := b.scripts[f.Path]
+	var mappings []byte
+	var line, col int           // in f of the previous mapping
+	genCol, prevGenCol := 0, -1 // in the line of the module, of the previous mapping of the line
+	for i, c := range script.Text {
+		switch {
+		case c == '\n':
+			mappings = append(mappings, ';')
+			genCol, prevGenCol = 0, -1
+			continue
+		case !isSpace(c) && (i == 0 || isSpace(script.Text[i-1])):
+			if prevGenCol >= 0 {
+				mappings = append(mappings, ',')
+			}
+			pos := script.Position(i)
+			mappings = appendVLQ(mappings, genCol-max(prevGenCol, 0))
+			mappings = appendVLQ(mappings, 0) // the only source
+			mappings = appendVLQ(mappings, pos.Line-1-line)
+			mappings = appendVLQ(mappings, pos.Column-1-col)
+			line, col, prevGenCol = pos.Line-1, pos.Column-1, genCol
+		}
+		genCol++
+	}
+	data, _ := json.Marshal(map[string]any{
+		"version":        3,
+		"sources":        []string{path.Base(f.Path)},
+		"sourcesContent": []string{string(b.sources[f.Path])},
+		"mappings":       string(mappings),
+	})
+	return "\n//# sourceMappingURL=data:application/json;base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+const base64VLQ = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// appendVLQ appends n to b as a base64 VLQ of the mappings of source maps.
+func appendVLQ(b []byte, n int) []byte {
+	v := n << 1
+	if n < 0 {
+		v = -n<<1 | 1
+	}
+	for {
+		digit := v & 31
+		v >>= 5
+		if v > 0 {
+			digit |= 32
+		}
+		b = append(b, base64VLQ[digit])
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+// blankImport blanks the import statement of buf whose quoted path of length
+// n is at offset off.
+func blankImport(buf []byte, off, n int) {
+	i := strings.LastIndex(string(buf[:off]), "import")
+	if i < 0 {
+		return
+	}
+	end := off + n
+	if end < len(buf) && buf[end] == ';' {
+		end++
+	}
+	blank(buf[i:end])
+}
+
+// blankProp blanks the prop declaration of buf between the offsets of its
+// name and of the end of its prop macro call, along with the separating
+// comma of a declaration list, or else the let keyword.
+func blankProp(buf []byte, start, end int) {
+	j := end
+	for j < len(buf) && isSpace(buf[j]) {
+		j++
+	}
+	if j < len(buf) && buf[j] == ',' {
+		end = j + 1
+	} else {
+		i := start
+		for i > 0 && isSpace(buf[i-1]) {
+			i--
+		}
+		switch {
+		case i > 0 && buf[i-1] == ',':
+			start = i - 1
+		case i >= 3 && string(buf[i-3:i]) == "let":
+			start = i - 3
+		}
+	}
+	blank(buf[start:end])
+}
+
+// blank replaces the bytes of b but newlines by spaces.
+func blank(b []byte) {
+	for i, c := range b {
+		if c != '\n' {
+			b[i] = ' '
+		}
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// errors returns the errors of esbuild as a [checker.ErrorList], positioned
+// in the components for the errors of their modules.
+func (b *bundler) errors(msgs []api.Message) checker.ErrorList {
+	var list checker.ErrorList
+	for _, m := range msgs {
+		var pos token.Position
+		if l := m.Location; l != nil {
+			pos = token.Position{Filename: filepath.ToSlash(l.File), Line: l.Line, Column: l.Column + 1}
+			if script := b.scripts[pos.Filename]; script != nil {
+				pos = script.Translate(pos) // from the module of a component
+			}
+		}
+		list.Add(pos, checker.BundleFailed, m.Text)
+	}
+	list.Sort()
+	return list
+}
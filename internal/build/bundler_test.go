@@ -0,0 +1,254 @@
+package build
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/supaleon/vanilla/internal/checker"
+	"github.com/supaleon/vanilla/internal/linker"
+	"github.com/supaleon/vanilla/internal/options"
+	"github.com/supaleon/vanilla/internal/parser"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+func TestModule(t *testing.T) {
+	sources := map[string][]byte{
+		"pages/Index.html": []byte(`<script>
+    import {User} from "./user.go"
+    import Card from "./Card.html"
+    import "./style.css"
+    let user = prop(User()), n = prop(1)
+    let a = 1, theme = prop("dark")
+    console.log("hi", a)
+</script>
+
+<div><Card></Card></div>`),
+		"pages/Card.html": []byte("<script>\n</script>\n\n<p>card</p>"),
+	}
+	fset := token.NewFileSet()
+	var files []*linker.File
+	for _, p := range []string{"pages/Card.html", "pages/Index.html"} {
+		c, err := parser.ParseFile(fset, p, sources[p])
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, &linker.File{Path: p, Component: c})
+	}
+	g, errs := linker.Link(fset, files)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	scripts := make(map[string]*Source)
+	for p, src := range sources {
+		script, _, err := preprocess(p, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scripts[p] = script
+	}
+	b := &bundler{fset: fset, g: g, sources: sources, scripts: scripts}
+
+	var got []string
+	for line := range strings.Lines(b.module(files[1])) {
+		got = append(got, strings.TrimRight(line, " \n"))
+	}
+	want := []string{
+		"",
+		"",
+		"",
+		`    import "./style.css"`,
+		"",
+		`    let a = 1`,
+		`    console.log("hi", a)`,
+		"",
+		`import "./Card.html";`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("module:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"pages/global.css": "body {\n  margin: 0;\n}\n",
+		"pages/Layout.html": `<script>
+    import "./global.css"
+    document.documentElement.dataset.ready = "1"
+</script>
+
+<html><head></head><body><slot/></body></html>`,
+		"pages/Card.html": `<script>
+    export function card() {
+        return "card"
+    }
+    console.log(card())
+</script>
+
+<p>card</p>`,
+		"pages/Index.html": `<script>
+    import Card from "./Card.html"
+    console.log("index")
+</script>
+
+<div><Card></Card></div>`,
+		"pages/about/Index.html": `<script>
+    import Card from "../Card.html"
+</script>
+
+<div><Card></Card></div>`,
+		"pages/plain/Index.html": "<script layout=\"none\">\n</script>\n\n<p>plain</p>",
+	})
+	res, err := Compile(&Config{Root: root, Production: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	bundle := func(prefix, ext string) string {
+		t.Helper()
+		i := slices.IndexFunc(res.Bundles, func(name string) bool {
+			return strings.HasPrefix(name, "gen/assets/"+prefix) && strings.HasSuffix(name, ext)
+		})
+		if i < 0 {
+			t.Fatalf("no bundle %s*%s in %q", prefix, ext, res.Bundles)
+		}
+		return res.Bundles[i]
+	}
+	url := func(name string) string {
+		return "/assets/" + strings.TrimPrefix(name, "gen/assets/")
+	}
+
+	index, about, chunk := bundle("Index-", ".js"), bundle("AboutIndex-", ".js"), bundle("chunks/", ".js")
+	layout, css := bundle("Layout-", ".js"), bundle("Layout-", ".css")
+	for name, want := range map[string]string{
+		"gen/index.go":      `Scripts: []string{"` + url(index) + `"},`,
+		"gen/aboutindex.go": `Scripts: []string{"` + url(about) + `"},`,
+		"gen/layout.go":     `Scripts: []string{"` + url(layout) + `"},` + "\n\t\tStyles:  []string{\"" + url(css) + `"},`,
+		index:               `import"/assets/chunks/`,
+		chunk:               `console.log(`,
+		css:                 "body{margin:0}",
+		index + ".map":      `"../../pages/Index.html"`,
+		"gen/plainindex.go": "Layout: \"\",\n\t\tRender:",
+	} {
+		if got := read(name); !strings.Contains(got, want) {
+			t.Errorf("%s: got\n%s\nwant it to contain %q", name, got, want)
+		}
+	}
+	if got := read(index); strings.Contains(got, "\n  ") {
+		t.Errorf("%s is not minified:\n%s", index, got)
+	}
+
+	var sourcemap struct {
+		Sources        []string `json:"sources"`
+		SourcesContent []string `json:"sourcesContent"`
+	}
+	if err := json.Unmarshal([]byte(read(chunk+".map")), &sourcemap); err != nil {
+		t.Fatal(err)
+	}
+	if i := slices.Index(sourcemap.Sources, "../../../pages/Card.html"); i < 0 {
+		t.Errorf("%s.map: got sources %q, want %q", chunk, sourcemap.Sources, "../../../pages/Card.html")
+	} else if got := sourcemap.SourcesContent[i]; got != read("pages/Card.html") {
+		t.Errorf("%s.map: got content of Card.html\n%s\nwant the component", chunk, got)
+	}
+
+	// errors of esbuild are positioned in the components
+	for _, test := range []struct{ src, want string }{
+		{"<script>\n    import \"./missing.js\"\n</script>\n\n<div></div>", "pages/Index.html:2:12"},
+		{"<script data-x=\"a>b\">import \"./missing.js\"\n</script>\n\n<div></div>", "pages/Index.html:1:29"},
+	} {
+		if err := os.WriteFile(filepath.Join(root, "pages", "Index.html"), []byte(test.src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err = Compile(&Config{Root: root})
+		var list checker.ErrorList
+		if !errors.As(err, &list) {
+			t.Fatalf("got error %v, want a checker.ErrorList", err)
+		}
+		if got, want := list.Error(), test.want+`: Could not resolve "./missing.js" [VB001]`; got != want {
+			t.Errorf("got error %q, want %q", got, want)
+		}
+	}
+}
+
+func TestBundleOptions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake tailwindcss command is a shell script")
+	}
+	root := writeProject(t, map[string]string{
+		"src/pages/app.css": "@tailwind utilities;\n",
+		"src/pages/Layout.html": `<script>
+    import "./app.css"
+</script>
+
+<html><head></head><body><slot/></body></html>`,
+		"src/pages/post/[id=int]/Index.html": `<script>
+    console.log("post")
+</script>
+
+<p>post</p>`,
+		// writes a rule per flag to the file of -o
+		"node_modules/.bin/tailwindcss": `#!/bin/sh
+css=".p-4{padding:1rem}"
+while [ $# -gt 0 ]; do
+    case $1 in
+    -o) out=$2; shift ;;
+    --minify) css="$css.minified{color:red}" ;;
+    esac
+    shift
+done
+echo "$css" > "$out"
+`,
+	})
+	if err := os.Chmod(filepath.Join(root, "node_modules", ".bin", "tailwindcss"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	opts := options.Default()
+	opts.PagesDir = "src/pages"
+	opts.BaseURL = "/docs/"
+	opts.Assets = options.Assets{Hash: false, Minify: true, SourceMaps: false}
+	opts.Tailwind = options.Tailwind{Enabled: true, Input: "src/pages/app.css"}
+	res, err := Compile(&Config{Root: root, Production: true, Options: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"gen/assets/Layout.css", "gen/assets/PostIdIndex.js"}; !slices.Equal(res.Bundles, want) {
+		t.Errorf("got bundles %q, want %q", res.Bundles, want)
+	}
+
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	for name, want := range map[string]string{
+		"gen/layout.go":         `Styles: []string{"/docs/assets/Layout.css?v=`,
+		"gen/postidindex.go":    `Scripts: []string{"/docs/assets/PostIdIndex.js?v=`,
+		"gen/assets/Layout.css": ".p-4{padding:1rem}.minified{color:red}",
+	} {
+		if got := read(name); !strings.Contains(got, want) {
+			t.Errorf("%s: got\n%s\nwant it to contain %q", name, got, want)
+		}
+	}
+	if got := read("gen/postidindex.go"); !strings.Contains(got, `return "/docs/post/" + strconv.Itoa(id)`) {
+		t.Errorf("gen/postidindex.go: got\n%s\nwant the URL to be prefixed with /docs", got)
+	}
+	if _, err := os.Stat(filepath.Join(root, "gen", "assets", "PostIdIndex.js.map")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got source map with sourceMaps false: %v", err)
+	}
+}
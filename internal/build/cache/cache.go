@@ -1 +1,189 @@
+// Package cache implements the persistent build cache of Vanilla projects. It
+// is a content-addressed store: each entry is keyed by the hash of every input
+// of its output, so that an entry never goes stale, it is only no longer
+// looked up once an input changes.
+//
+// A cache directory may be shared by concurrent builds, of the same project
+// or not. Entries are written to a temporary file renamed into place, and
+// checked against their checksum when read.
 package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Key identifies an entry: it is the hash of the inputs of its output.
+type Key [sha256.Size]byte
+
+func (k Key) String() string {
+	return hex.EncodeToString(k[:])
+}
+
+// A Hash computes the [Key] of a list of inputs.
+type Hash struct {
+	h hash.Hash
+}
+
+// NewHash returns a Hash of no inputs.
+func NewHash() *Hash {
+	return &Hash{h: sha256.New()}
+}
+
+// Add adds the input of the given name and content. Inputs are delimited, so
+// that the key of "a", "bc" is not the key of "ab", "c".
+func (h *Hash) Add(name string, data []byte) {
+	var n [binary.MaxVarintLen64]byte
+	h.h.Write(n[:binary.PutUvarint(n[:], uint64(len(name)))])
+	h.h.Write([]byte(name))
+	h.h.Write(n[:binary.PutUvarint(n[:], uint64(len(data)))])
+	h.h.Write(data)
+}
+
+// AddKey adds the key of another list of inputs, aka of a dependency.
+func (h *Hash) AddKey(name string, k Key) {
+	h.Add(name, k[:])
+}
+
+// Sum returns the key of the inputs added so far.
+func (h *Hash) Sum() Key {
+	var k Key
+	h.h.Sum(k[:0])
+	return k
+}
+
+// ErrMiss is returned by [Cache.Get] when there is no valid entry of a key.
+var ErrMiss = errors.New("cache miss")
+
+// Cache is a cache directory.
+type Cache struct {
+	dir string
+}
+
+// Open opens the cache directory dir, creating it if needed.
+func Open(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// DefaultDir returns the default cache directory, $VANILLA_CACHE if set or
+// else the vanilla directory of the user cache directory.
+func DefaultDir() (string, error) {
+	if dir := os.Getenv("VANILLA_CACHE"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "vanilla"), nil
+}
+
+// Dir returns the directory of c.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// file returns the file of the entry of k, in a subdirectory named after the
+// first byte of k so that directories stay small.
+func (c *Cache) file(k Key) string {
+	s := k.String()
+	return filepath.Join(c.dir, s[:2], s[2:])
+}
+
+// Get returns the output of the entry of k, or [ErrMiss] if there is none or
+// if it is corrupted.
+func (c *Cache) Get(k Key) ([]byte, error) {
+	data, err := os.ReadFile(c.file(k))
+	if os.IsNotExist(err) {
+		return nil, ErrMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < sha256.Size {
+		return nil, ErrMiss
+	}
+	sum, out := data[:sha256.Size], data[sha256.Size:]
+	if check := sha256.Sum256(out); !bytes.Equal(sum, check[:]) {
+		return nil, ErrMiss
+	}
+	return out, nil
+}
+
+// Put stores out as the output of the entry of k.
+func (c *Cache) Put(k Key, out []byte) error {
+	name := c.file(k)
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(out)
+	_, err = f.Write(append(sum[:], out...))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Clean removes every entry of c. Only the files written by [Cache.Put] and
+// the shard directories left empty are removed, so that cleaning a cache
+// opened on a directory holding other files, aka $VANILLA_CACHE=$HOME, keeps
+// them.
+func (c *Cache) Clean() error {
+	shards, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	for _, shard := range shards {
+		if !shard.IsDir() || !isHex(shard.Name(), 2) {
+			continue
+		}
+		dir := filepath.Join(c.dir, shard.Name())
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.Type().IsRegular() && (isHex(e.Name(), 2*sha256.Size-2) || strings.HasPrefix(e.Name(), ".tmp-")) {
+				if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+					return err
+				}
+			}
+		}
+		os.Remove(dir) // fails if other files are left
+	}
+	return nil
+}
+
+// isHex reports whether s is made of n lower-case hexadecimal digits, as the
+// names of the shards and the entries of a cache.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := range len(s) {
+		if !('0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func key(inputs ...string) Key {
+	h := NewHash()
+	for i := 0; i+1 < len(inputs); i += 2 {
+		h.Add(inputs[i], []byte(inputs[i+1]))
+	}
+	return h.Sum()
+}
+
+func TestHash(t *testing.T) {
+	if key("a", "bc") == key("ab", "c") || key("a", "b", "c", "") == key("a", "bc") {
+		t.Error("inputs are not delimited")
+	}
+	if key("a", "b") != key("a", "b") {
+		t.Error("key is not deterministic")
+	}
+	h := NewHash()
+	h.AddKey("dep", key("a", "b"))
+	if h.Sum() == key("a", "b") {
+		t.Error("AddKey does not add")
+	}
+}
+
+func TestCache(t *testing.T) {
+	c, err := Open(filepath.Join(t.TempDir(), "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := key("Index.html", "<p>hi</p>")
+	if _, err := c.Get(k); !errors.Is(err, ErrMiss) {
+		t.Fatalf("Get of a missing entry: got %v, want ErrMiss", err)
+	}
+	if err := c.Put(k, []byte("out")); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := c.Get(k); err != nil || string(out) != "out" {
+		t.Fatalf("Get: got %q, %v", out, err)
+	}
+	if err := c.Put(k, nil); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := c.Get(k); err != nil || len(out) != 0 {
+		t.Fatalf("Get of an empty output: got %q, %v", out, err)
+	}
+
+	// corrupted entries are misses
+	name := c.file(k)
+	if err := os.WriteFile(name, []byte("garbage garbage garbage garbage garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(k); !errors.Is(err, ErrMiss) {
+		t.Errorf("Get of a corrupted entry: got %v, want ErrMiss", err)
+	}
+	if err := os.WriteFile(name, []byte("short"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(k); !errors.Is(err, ErrMiss) {
+		t.Errorf("Get of a truncated entry: got %v, want ErrMiss", err)
+	}
+
+	// files of another use of the directory are kept
+	shard := "ab" // named like a shard but for the entry of k
+	if k.String()[:2] == shard {
+		shard = "cd"
+	}
+	others := []string{"notes.txt", filepath.Join(shard, "notes.txt"), filepath.Join("docs", "a.md")}
+	for _, name := range others {
+		name = filepath.Join(c.Dir(), name)
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte("keep"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Clean(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range others {
+		if _, err := os.Stat(filepath.Join(c.Dir(), name)); err != nil {
+			t.Errorf("Clean removed %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Dir(c.file(k))); !os.IsNotExist(err) {
+		t.Errorf("Clean left the shard of the entry: %v", err)
+	}
+	if _, err := c.Get(k); !errors.Is(err, ErrMiss) {
+		t.Errorf("Get after Clean: got %v, want ErrMiss", err)
+	}
+	if _, err := os.Stat(c.Dir()); err != nil {
+		t.Errorf("Clean removed the cache directory: %v", err)
+	}
+}
+
+func TestConcurrentPut(t *testing.T) {
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := bytes.Repeat([]byte("generated "), 1<<12)
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			k := key("file", fmt.Sprint(i%4))
+			if err := c.Put(k, out); err != nil {
+				t.Error(err)
+			}
+			if got, err := c.Get(k); err != nil || !bytes.Equal(got, out) {
+				t.Errorf("Get: got %d bytes, %v", len(got), err)
+			}
+		}()
+	}
+	wg.Wait()
+	entries, err := filepath.Glob(filepath.Join(c.Dir(), "*", ".tmp-*"))
+	if err != nil || len(entries) > 0 {
+		t.Errorf("temporary files left: %q, %v", entries, err)
+	}
+}
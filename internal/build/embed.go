@@ -1 +1,123 @@
 package build
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"go/format"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	embedFile = "vanilla_embed.go" // file of the generated package embedding the static files
+	publicDir = "public"           // directory of the files of public/ in the generated package
+)
+
+// compressible lists the extensions of the files which get precompressed
+// variants; the others are compressed already, aka images and fonts.
+var compressible = map[string]bool{
+	".css": true, ".html": true, ".js": true, ".json": true, ".map": true,
+	".mjs": true, ".svg": true, ".txt": true, ".wasm": true, ".xml": true,
+}
+
+// minCompressSize is the size under which compressing a file does not pay.
+const minCompressSize = 1024
+
+// embedFiles mirrors the directory of static files src of root, aka public,
+// into the directory public of the generated package out, writes the
+// precompressed variants of the static files of the package if compress is
+// set, and returns the source of the file of the package embedding them, which
+// registers them with [vanilla.RegisterFiles]. It returns nil if there are no
+// static files.
+func embedFiles(root, src, out string, compress bool) ([]byte, error) {
+	dir := filepath.Join(root, filepath.FromSlash(out))
+	public := filepath.Join(dir, publicDir)
+	if err := os.RemoveAll(public); err != nil {
+		return nil, err
+	}
+	if _, err := copyDir(filepath.Join(root, filepath.FromSlash(src)), public); err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	for _, name := range []string{assetsDir, publicDir} {
+		n := 0
+		err := filepath.WalkDir(filepath.Join(dir, name), func(name string, d fs.DirEntry, err error) error {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			if err != nil || d.IsDir() {
+				return err
+			}
+			n++
+			if compress && compressible[filepath.Ext(name)] {
+				return writeVariants(name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			patterns = append(patterns, "all:"+name)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by vanilla from %s/ and %s/. DO NOT EDIT.\n\n", path.Join(out, assetsDir), src)
+	fmt.Fprintf(&buf, "package %s\n\nimport (\n\t\"embed\"\n\n\t\"github.com/supaleon/vanilla\"\n)\n\n", path.Base(out))
+	buf.WriteString("//go:embed")
+	for _, p := range patterns {
+		buf.WriteString(" " + p)
+	}
+	buf.WriteString("\nvar files embed.FS\n\nfunc init() {\n\tvanilla.RegisterFiles(files)\n}\n")
+	return format.Source(buf.Bytes())
+}
+
+// writeVariants writes the gzip and brotli variants of the file of the given
+// name next to it, with a .gz and a .br extension, unless the file is too
+// small or a variant would not be smaller than 90% of it.
+func writeVariants(name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil || len(data) < minCompressSize {
+		return err
+	}
+	variants := []struct {
+		ext string
+		new func(io.Writer) io.WriteCloser
+	}{
+		{".gz", func(w io.Writer) io.WriteCloser {
+			zw, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+			return zw
+		}},
+		{".br", func(w io.Writer) io.WriteCloser {
+			return brotli.NewWriterLevel(w, brotli.BestCompression)
+		}},
+	}
+	for _, v := range variants {
+		var buf bytes.Buffer
+		zw := v.new(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		if buf.Len() > len(data)*9/10 {
+			continue
+		}
+		if err := os.WriteFile(name+v.ext, buf.Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package build
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestEmbed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping go run in short mode")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOPROXY", "off")
+
+	robots := strings.Repeat("User-agent: *\nDisallow: /private\n", 64)
+	root := writeProject(t, map[string]string{
+		"pages/Index.html": `<script>
+    console.log("index")
+</script>
+
+<html><head></head><body></body></html>`,
+		"public/robots.txt":      robots,
+		"public/.well-known/x":   "x",
+		"public/img/favicon.ico": "ico",
+		"main.go": `package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+
+	_ "example.com/app/gen"
+	"github.com/supaleon/vanilla"
+)
+
+func main() {
+	h := vanilla.NewRouter(vanilla.NewRenderer())
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Encoding", "br")
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	page := get("/").Body.String()
+	_, script, _ := strings.Cut(page, "<script type=\"module\" src=\"")
+	script, _, _ = strings.Cut(script, "\"")
+	fmt.Println("/", strings.HasPrefix(script, "/assets/Index-"))
+	for _, path := range []string{script, "/robots.txt", "/.well-known/x", "/img/favicon.ico", "/missing"} {
+		rec := get(path)
+		if path == script {
+			path = "script"
+		}
+		fmt.Println(path, rec.Code, rec.Header().Get("Content-Encoding"), rec.Header().Get("Cache-Control"), rec.Body.Len())
+	}
+}
+`,
+	})
+	res, err := Compile(&Config{Root: root, Production: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.ReadFile(filepath.Join(root, "gen", embedFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "//go:embed all:assets all:public\nvar files embed.FS\n"; !bytes.Contains(src, []byte(want)) {
+		t.Errorf("%s:\n%s\nwant it to contain %q", embedFile, src, want)
+	}
+
+	// precompressed variants
+	public := filepath.Join(root, "gen", "public")
+	for ext, reader := range map[string]func(io.Reader) (io.Reader, error){
+		".gz": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		".br": func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+	} {
+		f, err := os.Open(filepath.Join(public, "robots.txt"+ext))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		r, err := reader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		f.Close()
+		if err != nil || string(data) != robots {
+			t.Errorf("robots.txt%s: got %q, %v", ext, data, err)
+		}
+	}
+	for _, name := range []string{"img/favicon.ico.gz", ".well-known/x.gz"} {
+		if _, err := os.Stat(filepath.Join(public, filepath.FromSlash(name))); !os.IsNotExist(err) {
+			t.Errorf("%s: got %v, want no variant", name, err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = root
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run: %v\n%s", err, out)
+	}
+	br, err := os.Stat(filepath.Join(public, "robots.txt.br"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := os.Stat(filepath.Join(root, filepath.FromSlash(res.Bundles[0])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf(`/ true
+script 200  public, max-age=31536000, immutable %d
+/robots.txt 200 br no-cache %d
+/.well-known/x 200  no-cache 1
+/img/favicon.ico 200  no-cache 3
+/missing 404   19
+`, js.Size(), br.Size())
+	if string(out) != want {
+		t.Errorf("go run: got\n%s\nwant\n%s", out, want)
+	}
+
+	// without static files, the embedding file is removed
+	if err := os.RemoveAll(filepath.Join(root, "public")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "pages", "Index.html"), []byte("<script>\n</script>\n\n<p>index</p>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Compile(&Config{Root: root}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "gen", embedFile)); !os.IsNotExist(err) {
+		t.Errorf("%s: got %v, want it removed", embedFile, err)
+	}
+}
@@ -0,0 +1,187 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/supaleon/vanilla/internal/build/cache"
+	"github.com/supaleon/vanilla/internal/checker"
+	"github.com/supaleon/vanilla/internal/codegen"
+	"github.com/supaleon/vanilla/internal/linker"
+)
+
+// NB: the cache entry of a component holds its generated code and the
+// warnings of its checks, keyed by the hash of every input of both:
+//
+//   - the version of the compiler and the options of the build;
+//   - the paths of the components, which decide the routes and layouts;
+//   - the .go files of the project along with go.mod and go.sum, which
+//     declare the Go types of the props and the loaders;
+//   - the source of the component and the URLs of its bundles, which are
+//     named after the hash of its scripts and stylesheets;
+//   - the keys of the components it uses, since their props are checked
+//     against its attributes.
+//
+// A change to a component thus misses the entries of the component and of
+// its dependents only, which are checked along with their dependencies; the
+// others are reused as is.
+
+// entry is the cached output of a component.
+type entry struct {
+	Source   []byte            // generated Go source
+	Warnings checker.ErrorList // warnings of the checks of the component
+}
+
+// keyer computes the cache keys of the components of a build.
+type keyer struct {
+	base     cache.Key // inputs shared by the components
+	g        *linker.Graph
+	sources  map[string][]byte
+	assets   map[string]*codegen.Assets
+	keys     map[string]cache.Key
+	visiting map[string]bool // components whose key is being computed
+}
+
+// newKeyer returns a keyer of the components of g of the given sources and
+// bundles, for a build of the project at root generating the package out.
+// It returns nil if the version of the compiler is unknown.
+func newKeyer(conf *Config, root, out string, paths []string, g *linker.Graph, sources map[string][]byte, assets map[string]*codegen.Assets) (*keyer, error) {
+	version := compilerVersion()
+	if version == "" {
+		return nil, nil
+	}
+	h := cache.NewHash()
+	h.Add("version", []byte(version))
+	h.Add("out", []byte(out))
+	h.Add("production", []byte(strconv.FormatBool(conf.Production)))
+	opts, _ := json.Marshal(conf.options())
+	h.Add("options", opts)
+	h.Add("paths", []byte(strings.Join(paths, "\n")))
+	if err := hashGoFiles(h, root, out); err != nil {
+		return nil, err
+	}
+	k := &keyer{
+		base:     h.Sum(),
+		g:        g,
+		sources:  sources,
+		assets:   assets,
+		keys:     make(map[string]cache.Key),
+		visiting: make(map[string]bool),
+	}
+	return k, nil
+}
+
+// key returns the cache key of the component at p.
+func (k *keyer) key(p string) cache.Key {
+	if key, ok := k.keys[p]; ok {
+		return key
+	}
+	h := cache.NewHash()
+	h.AddKey("base", k.base)
+	h.Add("path", []byte(p))
+	h.Add("source", k.sources[p])
+	if a := k.assets[p]; a != nil {
+		data, _ := json.Marshal(a)
+		h.Add("assets", data)
+	}
+	k.visiting[p] = true
+	for _, dep := range k.g.Dependencies(p) {
+		if k.visiting[dep] {
+			continue // import cycles are reported by the linker
+		}
+		h.AddKey(dep, k.key(dep))
+	}
+	delete(k.visiting, p)
+	key := h.Sum()
+	k.keys[p] = key
+	return key
+}
+
+// get returns the cached entry of key, or nil.
+func get(c *cache.Cache, key cache.Key) *entry {
+	data, err := c.Get(key)
+	if err != nil {
+		return nil
+	}
+	e := new(entry)
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil
+	}
+	return e
+}
+
+// put caches e under key. Failures are ignored: the entry is recomputed by
+// the next build.
+func put(c *cache.Cache, key cache.Key, e *entry) {
+	if data, err := json.Marshal(e); err == nil {
+		c.Put(key, data)
+	}
+}
+
+// hashGoFiles adds the .go files of the project at root to h, along with its
+// go.mod and go.sum files, leaving out the generated package out and the
+// directories ignored by the go command.
+func hashGoFiles(h *cache.Hash, root, out string) error {
+	gen := filepath.Join(root, filepath.FromSlash(out))
+	return filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		base := d.Name()
+		if d.IsDir() {
+			if name != root && (name == gen || base == "testdata" || base == "node_modules" ||
+				strings.HasPrefix(base, ".") || strings.HasPrefix(base, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(base) != ".go" && name != filepath.Join(root, "go.mod") && name != filepath.Join(root, "go.sum") {
+			return nil
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, name)
+		h.Add(filepath.ToSlash(rel), data)
+		return nil
+	})
+}
+
+// compilerVersion returns the version of the vanilla module built into the
+// running binary along with the version of Go, or the hash of the binary for
+// a development build; "" if unknown.
+var compilerVersion = sync.OnceValue(func() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		mods := append([]*debug.Module{&info.Main}, info.Deps...)
+		for _, m := range mods {
+			if m.Path == "github.com/supaleon/vanilla" && m.Version != "" && m.Version != "(devel)" {
+				return m.Version + " " + m.Sum + " " + runtime.Version()
+			}
+		}
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return "devel " + hex.EncodeToString(h.Sum(nil))
+})
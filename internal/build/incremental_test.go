@@ -0,0 +1,103 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/supaleon/vanilla/internal/build/cache"
+)
+
+func TestIncremental(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"pages/Index.html": `<script>
+    import Card from "./Card.html"
+</script>
+
+<Card title="home">unused</Card>`,
+		"pages/Card.html":        "<script>\n    let title = prop(\"\")\n</script>\n\n<h2>{title}</h2>",
+		"pages/about/Index.html": "<script>\n</script>\n\n<p>about</p>",
+	})
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &Config{Root: root, Cache: c}
+	compile := func() *Result {
+		t.Helper()
+		res, err := Compile(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	read := func(res *Result) map[string]string {
+		t.Helper()
+		srcs := make(map[string]string)
+		for _, name := range res.Files {
+			data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			srcs[name] = string(data)
+		}
+		return srcs
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res := compile()
+	all := []string{"pages/Card.html", "pages/Index.html", "pages/about/Index.html"}
+	if !reflect.DeepEqual(res.Compiled, all) {
+		t.Errorf("first build compiled %q, want %q", res.Compiled, all)
+	}
+	if len(res.Warnings) != 1 {
+		t.Fatalf("first build: got warnings %v, want 1", res.Warnings)
+	}
+	first := read(res)
+
+	res = compile()
+	if res.Compiled != nil {
+		t.Errorf("unchanged build compiled %q, want none", res.Compiled)
+	}
+	if got := read(res); !reflect.DeepEqual(got, first) {
+		t.Errorf("unchanged build generated\n%v\nwant\n%v", got, first)
+	}
+	if len(res.Warnings) != 1 {
+		t.Errorf("unchanged build: got warnings %v, want 1", res.Warnings)
+	}
+
+	// a change to Card recompiles Card and its dependent
+	write("pages/Card.html", "<script>\n    let title = prop(\"\")\n</script>\n\n<h3>{title}</h3>")
+	res = compile()
+	if want := all[:2]; !reflect.DeepEqual(res.Compiled, want) {
+		t.Errorf("build after a change to Card compiled %q, want %q", res.Compiled, want)
+	}
+
+	// a change to a Go file recompiles everything
+	write("types.go", "package app\n")
+	if res = compile(); !reflect.DeepEqual(res.Compiled, all) {
+		t.Errorf("build after a change to a Go file compiled %q, want %q", res.Compiled, all)
+	}
+
+	// errors are reported even if the other components are cached
+	write("pages/about/Index.html", "<script>\n</script>\n\n<p>{missing}</p>")
+	if _, err := Compile(conf); err == nil {
+		t.Error("build of an invalid component succeeded")
+	}
+	write("pages/about/Index.html", "<script>\n</script>\n\n<p>about</p>")
+	if res = compile(); res.Compiled != nil {
+		t.Errorf("build after reverting compiled %q, want none", res.Compiled)
+	}
+
+	// without a cache, everything is compiled
+	conf.Cache = nil
+	if res = compile(); !reflect.DeepEqual(res.Compiled, all) {
+		t.Errorf("build without a cache compiled %q, want %q", res.Compiled, all)
+	}
+}
@@ -1,3 +1,174 @@
 package build
 
-// preprocess the HTML file to a script & template.
+import (
+	"bytes"
+	"cmp"
+	"slices"
+
+	"github.com/supaleon/vanilla/internal/scanner"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+// NB: preprocessing splits a component file into the body of its leading
+// <script> element, an ES module for esbuild, and the rest of the file, its
+// template. Each part is a Source which maps its offsets back to the file, so
+// that the errors reported on a part are positioned in the component. The
+// parser still reads whole files, as the positions of the AST are offsets in
+// the file.
+
+// A Source is a part of a component file.
+type Source struct {
+	Text  []byte
+	file  *lineTable
+	spans []span // sorted by offset in Text
+}
+
+// span is a run of bytes of a Source copied from its file.
+type span struct {
+	off     int // offset in Text
+	fileOff int // offset in the file
+	len     int
+}
+
+// lineTable holds the line offsets of a file.
+type lineTable struct {
+	filename string
+	lines    []int // offsets of the first byte of each line
+}
+
+func newLineTable(filename string, src []byte) *lineTable {
+	t := &lineTable{filename: filename, lines: []int{0}}
+	for i, c := range src {
+		if c == '\n' {
+			t.lines = append(t.lines, i+1)
+		}
+	}
+	return t
+}
+
+// position returns the position of the byte at offset off.
+func (t *lineTable) position(off int) token.Position {
+	i, found := slices.BinarySearch(t.lines, off)
+	if !found {
+		i-- // the line holding off
+	}
+	return token.Position{Filename: t.filename, Offset: off, Line: i + 1, Column: off - t.lines[i] + 1}
+}
+
+// Offset returns the offset in the file of the byte at offset off of the
+// Text of s. An offset at the end of a span, aka len(s.Text), maps to the end
+// of the span in the file.
+func (s *Source) Offset(off int) int {
+	// the first span ending after off
+	i, _ := slices.BinarySearchFunc(s.spans, off+1, func(sp span, end int) int {
+		return cmp.Compare(sp.off+sp.len, end)
+	})
+	if i == len(s.spans) {
+		if i == 0 {
+			return off
+		}
+		i--
+	}
+	sp := s.spans[i]
+	return sp.fileOff + min(max(off-sp.off, 0), sp.len)
+}
+
+// Position returns the position in the file of the byte at offset off of the
+// Text of s.
+func (s *Source) Position(off int) token.Position {
+	return s.file.position(s.Offset(off))
+}
+
+// Translate returns the position in the file of pos, a position in the Text
+// of s. The offset of pos is used if it has one, else its line and column,
+// aka for the messages of esbuild which have no offset.
+func (s *Source) Translate(pos token.Position) token.Position {
+	off := pos.Offset
+	if off <= 0 && pos.Line > 0 {
+		off = 0
+		for line := 1; line < pos.Line; line++ {
+			i := bytes.IndexByte(s.Text[off:], '\n')
+			if i < 0 {
+				off = len(s.Text)
+				break
+			}
+			off += i + 1
+		}
+		off = min(off+max(pos.Column-1, 0), len(s.Text))
+	}
+	return s.Position(off)
+}
+
+// add appends the bytes of the file src at offsets [start, end) to s.
+func (s *Source) add(src []byte, start, end int) {
+	if start == end {
+		return
+	}
+	s.spans = append(s.spans, span{off: len(s.Text), fileOff: start, len: end - start})
+	s.Text = append(s.Text, src[start:end]...)
+}
+
+// preprocess splits the component file src of the given name into the body
+// of its leading <script> element and its template, the rest of the file. If
+// the file does not begin with a <script> element, the script is empty and
+// the template is the whole file. The script is expected to be followed by a
+// </script> end tag; an error is returned as a [scanner.ErrorList] if it is
+// not.
+func preprocess(filename string, src []byte) (script, template *Source, err error) {
+	t := newLineTable(filename, src)
+	script, template = &Source{file: t}, &Source{file: t}
+
+	start := 0
+	for start < len(src) && isSpace(src[start]) {
+		start++
+	}
+	body := openTagEnd(src, start, "script")
+	if body < 0 {
+		template.add(src, 0, len(src))
+		return script, template, nil
+	}
+	end := bytes.Index(src[body:], []byte("</script"))
+	if end < 0 {
+		var errs scanner.ErrorList
+		errs.Add(t.position(start), "<script> element not terminated")
+		return nil, nil, errs
+	}
+	end += body
+	close := bytes.IndexByte(src[end:], '>')
+	if close < 0 {
+		close = len(src)
+	} else {
+		close += end + 1
+	}
+	script.add(src, body, end)
+	template.add(src, 0, start)
+	template.add(src, close, len(src))
+	return script, template, nil
+}
+
+// openTagEnd returns the offset following the start tag of the given name
+// at offset off of src, or -1 if there is none. Quoted attribute values may
+// hold '>'.
+func openTagEnd(src []byte, off int, name string) int {
+	if !bytes.HasPrefix(src[off:], []byte("<"+name)) {
+		return -1
+	}
+	i := off + 1 + len(name)
+	if i < len(src) && !isSpace(src[i]) && src[i] != '>' && src[i] != '/' {
+		return -1 // aka <scripts>
+	}
+	var quote byte
+	for ; i < len(src); i++ {
+		switch c := src[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i + 1
+		}
+	}
+	return -1
+}
@@ -1,7 +1,75 @@
 package build
 
-import "testing"
+import (
+	"strings"
+	"testing"
+
+	"github.com/supaleon/vanilla/internal/token"
+)
 
 func TestProcess(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		script   string
+		template string
+		err      string
+	}{
+		{"script and template", "<script>\n    let a = 1\n</script>\n\n<p>{a}</p>", "\n    let a = 1\n", "\n\n<p>{a}</p>", ""},
+		{"leading space", "\n  <script lang=\"ts\">x</script><p/>", "x", "\n  <p/>", ""},
+		{"quoted >", "<script data-x=\"a>b\">y</script>", "y", "", ""},
+		{"empty script", "<script></script>", "", "", ""},
+		{"no script", "<p>hi</p>\n<script>x</script>", "", "<p>hi</p>\n<script>x</script>", ""},
+		{"not a script tag", "<scripts>x</scripts>", "", "<scripts>x</scripts>", ""},
+		{"unterminated", "\n<script>\nlet a = 1\n", "", "", "Index.html:2:1: <script> element not terminated"},
+	}
+	for _, test := range tests {
+		script, template, err := preprocess("Index.html", []byte(test.src))
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if string(script.Text) != test.script || string(template.Text) != test.template {
+			t.Errorf("%s: got script %q and template %q, want %q and %q", test.name, script.Text, template.Text, test.script, test.template)
+		}
+		// every byte maps back to itself
+		for _, s := range []*Source{script, template} {
+			for i, c := range s.Text {
+				if off := s.Offset(i); test.src[off] != c {
+					t.Errorf("%s: Offset(%d) = %d holds %q, want %q", test.name, i, off, test.src[off], c)
+				}
+			}
+		}
+	}
+}
 
+func TestSourcePosition(t *testing.T) {
+	src := "<script>\n    let a = b\n</script>\n\n<p>{c}</p>\n"
+	script, template, err := preprocess("pages/Index.html", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		got  token.Position
+		want string
+	}{
+		{"script b", script.Position(strings.Index(string(script.Text), "b")), "pages/Index.html:2:13"},
+		{"template c", template.Position(strings.Index(string(template.Text), "c")), "pages/Index.html:5:5"},
+		{"template end", template.Position(len(template.Text)), "pages/Index.html:6:1"},
+		// positions without an offset, aka of esbuild messages
+		{"script line 2 column 13", script.Translate(token.Position{Line: 2, Column: 13}), "pages/Index.html:2:13"},
+		{"template offset", template.Translate(token.Position{Offset: 6, Line: 3, Column: 5}), "pages/Index.html:5:5"},
+	}
+	for _, test := range tests {
+		if got := test.got.String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
 }
@@ -1 +1,181 @@
 package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/supaleon/vanilla"
+)
+
+// ManifestFile is the name of the manifest written by [Static] in its output
+// directory.
+const ManifestFile = "manifest.json"
+
+// Manifest lists the files of a static site.
+type Manifest struct {
+	Pages    []*vanilla.StaticPage `json:"pages"`              // rendered pages
+	Assets   []string              `json:"assets"`             // slash-separated files of the bundles and of public/
+	Failures []*vanilla.StaticPage `json:"failures,omitempty"` // pages which could not be rendered
+}
+
+// StaticError reports the pages of a static site which could not be
+// rendered. The other pages are written nonetheless.
+type StaticError struct {
+	Failures []*vanilla.StaticPage
+}
+
+func (e *StaticError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d pages could not be rendered:", len(e.Failures))
+	for _, p := range e.Failures {
+		b.WriteString("\n\t" + p.Route)
+		if p.Path != "" && p.Path != p.Route {
+			b.WriteString(" " + p.Path)
+		}
+		b.WriteString(": " + p.Error)
+	}
+	return b.String()
+}
+
+// staticMain is the program rendering the pages of a project with the
+// compiled Renderer.
+const staticMain = `// Code generated by vanilla. DO NOT EDIT.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/supaleon/vanilla"
+	_ %q
+)
+
+func main() {
+	report, err := vanilla.WriteStatic(vanilla.NewRenderer(), os.Args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	json.NewEncoder(os.Stdout).Encode(report)
+}
+`
+
+// Static compiles the project of conf and renders its pages into the static
+// site out, along with their bundles and the files of its public/ directory,
+// as described in [vanilla.WriteStatic]. The pages are rendered by a program
+// built with the go command. The site is listed in the manifest.json file of
+// out.
+//
+// If some pages cannot be rendered, Static writes the others and returns the
+// manifest and a *StaticError.
+func Static(conf *Config, out string) (*Manifest, error) {
+	res, err := Compile(conf)
+	if err != nil {
+		return nil, err
+	}
+	root, err := filepath.Abs(conf.Root)
+	if err != nil {
+		return nil, err
+	}
+	if out, err = filepath.Abs(out); err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	for _, name := range res.Bundles {
+		rel := assetsDir + "/" + strings.TrimPrefix(name, path.Join(conf.out(), assetsDir)+"/")
+		if err := copyFile(filepath.Join(root, filepath.FromSlash(name)), filepath.Join(out, filepath.FromSlash(rel))); err != nil {
+			return nil, err
+		}
+		m.Assets = append(m.Assets, rel)
+	}
+	public, err := copyDir(filepath.Join(root, filepath.FromSlash(conf.options().PublicDir)), out)
+	if err != nil {
+		return nil, err
+	}
+	m.Assets = append(m.Assets, public...)
+
+	tmp, err := os.MkdirTemp("", "vanilla-static")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	main := filepath.Join(tmp, "main.go")
+	if err := os.WriteFile(main, fmt.Appendf(nil, staticMain, res.Package), 0o644); err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("go", "run", main, out)
+	cmd.Dir = root
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("rendering pages: %v\n%s", err, stderr.Bytes())
+	}
+	var report vanilla.StaticReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		return nil, fmt.Errorf("rendering pages: %v", err)
+	}
+	m.Pages, m.Failures = report.Pages, report.Failures
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(out, ManifestFile), append(data, '\n'), 0o644); err != nil {
+		return nil, err
+	}
+	if len(m.Failures) > 0 {
+		return m, &StaticError{Failures: m.Failures}
+	}
+	return m, nil
+}
+
+// copyDir copies the files of the directory src into dst, and returns their
+// slash-separated paths relative to src. A missing src has no files.
+func copyDir(src, dst string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(src, func(name string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && name == src {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, name)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return copyFile(name, filepath.Join(dst, rel))
+	})
+	return files, err
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,144 @@
+package build
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeProject writes the files of a project example.com/app using the
+// vanilla module of this tree into a temporary directory.
+func writeProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	module, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := os.ReadFile(filepath.Join(module, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	files["go.mod"] = "module example.com/app\n\ngo 1.24\n\nrequire github.com/supaleon/vanilla v0.0.0\n\nreplace github.com/supaleon/vanilla => " + module + "\n"
+	files["go.sum"] = string(sum)
+	for name, content := range files {
+		name = filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestStatic(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping go run in short mode")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOPROXY", "off")
+
+	root := writeProject(t, map[string]string{
+		"pages/Index.html":                "<script>\n</script>\n\n<div>home</div>",
+		"pages/blog/[slug]/Index.html":    "<script>\n    let slug = prop(\"\")\n</script>\n\n<h1>{slug}</h1>",
+		"pages/users/[id=int]/Index.html": "<script>\n</script>\n\n<p>user</p>",
+		"pages/blog/route.go": `package blog
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/supaleon/vanilla"
+)
+
+type Post struct {
+	Slug string
+}
+
+func LoadSlug(r *http.Request) (*Post, error) {
+	if vanilla.Param(r, "slug") == "draft" {
+		return nil, errors.New("unpublished")
+	}
+	return &Post{Slug: vanilla.Param(r, "slug")}, nil
+}
+
+func StaticSlug() ([]map[string]string, error) {
+	return []map[string]string{{"slug": "hello"}, {"slug": "a b"}, {"slug": "draft"}}, nil
+}
+`,
+		"public/robots.txt":   "User-agent: *\n",
+		"public/img/logo.svg": "<svg/>",
+	})
+	out := filepath.Join(t.TempDir(), "site")
+	m, err := Static(&Config{Root: root}, out)
+	var serr *StaticError
+	if !errors.As(err, &serr) {
+		t.Fatalf("Static: got error %v, want *StaticError", err)
+	}
+
+	var pages []string
+	for _, p := range m.Pages {
+		pages = append(pages, p.Route+" "+p.Path+" "+p.File)
+	}
+	wantPages := []string{
+		"/blog/:slug /blog/hello blog/hello/index.html",
+		"/blog/:slug /blog/a%20b blog/a b/index.html",
+		"/ / index.html",
+	}
+	if !reflect.DeepEqual(pages, wantPages) {
+		t.Errorf("pages:\ngot  %q\nwant %q", pages, wantPages)
+	}
+	var failures []string
+	for _, p := range serr.Failures {
+		failures = append(failures, p.Route+" "+p.Path+": "+p.Error)
+	}
+	wantFailures := []string{
+		"/blog/:slug /blog/draft: status 500 Internal Server Error",
+		"/users/:id : dynamic route with no static parameters",
+	}
+	if !reflect.DeepEqual(failures, wantFailures) {
+		t.Errorf("failures:\ngot  %q\nwant %q", failures, wantFailures)
+	}
+	if want := []string{"img/logo.svg", "robots.txt"}; !reflect.DeepEqual(m.Assets, want) {
+		t.Errorf("assets: got %q, want %q", m.Assets, want)
+	}
+
+	for name, want := range map[string]string{
+		"index.html":            "<div>home</div>",
+		"blog/hello/index.html": "<h1>hello</h1>",
+		"blog/a b/index.html":   "<h1>a b</h1>",
+		"robots.txt":            "User-agent: *\n",
+		"img/logo.svg":          "<svg/>",
+	} {
+		data, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(name)))
+		if err != nil {
+			t.Error(err)
+		} else if !strings.Contains(string(data), want) {
+			t.Errorf("%s: got %q, want it to contain %q", name, data, want)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(out, ManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&manifest, m) {
+		t.Errorf("manifest.json: got %+v, want %+v", manifest, *m)
+	}
+	if _, err := os.Stat(filepath.Join(out, "blog", "draft")); !os.IsNotExist(err) {
+		t.Errorf("failed page written: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package build
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// tailwind returns the setup of the plugin loading the input stylesheet of
+// the Tailwind integration through the tailwindcss command, which generates
+// the classes used by the components from its directives.
+func (b *bundler) tailwind(minify bool) func(api.PluginBuild) {
+	input := filepath.Join(b.root, filepath.FromSlash(b.opts.Tailwind.Input))
+	return func(build api.PluginBuild) {
+		filter := "^" + regexp.QuoteMeta(input) + "$"
+		build.OnLoad(api.OnLoadOptions{Filter: filter, Namespace: "file"}, func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+			css, err := b.runTailwind(input, minify)
+			if err != nil {
+				return api.OnLoadResult{}, err
+			}
+			return api.OnLoadResult{Contents: &css, ResolveDir: filepath.Dir(input), Loader: api.LoaderCSS}, nil
+		})
+	}
+}
+
+// runTailwind returns the stylesheet generated by the tailwindcss command of
+// the project from input.
+func (b *bundler) runTailwind(input string, minify bool) (string, error) {
+	name := filepath.Join(b.root, "node_modules", ".bin", "tailwindcss")
+	if runtime.GOOS == "windows" {
+		name += ".cmd"
+	}
+	if _, err := os.Stat(name); err != nil {
+		if name, err = exec.LookPath("tailwindcss"); err != nil {
+			return "", errors.New("tailwindcss command not found, install it with `npm install -D tailwindcss`")
+		}
+	}
+	out, err := os.CreateTemp("", "vanilla-tailwind-*.css")
+	if err != nil {
+		return "", err
+	}
+	out.Close()
+	defer os.Remove(out.Name())
+
+	args := []string{"-i", input, "-o", out.Name()}
+	if c := b.opts.Tailwind.Config; c != "" {
+		args = append(args, "-c", filepath.Join(b.root, filepath.FromSlash(c)))
+	}
+	if minify {
+		args = append(args, "--minify")
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Dir = b.root
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", errors.New("tailwindcss: " + err.Error() + "\n" + strings.TrimSpace(stderr.String()))
+	}
+	css, err := os.ReadFile(out.Name())
+	return string(css), err
+}
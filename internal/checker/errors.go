@@ -0,0 +1,239 @@
+// Package checker implements the semantic checks of Vanilla components which
+// follow parsing. Every violation is reported as an [Error] carrying a stable
+// [Code], so that IDEs and CI can filter them.
+package checker
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+// Code is a stable identifier of a kind of error, aka "VL001".
+// Codes are never reused once released.
+type Code string
+
+const (
+	// Layout errors, see spec/component.md.
+
+	// ScriptNotFirst occurs when the component does not begin with a
+	// top-level <script> code block.
+	ScriptNotFirst Code = "VL001"
+	// DuplicateScript occurs when the component has more than one top-level
+	// <script> code block.
+	DuplicateScript Code = "VL002"
+	// MissingTemplate occurs when the component has no top-level template element.
+	MissingTemplate Code = "VL003"
+	// MultipleTemplates occurs when the component has more than one top-level
+	// template element.
+	MultipleTemplates Code = "VL004"
+	// TopLevelComment occurs when a comment is placed outside of the code blocks.
+	TopLevelComment Code = "VL005"
+	// TopLevelContent occurs when text or a template code block is placed
+	// outside of the top-level template element.
+	TopLevelContent Code = "VL006"
+	// InlineScript occurs when the template contains a <script> element
+	// other than the `<script src="..."></script>` form.
+	InlineScript Code = "VL007"
+	// InvalidFileName occurs when the component file name is not UpperCamelCase.
+	InvalidFileName Code = "VL008"
+	// OutsidePages occurs when the component file is not stored under pages/.
+	OutsidePages Code = "VL009"
+	// InvalidLayoutOption occurs when the layout attribute of the top-level
+	// <script> has another value than "none", which opts a page out of its
+	// layouts.
+	InvalidLayoutOption Code = "VL010"
+	// MissingLayoutSlot occurs when a Layout.html file has no default <slot>
+	// element to render the page in.
+	MissingLayoutSlot Code = "VL011"
+
+	// Link errors, see package linker.
+
+	// UnresolvedImport occurs when an imported component file does not exist.
+	UnresolvedImport Code = "VK001"
+	// UnknownComponent occurs when a component tag does not match any import.
+	UnknownComponent Code = "VK002"
+	// UnusedImport occurs when an imported component is not used in the template.
+	UnusedImport Code = "VK003"
+	// NameCollision occurs when two imports bind the same component name.
+	NameCollision Code = "VK004"
+	// ImportCycle occurs when components import each other, directly or not.
+	ImportCycle Code = "VK005"
+	// UnknownAttribute occurs when an attribute of a component element is not
+	// a prop of the component.
+	UnknownAttribute Code = "VK006"
+	// MissingProp occurs when a required prop of a component element is
+	// neither assigned by an attribute nor provided by a <context>.
+	MissingProp Code = "VK007"
+	// PropMismatch occurs when the value assigned to a prop, by an attribute
+	// or a <context>, is not assignable to the type of the prop.
+	PropMismatch Code = "VK008"
+	// ContextShadowing occurs when an attribute of a component element
+	// shadows the value of the same name of an enclosing <context>.
+	ContextShadowing Code = "VK009"
+	// UnusedContent occurs when content is passed to a component which has
+	// no <slot> to render it. It is a warning.
+	UnusedContent Code = "VK010"
+	// UnknownSlot occurs when content passed to a component targets a named
+	// slot which the component does not have, aka `<p slot="footer">`.
+	UnknownSlot Code = "VK011"
+
+	// Type errors, see Check.
+
+	// GoImportFailed occurs when the Go package of an imported .go file
+	// cannot be found or type-checked.
+	GoImportFailed Code = "VT001"
+	// UndeclaredGoType occurs when an imported name is not an exported type
+	// of the Go package.
+	UndeclaredGoType Code = "VT002"
+	// InvalidPropType occurs when a prop is declared with a Go type which is
+	// not a struct, map or slice type.
+	InvalidPropType Code = "VT003"
+	// UndefinedName occurs when an identifier is neither a prop nor a loop variable.
+	UndefinedName Code = "VT004"
+	// UnknownField occurs when a selector does not denote a field.
+	UnknownField Code = "VT005"
+	// NonIndexable occurs when an index expression is applied to a value which
+	// cannot be indexed, or with an invalid index.
+	NonIndexable Code = "VT006"
+	// NonBoolCondition occurs when the condition of an {if} block or a
+	// conditional text is not a boolean.
+	NonBoolCondition Code = "VT007"
+	// InvalidOperand occurs when an operator is applied to operands of
+	// invalid or mismatched types.
+	InvalidOperand Code = "VT008"
+	// NonIterable occurs when a {for} block ranges over a value which cannot
+	// be iterated.
+	NonIterable Code = "VT009"
+	// InvalidCall occurs when a built-in function is unknown or called with
+	// invalid arguments.
+	InvalidCall Code = "VT010"
+
+	// Routing errors, see package router.
+
+	// InvalidRouteSegment occurs when a directory of a page path is not a
+	// valid route segment, aka `[a b]`, or a catch-all segment is not last.
+	InvalidRouteSegment Code = "VR001"
+	// RouteConflict occurs when the routes of two pages cannot be told
+	// apart, aka `pages/[a]/Index.html` and `pages/[b]/Index.html`.
+	RouteConflict Code = "VR002"
+	// InvalidLoader occurs when the loader of a page in a route.go file does
+	// not have the signature `func(*http.Request) (T, error)` with T a
+	// struct whose fields are props of the page.
+	InvalidLoader Code = "VR003"
+	// UnimportableRoute occurs when a route.go file is stored in a directory
+	// of a dynamic segment, which cannot be imported by Go.
+	UnimportableRoute Code = "VR004"
+	// InvalidMiddleware occurs when the middleware of a directory in a
+	// route.go file does not have the signature
+	// `func(http.Handler) http.Handler`.
+	InvalidMiddleware Code = "VR005"
+	// InvalidStaticParams occurs when the function listing the parameters
+	// of a dynamic route for static generation in a route.go file does not
+	// have the signature `func() ([]map[string]string, error)`.
+	InvalidStaticParams Code = "VR006"
+
+	// Code generation errors, see package codegen.
+
+	// UnrenderableValue occurs when a code block renders a value which is
+	// neither of a basic type, a fmt.Stringer nor an interface.
+	UnrenderableValue Code = "VG001"
+	// DuplicateName occurs when two components have the same Go name, or
+	// Go names differing only in case, which also name their generated
+	// files, aka `pages/BlogCard.html` and `pages/blog/Card.html`.
+	DuplicateName Code = "VG002"
+	// InvalidURL occurs when the built-in url is called with a name which
+	// is not a page, or with arguments which do not match the parameters of
+	// its route.
+	InvalidURL Code = "VG003"
+
+	// Bundle errors, see package build.
+
+	// BundleFailed occurs when the scripts or stylesheets of a component
+	// cannot be bundled, aka a syntax error or an unresolved import.
+	BundleFailed Code = "VB001"
+
+	// Option errors, see package options.
+
+	// UnknownOption occurs when vanilla.options.json holds a key which is not
+	// an option.
+	UnknownOption Code = "VO001"
+	// InvalidOption occurs when an option of vanilla.options.json, or its
+	// environment variable, has a value of the wrong type or out of range.
+	InvalidOption Code = "VO002"
+)
+
+// warnings are the codes of the errors which do not fail a build.
+var warnings = map[Code]bool{
+	UnusedContent: true,
+}
+
+// IsWarning reports whether the errors of code c are warnings, which are
+// reported but do not fail a build.
+func (c Code) IsWarning() bool {
+	return warnings[c]
+}
+
+// An Error describes a violation found by a check.
+type Error struct {
+	Pos  token.Position
+	Code Code
+	Msg  string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Code.IsWarning() {
+		return fmt.Sprintf("%s: warning: %s [%s]", e.Pos.String(), e.Msg, e.Code)
+	}
+	return fmt.Sprintf("%s: %s [%s]", e.Pos.String(), e.Msg, e.Code)
+}
+
+// ErrorList is a list of *Errors.
+// The zero value for an ErrorList is an empty ErrorList ready to use.
+type ErrorList []*Error
+
+// Add adds an [Error] with given position, code and message to an [ErrorList].
+func (p *ErrorList) Add(pos token.Position, code Code, msg string) {
+	*p = append(*p, &Error{pos, code, msg})
+}
+
+// Sort sorts an [ErrorList] by position, then by code.
+func (p ErrorList) Sort() {
+	sort.SliceStable(p, func(i, j int) bool {
+		e, f := &p[i].Pos, &p[j].Pos
+		if e.Filename != f.Filename {
+			return e.Filename < f.Filename
+		}
+		if e.Line != f.Line {
+			return e.Line < f.Line
+		}
+		if e.Column != f.Column {
+			return e.Column < f.Column
+		}
+		return p[i].Code < p[j].Code
+	})
+}
+
+// An ErrorList implements the error interface.
+func (p ErrorList) Error() string {
+	switch len(p) {
+	case 0:
+		return "no errors"
+	case 1:
+		return p[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", p[0], len(p)-1)
+}
+
+// Err returns an error equivalent to this error list.
+// If the list is empty or holds warnings only, Err returns nil.
+func (p ErrorList) Err() error {
+	for _, e := range p {
+		if !e.Code.IsWarning() {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package checker
+
+import (
+	"errors"
+	goast "go/ast"
+	"go/build"
+	"go/importer"
+	goparser "go/parser"
+	gotoken "go/token"
+	"go/types"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// GoLoader loads and type-checks the Go packages whose types are imported by
+// components, aka `import {User} from "./user.go"`. Packages are loaded from
+// source once per directory; a GoLoader is safe for concurrent use.
+type GoLoader struct {
+	fset     *gotoken.FileSet
+	importer types.Importer
+
+	mu    sync.Mutex
+	pkgs  map[string]*goPackage  // by absolute directory
+	files map[string]*goast.File // of the loaded packages, by file name
+}
+
+type goPackage struct {
+	pkg *types.Package
+	err error
+}
+
+// NewGoLoader returns a new GoLoader. Imports of the loaded packages are
+// type-checked from source as well.
+func NewGoLoader() *GoLoader {
+	fset := gotoken.NewFileSet()
+	return &GoLoader{
+		fset:     fset,
+		importer: importer.ForCompiler(fset, "source", nil),
+		pkgs:     make(map[string]*goPackage),
+		files:    make(map[string]*goast.File),
+	}
+}
+
+// FileSet returns the file set of the loaded Go files.
+func (l *GoLoader) FileSet() *gotoken.FileSet {
+	return l.fset
+}
+
+// Load returns the type-checked Go package in dir. Only the files matching the
+// default build context are loaded, test files are ignored.
+func (l *GoLoader) Load(dir string) (*types.Package, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if p, ok := l.pkgs[dir]; ok {
+		return p.pkg, p.err
+	}
+	pkg, err := l.load(dir)
+	l.pkgs[dir] = &goPackage{pkg, err}
+	return pkg, err
+}
+
+func (l *GoLoader) load(dir string) (*types.Package, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	var files []*goast.File
+	for _, name := range bp.GoFiles {
+		filename := filepath.Join(dir, name)
+		f, err := goparser.ParseFile(l.fset, filename, nil, goparser.SkipObjectResolution|goparser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+		l.files[filename] = f
+	}
+	var errs []error
+	conf := types.Config{
+		Importer: l.importer,
+		Error: func(err error) {
+			errs = append(errs, err)
+		},
+	}
+	pkg, _ := conf.Check(importPath(dir, bp.ImportPath), l.fset, files, nil)
+	return pkg, errors.Join(errs...)
+}
+
+// Doc returns the doc comment of the type or struct field obj of a loaded
+// package, or its line comment if it has no doc comment; "" if it has none.
+func (l *GoLoader) Doc(obj types.Object) string {
+	if !obj.Pos().IsValid() {
+		return ""
+	}
+	l.mu.Lock()
+	f := l.files[l.fset.File(obj.Pos()).Name()]
+	l.mu.Unlock()
+	if f == nil {
+		return ""
+	}
+	var doc string
+	found := false
+	goast.Inspect(f, func(n goast.Node) bool {
+		if found || n == nil || n.Pos() > obj.Pos() || n.End() <= obj.Pos() {
+			return false
+		}
+		switch n := n.(type) {
+		case *goast.GenDecl:
+			for _, spec := range n.Specs {
+				if s, ok := spec.(*goast.TypeSpec); ok && s.Name.Pos() == obj.Pos() {
+					doc, found = s.Doc.Text(), true
+					if doc == "" && !n.Lparen.IsValid() {
+						doc = n.Doc.Text() // aka type User struct{}
+					}
+					if doc == "" {
+						doc = s.Comment.Text()
+					}
+				}
+			}
+		case *goast.Field:
+			embedded := len(n.Names) == 0 && n.Type.Pos() <= obj.Pos() && obj.Pos() < n.Type.End()
+			if embedded || slices.ContainsFunc(n.Names, func(name *goast.Ident) bool { return name.Pos() == obj.Pos() }) {
+				doc, found = n.Doc.Text(), true
+				if doc == "" {
+					doc = n.Comment.Text()
+				}
+			}
+		}
+		return !found
+	})
+	return doc
+}
+
+// importPath returns the import path of the package in dir from the module
+// path of the enclosing go.mod file, or path if there is none.
+func importPath(dir, path string) string {
+	for d := dir; ; {
+		data, err := os.ReadFile(filepath.Join(d, "go.mod"))
+		if err == nil {
+			for line := range strings.Lines(string(data)) {
+				if mod, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+					rel, _ := filepath.Rel(d, dir)
+					return pathpkg.Join(strings.Trim(strings.TrimSpace(mod), `"`), filepath.ToSlash(rel))
+				}
+			}
+			return path
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return path
+		}
+		d = parent
+	}
+}
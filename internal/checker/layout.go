@@ -0,0 +1,157 @@
+package checker
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/supaleon/vanilla/internal/ast"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+// CheckLayout checks the component c parsed from filename against the layout
+// rules of spec/component.md: a component file is stored under pages/, named
+// in UpperCamelCase and consists of exactly one top-level <script> code block
+// followed by exactly one top-level template element. A Layout.html file must
+// have a <slot> element, see [LayoutFile].
+//
+// The returned list is sorted by position.
+func CheckLayout(fset *token.FileSet, filename string, c *ast.Component) ErrorList {
+	var list ErrorList
+	errorf := func(loc token.Loc, code Code, msg string) {
+		list.Add(fset.Position(loc), code, msg)
+	}
+
+	// file name and location
+	filePos := token.Position{Filename: filename, Line: 1, Column: 1}
+	dirs := strings.Split(filepath.ToSlash(filepath.Dir(filename)), "/")
+	if !slices.Contains(dirs, "pages") {
+		list.Add(filePos, OutsidePages, "component files must be stored under the pages/ folder")
+	}
+	if name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)); !isUpperCamelCase(name) {
+		list.Add(filePos, InvalidFileName, "component file name "+name+" must be UpperCamelCase, aka `SideBar.html`")
+	}
+
+	// top-level code blocks
+	script := c.ESModule != nil
+	var template *ast.Element
+	for _, n := range c.Template.Nodes {
+		switch n := n.(type) {
+		case *ast.Element:
+			switch {
+			case n.Name == "script" && !script:
+				script = true
+				errorf(n.Start, ScriptNotFirst, "the <script> code block must be placed at the top of the component")
+			case n.Name == "script":
+				errorf(n.Start, DuplicateScript, "a component can only contain one top-level <script> code block")
+			case template != nil:
+				errorf(n.Start, MultipleTemplates, "a component can only contain one top-level template element, found <"+n.Name+">")
+			default:
+				template = n
+			}
+		case *ast.Comment:
+			errorf(n.Start, TopLevelComment, "comments are not allowed outside of the template element")
+		case *ast.Text:
+			if i := strings.IndexFunc(n.Value, func(r rune) bool { return !unicode.IsSpace(r) }); i >= 0 {
+				errorf(n.Start+token.Loc(i), TopLevelContent, "text is not allowed outside of the template element")
+			}
+		default:
+			errorf(n.Range().Start, TopLevelContent, "code blocks are not allowed outside of the template element")
+		}
+	}
+	if !script {
+		loc := token.NoLoc
+		if len(c.Template.Nodes) > 0 {
+			loc = c.Template.Nodes[0].Range().Start
+		}
+		if loc.IsValid() {
+			errorf(loc, ScriptNotFirst, "a component must begin with a top-level <script> code block")
+		} else {
+			list.Add(filePos, ScriptNotFirst, "a component must begin with a top-level <script> code block")
+		}
+	}
+	if template == nil {
+		loc := token.NoLoc
+		if c.ESModule != nil {
+			loc = c.ESModule.Range().End
+		}
+		if loc.IsValid() {
+			errorf(loc, MissingTemplate, "a component must contain a top-level template element, aka <div> or <metadata>")
+		} else {
+			list.Add(filePos, MissingTemplate, "a component must contain a top-level template element, aka <div> or <metadata>")
+		}
+		list.Sort()
+		return list
+	}
+
+	// layouts
+	if c.ESModule != nil {
+		for _, a := range c.ESModule.Script.Attrs {
+			if a.Name == "layout" && !isText(a.Value, "none") {
+				errorf(a.NameLoc, InvalidLayoutOption, `the layout attribute of <script> only accepts "none", aka <script layout="none">`)
+			}
+		}
+	}
+	if filepath.Base(filename) == LayoutFile && !slices.Contains(c.Slots(), "") {
+		errorf(template.Start, MissingLayoutSlot, "a layout must contain a default <slot> element where the page is rendered")
+	}
+
+	// inline scripts
+	ast.Inspect(template, func(n ast.Node) bool {
+		if e, ok := n.(*ast.Element); ok && isScript(e) {
+			if !slices.ContainsFunc(e.Attrs, func(a *ast.Attribute) bool { return a.Name == "src" }) || len(e.Children) > 0 {
+				errorf(e.Start, InlineScript, "inline <script> is not allowed in the template, use <script src=\"...\"></script> instead")
+			}
+			return false
+		}
+		return true
+	})
+
+	list.Sort()
+	return list
+}
+
+// LayoutFile is the name of the layout files. Each page is rendered in the
+// slot of the nearest layout file up its directory chain, which is in turn
+// rendered in the slot of the next one, unless its <script> has the
+// attribute layout="none".
+const LayoutFile = "Layout.html"
+
+// NoLayout reports whether the component c opts out of its layouts with
+// `<script layout="none">`.
+func NoLayout(c *ast.Component) bool {
+	if c.ESModule == nil {
+		return false
+	}
+	return slices.ContainsFunc(c.ESModule.Script.Attrs, func(a *ast.Attribute) bool { return a.Name == "layout" })
+}
+
+// isText reports whether the attribute value nodes are the text s.
+func isText(value []ast.Node, s string) bool {
+	if len(value) != 1 {
+		return false
+	}
+	t, ok := value[0].(*ast.Text)
+	return ok && t.Value == s
+}
+
+func isScript(n ast.Node) bool {
+	e, ok := n.(*ast.Element)
+	return ok && e.Name == "script"
+}
+
+// isUpperCamelCase reports whether name is an UpperCamelCase identifier, aka `SideBar`.
+func isUpperCamelCase(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	if !unicode.IsUpper(r) {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
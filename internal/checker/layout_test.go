@@ -0,0 +1,77 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/supaleon/vanilla/internal/parser"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+func TestCheckLayout(t *testing.T) {
+	const script = "<script>\n</script>\n"
+	tests := []struct {
+		filename, src string
+		errs          []string
+	}{
+		{"pages/Hello.html", script + "<div>\n<script src=\"x.js\"></script>\n</div>", nil},
+		{"/app/pages/blog/SideBar2.html", script + "<metadata></metadata>\n", nil},
+		{"pages/Hello.html", "<div></div>", []string{
+			"pages/Hello.html:1:1: a component must begin with a top-level <script> code block [VL001]",
+		}},
+		{"pages/Hello.html", "<div></div>\n" + script, []string{
+			"pages/Hello.html:2:1: the <script> code block must be placed at the top of the component [VL001]",
+		}},
+		{"pages/Hello.html", script + script + "<div></div>", []string{
+			"pages/Hello.html:3:1: a component can only contain one top-level <script> code block [VL002]",
+		}},
+		{"pages/Hello.html", script, []string{
+			"pages/Hello.html:2:10: a component must contain a top-level template element, aka <div> or <metadata> [VL003]",
+		}},
+		{"pages/Hello.html", script + "<div></div>\n<span></span>", []string{
+			"pages/Hello.html:4:1: a component can only contain one top-level template element, found <span> [VL004]",
+		}},
+		{"pages/Hello.html", script + "<!--x-->\n<div></div>", []string{
+			"pages/Hello.html:3:1: comments are not allowed outside of the template element [VL005]",
+		}},
+		{"pages/Hello.html", script + "<div></div>\n  hello", []string{
+			"pages/Hello.html:4:3: text is not allowed outside of the template element [VL006]",
+		}},
+		{"pages/Hello.html", script + "<div></div>\n{x}", []string{
+			"pages/Hello.html:4:1: code blocks are not allowed outside of the template element [VL006]",
+		}},
+		{"pages/Hello.html", script + "<div>\n  <p><script>alert(1)</script></p>\n</div>", []string{
+			"pages/Hello.html:4:6: inline <script> is not allowed in the template, use <script src=\"...\"></script> instead [VL007]",
+		}},
+		{"pages/side-bar.html", script + "<div></div>", []string{
+			"pages/side-bar.html:1:1: component file name side-bar must be UpperCamelCase, aka `SideBar.html` [VL008]",
+		}},
+		{"components/Hello.html", script + "<div></div>", []string{
+			"components/Hello.html:1:1: component files must be stored under the pages/ folder [VL009]",
+		}},
+		{"pages/Hello.html", "<script layout=\"none\">\n</script>\n<div></div>", nil},
+		{"pages/Hello.html", "<script layout=\"blog\">\n</script>\n<div></div>", []string{
+			"pages/Hello.html:1:9: the layout attribute of <script> only accepts \"none\", aka <script layout=\"none\"> [VL010]",
+		}},
+		{"pages/blog/Layout.html", script + "<html><body><slot/></body></html>", nil},
+		{"pages/blog/Layout.html", script + "<html><body><slot name=\"main\"/></body></html>", []string{
+			"pages/blog/Layout.html:3:1: a layout must contain a default <slot> element where the page is rendered [VL011]",
+		}},
+	}
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		c, err := parser.ParseFile(fset, test.filename, []byte(test.src))
+		if err != nil {
+			t.Errorf("%q: %v", test.src, err)
+			continue
+		}
+		list := CheckLayout(fset, test.filename, c)
+		var got []string
+		for _, e := range list {
+			got = append(got, e.Error())
+		}
+		if strings.Join(got, "\n") != strings.Join(test.errs, "\n") {
+			t.Errorf("%s %q:\ngot  %q\nwant %q", test.filename, test.src, got, test.errs)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package testdata
+
+// User is a user of the app.
+type User struct {
+	// Name is the name displayed to the other users.
+	Name    string
+	Active  bool
+	Likes   int // count of likes of the posts
+	Tags    []string
+	Profile map[string]string
+	Posts   []*Post
+	Extra   any
+	secret  string
+}
+
+type Post struct {
+	Title string
+}
+
+type Code int
+
+// Member is a user of a team.
+type Member struct {
+	*User
+	Name string // name in the team
+	Role string
+}
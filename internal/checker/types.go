@@ -0,0 +1,573 @@
+package checker
+
+import (
+	"fmt"
+	"go/types"
+	"os"
+	"path/filepath"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/supaleon/vanilla/internal/ast"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+// Info holds the results of type-checking a component.
+type Info struct {
+	// Props maps the prop names to their Go types.
+	Props map[string]types.Type
+	// Types maps the checked expressions, including loop variables, to their
+	// types. Literals have untyped basic types.
+	Types map[ast.Expr]types.Type
+	// Fields maps the selectors on structs to the selected Go fields, aka
+	// `Name` for `user.name`.
+	Fields map[*ast.Selector]*types.Var
+	// Uses maps the identifiers of props and loop variables to the identifier
+	// of their declaration.
+	Uses map[*ast.Ident]*ast.Ident
+}
+
+// TypeOf returns the type of x, or nil if x was not type-checked.
+func (info *Info) TypeOf(x ast.Expr) types.Type {
+	return info.Types[x]
+}
+
+// Check type-checks the prop declarations and the template expressions of the
+// component c parsed from filename. Go types imported from .go files are
+// loaded by loader relative to the directory of filename.
+//
+// The returned info is complete even if there are errors; expressions which
+// could not be typed are left out of it. The error list is sorted by position.
+func Check(fset *token.FileSet, filename string, c *ast.Component, loader *GoLoader) (*Info, ErrorList) {
+	ch := &typeChecker{
+		fset:    fset,
+		dir:     filepath.Dir(filename),
+		loader:  loader,
+		goTypes: make(map[string]types.Type),
+		info: &Info{
+			Props:  make(map[string]types.Type),
+			Types:  make(map[ast.Expr]types.Type),
+			Fields: make(map[*ast.Selector]*types.Var),
+			Uses:   make(map[*ast.Ident]*ast.Ident),
+		},
+	}
+	ch.scope = &scope{names: make(map[string]*object)}
+	if c.ESModule != nil {
+		ch.checkImports(c.ESModule)
+		ch.checkProps(c.ESModule)
+	}
+	ch.checkNodes(c.Template.Nodes)
+	ch.errors.Sort()
+	return ch.info, ch.errors
+}
+
+type typeChecker struct {
+	fset    *token.FileSet
+	dir     string
+	loader  *GoLoader
+	goTypes map[string]types.Type // imported Go type names
+	scope   *scope
+	info    *Info
+	errors  ErrorList
+}
+
+// object is a prop or a loop variable.
+type object struct {
+	decl *ast.Ident
+	typ  types.Type
+}
+
+type scope struct {
+	parent *scope
+	names  map[string]*object
+}
+
+func (s *scope) lookup(name string) *object {
+	for ; s != nil; s = s.parent {
+		if obj, ok := s.names[name]; ok {
+			return obj
+		}
+	}
+	return nil
+}
+
+func (ch *typeChecker) errorf(loc token.Loc, code Code, format string, args ...any) {
+	ch.errors.Add(ch.fset.Position(loc), code, fmt.Sprintf(format, args...))
+}
+
+// ----------------------------------------------------------------------------
+// Declarations
+
+func (ch *typeChecker) checkImports(m *ast.ESModule) {
+	for _, spec := range m.Imports {
+		if spec.Kind != ast.ImportSTMT || spec.File != ast.FileGo {
+			continue
+		}
+		filename := filepath.Join(ch.dir, filepath.FromSlash(spec.Path))
+		if _, err := os.Stat(filename); err != nil {
+			ch.errorf(spec.PathLoc, GoImportFailed, "cannot find Go file %s", spec.Path)
+			continue
+		}
+		pkg, err := ch.loader.Load(filepath.Dir(filename))
+		if err != nil {
+			ch.errorf(spec.PathLoc, GoImportFailed, "cannot load Go package of %s: %v", spec.Path, err)
+		}
+		if pkg == nil {
+			continue
+		}
+		for _, name := range spec.Names {
+			obj, ok := pkg.Scope().Lookup(name.Name).(*types.TypeName)
+			if !ok || !obj.Exported() {
+				ch.errorf(name.NameLoc, UndeclaredGoType, "%s is not an exported type of package %s", name.Name, pkg.Name())
+				continue
+			}
+			ch.goTypes[name.Name] = obj.Type()
+		}
+	}
+}
+
+func (ch *typeChecker) checkProps(m *ast.ESModule) {
+	anyType := types.Universe.Lookup("any").Type()
+	for _, d := range m.Props {
+		var typ types.Type
+		switch d.Kind {
+		case ast.PropGoType:
+			typ = ch.goTypes[d.Type.Name]
+			if typ == nil {
+				continue // reported by checkImports or the parser
+			}
+			switch typ.Underlying().(type) {
+			case *types.Struct, *types.Map, *types.Slice:
+			default:
+				ch.errorf(d.Type.NameLoc, InvalidPropType, "prop %s must be a struct, map or slice type, found %s", d.Name.Name, ch.typeString(typ))
+			}
+		case ast.PropBool:
+			typ = types.Typ[types.Bool]
+		case ast.PropInt:
+			typ = types.Typ[types.Int32]
+		case ast.PropFloat:
+			typ = types.Typ[types.Float32]
+		case ast.PropString:
+			typ = types.Typ[types.String]
+		case ast.PropArray:
+			typ = types.NewSlice(anyType)
+		case ast.PropObject:
+			typ = types.NewMap(types.Typ[types.String], anyType)
+		}
+		ch.info.Props[d.Name.Name] = typ
+		ch.scope.names[d.Name.Name] = &object{decl: d.Name, typ: typ}
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Template
+
+func (ch *typeChecker) checkNodes(nodes []ast.Node) {
+	for _, n := range nodes {
+		ch.checkNode(n)
+	}
+}
+
+func (ch *typeChecker) checkNode(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.Element:
+		for _, a := range n.Attrs {
+			ch.checkNodes(a.Value)
+		}
+		ch.checkNodes(n.Children)
+	case *ast.Interpolation:
+		ch.expr(n.X)
+	case *ast.FormatSpec:
+		ch.expr(n.X)
+	case *ast.ConditionalText:
+		ch.cond(n.X, "conditional text")
+	case *ast.IfBlock:
+		ch.cond(n.Cond, "if condition")
+		ch.checkNodes(n.Then)
+		ch.checkNodes(n.Else)
+	case *ast.ForBlock:
+		ch.checkFor(n)
+	}
+}
+
+// cond checks that x is a boolean expression used as what.
+func (ch *typeChecker) cond(x ast.Expr, what string) {
+	if t := ch.expr(x); t != nil && !isBoolean(t) {
+		ch.errorf(x.Range().Start, NonBoolCondition, "non-bool %s (%s) used as %s", ast.ExprString(x), ch.typeString(t), what)
+	}
+}
+
+func (ch *typeChecker) checkFor(b *ast.ForBlock) {
+	var key, value types.Type
+	if r, ok := b.X.(*ast.Range); ok {
+		ch.integer(r.Low)
+		ch.integer(r.High)
+		key, value = types.Typ[types.Int], types.Typ[types.Int]
+	} else if t := ch.expr(b.X); t != nil {
+		switch u := t.Underlying().(type) {
+		case *types.Slice:
+			key, value = types.Typ[types.Int], u.Elem()
+		case *types.Array:
+			key, value = types.Typ[types.Int], u.Elem()
+		case *types.Map:
+			key, value = u.Key(), u.Elem()
+		case *types.Basic:
+			if u.Info()&types.IsString != 0 {
+				key, value = types.Typ[types.Int], types.Typ[types.Rune]
+			} else if u.Info()&types.IsInteger != 0 {
+				key, value = types.Typ[types.Int], types.Typ[types.Int]
+			}
+		}
+		if key == nil {
+			ch.errorf(b.X.Range().Start, NonIterable, "cannot range over %s (%s)", ast.ExprString(b.X), ch.typeString(t))
+		}
+	}
+
+	outer := ch.scope
+	ch.scope = &scope{parent: outer, names: make(map[string]*object)}
+	ch.declare(b.Key, key)
+	if b.Value != nil {
+		ch.declare(b.Value, value)
+	}
+	ch.checkNodes(b.Body)
+	ch.scope = outer
+}
+
+func (ch *typeChecker) declare(id *ast.Ident, typ types.Type) {
+	if id.Name == "_" {
+		return
+	}
+	if typ != nil {
+		ch.info.Types[id] = typ
+	}
+	ch.scope.names[id.Name] = &object{decl: id, typ: typ}
+}
+
+// integer checks that x is an integer expression.
+func (ch *typeChecker) integer(x ast.Expr) {
+	if t := ch.expr(x); t != nil && !hasInfo(t, types.IsInteger) {
+		ch.errorf(x.Range().Start, InvalidOperand, "%s (%s) must be an integer", ast.ExprString(x), ch.typeString(t))
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Expressions
+
+// expr type-checks x and returns its type, or nil if x is invalid.
+func (ch *typeChecker) expr(x ast.Expr) types.Type {
+	t := ch.exprInternal(x)
+	if t != nil {
+		ch.info.Types[x] = t
+	}
+	return t
+}
+
+func (ch *typeChecker) exprInternal(x ast.Expr) types.Type {
+	switch x := x.(type) {
+	case *ast.Ident:
+		if x.Name == "_" {
+			ch.errorf(x.NameLoc, UndefinedName, "cannot use _ as value")
+			return nil
+		}
+		obj := ch.scope.lookup(x.Name)
+		if obj == nil {
+			ch.errorf(x.NameLoc, UndefinedName, "undefined: %s", x.Name)
+			return nil
+		}
+		ch.info.Uses[x] = obj.decl
+		return obj.typ
+	case *ast.BasicLit:
+		switch x.Kind {
+		case token.INT:
+			return types.Typ[types.UntypedInt]
+		case token.FLOAT:
+			return types.Typ[types.UntypedFloat]
+		case token.STRING:
+			return types.Typ[types.UntypedString]
+		case token.CHAR:
+			return types.Typ[types.UntypedRune]
+		case token.TRUE, token.FALSE:
+			return types.Typ[types.UntypedBool]
+		}
+	case *ast.Paren:
+		return ch.expr(x.X)
+	case *ast.Selector:
+		return ch.selector(x)
+	case *ast.Index:
+		return ch.index(x)
+	case *ast.Call:
+		return ch.call(x)
+	case *ast.Unary:
+		t := ch.expr(x.X)
+		if t == nil {
+			return nil
+		}
+		if x.Op == token.NOT && !isBoolean(t) || x.Op == token.SUB && !hasInfo(t, types.IsNumeric) {
+			ch.errorf(x.OpLoc, InvalidOperand, "invalid operation: operator %s not defined on %s (%s)", x.Op, ast.ExprString(x.X), ch.typeString(t))
+			return nil
+		}
+		return t
+	case *ast.Binary:
+		return ch.binary(x)
+	case *ast.Range:
+		ch.errorf(x.OpLoc, InvalidOperand, "range expression is only allowed in {for} block")
+	}
+	return nil
+}
+
+func (ch *typeChecker) selector(x *ast.Selector) types.Type {
+	t := ch.expr(x.X)
+	if t == nil {
+		return nil
+	}
+	base := t
+	if p, ok := base.Underlying().(*types.Pointer); ok {
+		base = p.Elem()
+	}
+	switch u := base.Underlying().(type) {
+	case *types.Struct:
+		if f := lookupField(base, x.Sel.Name); f != nil {
+			ch.info.Fields[x] = f
+			return f.Type()
+		}
+	case *types.Map:
+		// user.profile.city is profile["city"]
+		if isString(u.Key()) {
+			return u.Elem()
+		}
+	}
+	ch.errorf(x.Sel.NameLoc, UnknownField, "%s undefined (%s has no field %s)", ast.ExprString(x), ch.typeString(t), x.Sel.Name)
+	return nil
+}
+
+// lookupField returns the exported field of the struct type t named name, or
+// name with its first letter in upper case, aka `Name` for `name`.
+func lookupField(t types.Type, name string) *types.Var {
+	names := []string{name}
+	if r, size := utf8.DecodeRuneInString(name); unicode.IsLower(r) {
+		names = append(names, string(unicode.ToUpper(r))+name[size:])
+	}
+	for _, name := range names {
+		obj, _, _ := types.LookupFieldOrMethod(t, true, nil, name)
+		if f, ok := obj.(*types.Var); ok && f.IsField() && f.Exported() {
+			return f
+		}
+	}
+	return nil
+}
+
+// Fields returns the fields which can be selected on values of type t: the
+// exported fields of the struct type t, or of the struct type t points to,
+// followed by the fields promoted from its embedded structs.
+func Fields(t types.Type) []*types.Var {
+	var fields []*types.Var
+	seen := make(map[types.Type]bool)
+	for queue := []types.Type{t}; len(queue) > 0; queue = queue[1:] {
+		s := queue[0]
+		if p, ok := s.Underlying().(*types.Pointer); ok {
+			s = p.Elem()
+		}
+		st, ok := s.Underlying().(*types.Struct)
+		if !ok || seen[s] {
+			continue
+		}
+		seen[s] = true
+		for f := range st.Fields() {
+			// a field shadowed by another, or ambiguous, is not selected by its name
+			if f.Exported() && lookupField(t, f.Name()) == f {
+				fields = append(fields, f)
+			}
+			if f.Embedded() {
+				queue = append(queue, f.Type())
+			}
+		}
+	}
+	return fields
+}
+
+func (ch *typeChecker) index(x *ast.Index) types.Type {
+	t := ch.expr(x.X)
+	it := ch.expr(x.Index)
+	if t == nil || it == nil {
+		return nil
+	}
+	var key, elem types.Type
+	switch u := t.Underlying().(type) {
+	case *types.Slice:
+		key, elem = types.Typ[types.Int], u.Elem()
+	case *types.Array:
+		key, elem = types.Typ[types.Int], u.Elem()
+	case *types.Pointer:
+		if a, ok := u.Elem().Underlying().(*types.Array); ok {
+			key, elem = types.Typ[types.Int], a.Elem()
+		}
+	case *types.Map:
+		key, elem = u.Key(), u.Elem()
+	case *types.Basic:
+		if u.Info()&types.IsString != 0 {
+			key, elem = types.Typ[types.Int], types.Typ[types.Byte]
+		}
+	}
+	if elem == nil {
+		ch.errorf(x.LBrack, NonIndexable, "invalid operation: cannot index %s (%s)", ast.ExprString(x.X), ch.typeString(t))
+		return nil
+	}
+	if _, isMap := t.Underlying().(*types.Map); !isMap && !hasInfo(it, types.IsInteger) || isMap && !compatible(it, key) {
+		ch.errorf(x.Index.Range().Start, NonIndexable, "invalid index %s (%s) of %s (%s)",
+			ast.ExprString(x.Index), ch.typeString(it), ast.ExprString(x.X), ch.typeString(t))
+		return nil
+	}
+	return elem
+}
+
+func (ch *typeChecker) call(x *ast.Call) types.Type {
+	if x.Fun.Name == "url" {
+		// url(BlogPostIndex, post.id): the page and the arguments are checked
+		// against its route when generating code
+		if _, ok := firstArg(x).(*ast.Ident); !ok {
+			ch.errorf(x.Fun.NameLoc, InvalidCall, "url expects the name of a page as first argument, aka url(BlogPostIndex, post.id)")
+			return nil
+		}
+		for _, arg := range x.Args[1:] {
+			ch.expr(arg)
+		}
+		return types.Typ[types.String]
+	}
+	var args []types.Type
+	for _, arg := range x.Args {
+		args = append(args, ch.expr(arg))
+	}
+	switch x.Fun.Name {
+	case "len", "escape":
+	default:
+		ch.errorf(x.Fun.NameLoc, InvalidCall, "undefined function %s, only len, escape and url are built in", x.Fun.Name)
+		return nil
+	}
+	if len(args) != 1 {
+		ch.errorf(x.Fun.NameLoc, InvalidCall, "%s expects 1 argument, found %d", x.Fun.Name, len(args))
+		return nil
+	}
+	t := args[0]
+	if t == nil {
+		return nil
+	}
+	if x.Fun.Name == "escape" {
+		if !isString(t) {
+			ch.errorf(x.Args[0].Range().Start, InvalidCall, "invalid argument %s (%s) for escape, expected a string",
+				ast.ExprString(x.Args[0]), ch.typeString(t))
+			return nil
+		}
+		return types.Typ[types.String]
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Slice, *types.Array, *types.Map, *types.Chan:
+		return types.Typ[types.Int]
+	case *types.Basic:
+		if u.Info()&types.IsString != 0 {
+			return types.Typ[types.Int]
+		}
+	}
+	ch.errorf(x.Args[0].Range().Start, InvalidCall, "invalid argument %s (%s) for len", ast.ExprString(x.Args[0]), ch.typeString(t))
+	return nil
+}
+
+func firstArg(x *ast.Call) ast.Expr {
+	if len(x.Args) == 0 {
+		return nil
+	}
+	return x.Args[0]
+}
+
+func (ch *typeChecker) binary(x *ast.Binary) types.Type {
+	xt, yt := ch.expr(x.X), ch.expr(x.Y)
+	if xt == nil || yt == nil {
+		return nil
+	}
+	switch x.Op {
+	case token.AND, token.OR:
+		for _, operand := range []struct {
+			x ast.Expr
+			t types.Type
+		}{{x.X, xt}, {x.Y, yt}} {
+			if !isBoolean(operand.t) {
+				ch.errorf(operand.x.Range().Start, InvalidOperand, "invalid operation: operator %s not defined on %s (%s)",
+					x.Op, ast.ExprString(operand.x), ch.typeString(operand.t))
+				return nil
+			}
+		}
+		return types.Typ[types.Bool]
+	}
+	// comparison
+	if !compatible(xt, yt) {
+		ch.errorf(x.OpLoc, InvalidOperand, "invalid operation: %s (mismatched types %s and %s)",
+			ast.ExprString(x), ch.typeString(xt), ch.typeString(yt))
+		return nil
+	}
+	switch x.Op {
+	case token.EQ, token.NE:
+		if !types.Comparable(xt) || !types.Comparable(yt) {
+			ch.errorf(x.OpLoc, InvalidOperand, "invalid operation: %s (%s cannot be compared)", ast.ExprString(x), ch.typeString(xt))
+			return nil
+		}
+	default:
+		if !hasInfo(xt, types.IsOrdered) || !hasInfo(yt, types.IsOrdered) {
+			ch.errorf(x.OpLoc, InvalidOperand, "invalid operation: %s (operator %s not defined on %s)", ast.ExprString(x), x.Op, ch.typeString(xt))
+			return nil
+		}
+	}
+	return types.Typ[types.Bool]
+}
+
+// compatible reports whether values of the types x and y can be compared.
+func compatible(x, y types.Type) bool {
+	if types.Identical(x, y) {
+		return true
+	}
+	if isUntyped(y) {
+		x, y = y, x
+	}
+	if isUntyped(x) {
+		// x is a literal
+		info := x.Underlying().(*types.Basic).Info()
+		switch {
+		case info&types.IsBoolean != 0:
+			return isBoolean(y) || isInterface(y)
+		case info&types.IsString != 0:
+			return isString(y) || isInterface(y)
+		case info&types.IsInteger != 0:
+			return hasInfo(y, types.IsNumeric) || isInterface(y)
+		case info&types.IsFloat != 0:
+			return hasInfo(y, types.IsFloat) || isUntyped(y) && hasInfo(y, types.IsNumeric) || isInterface(y)
+		}
+		return false
+	}
+	return types.AssignableTo(x, y) || types.AssignableTo(y, x)
+}
+
+func hasInfo(t types.Type, info types.BasicInfo) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&info != 0
+}
+
+func isBoolean(t types.Type) bool { return hasInfo(t, types.IsBoolean) }
+func isString(t types.Type) bool  { return hasInfo(t, types.IsString) }
+func isUntyped(t types.Type) bool { return hasInfo(t, types.IsUntyped) }
+
+func isInterface(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Interface)
+	return ok
+}
+
+// typeString returns the string of t, qualified by package names only.
+func (ch *typeChecker) typeString(t types.Type) string {
+	return types.TypeString(t, (*types.Package).Name)
+}
+
+// AssignableTo reports whether a value of type v, possibly the untyped type of
+// a literal, is assignable to a variable of type t.
+func AssignableTo(v, t types.Type) bool {
+	if isUntyped(v) {
+		return compatible(v, t)
+	}
+	return types.AssignableTo(v, t)
+}
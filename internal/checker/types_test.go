@@ -0,0 +1,190 @@
+package checker
+
+import (
+	"go/types"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/supaleon/vanilla/internal/ast"
+	"github.com/supaleon/vanilla/internal/parser"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+const script = `<script>
+    import {User, Code} from "./user.go"
+    let user = prop(User())
+    let theme = prop("dark")
+    let count = prop(1)
+    let tags = prop([])
+</script>
+`
+
+func check(t *testing.T, loader *GoLoader, src string) (*ast.Component, *Info, []string) {
+	t.Helper()
+	fset := token.NewFileSet()
+	filename := filepath.Join("testdata", "Hello.html")
+	c, err := parser.ParseFile(fset, filename, []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, list := Check(fset, filename, c, loader)
+	var errs []string
+	for _, e := range list {
+		errs = append(errs, e.Error())
+	}
+	return c, info, errs
+}
+
+func TestCheck(t *testing.T) {
+	c, info, errs := check(t, NewGoLoader(), script+`<div class="{theme}">
+    {user.name} {user.tags[0]} {user.profile.city} {user.posts[0].title}
+    {if len(user.tags) > 0 && user.active && count >= 1}{/if}
+    {for i, tag in user.tags}<span data-i={i}>{escape(tag)}</span>{/for}
+    {for i in 1..9}{i}{/for}
+    {for _, v in tags}{v}{/for}
+</div>`)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	want := map[string]string{
+		"user.name":           "string",
+		"user.tags[0]":        "string",
+		"user.profile.city":   "string",
+		"user.posts[0].title": "string",
+		"len(user.tags)":      "int",
+		"tag":                 "string",
+		"escape(tag)":         "string",
+		"v":                   "any",
+		"theme":               "string",
+		"count":               "int32",
+		"user":                "testdata.User",
+	}
+	ast.Inspect(c.Template, func(n ast.Node) bool {
+		x, ok := n.(ast.Expr)
+		if !ok {
+			return true
+		}
+		s := ast.ExprString(x)
+		if w, ok := want[s]; ok {
+			if got := info.TypeOf(x); got == nil || types.TypeString(got, (*types.Package).Name) != w {
+				t.Errorf("%s: got type %v; want %s", s, got, w)
+			}
+		}
+		return true
+	})
+	for sel, f := range info.Fields {
+		if f.Name() != strings.ToUpper(sel.Sel.Name[:1])+sel.Sel.Name[1:] {
+			t.Errorf("%s: got field %s", ast.ExprString(sel), f.Name())
+		}
+	}
+	if len(info.Fields) != 8 {
+		t.Errorf("got %d resolved fields; want 8", len(info.Fields))
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	tests := []struct {
+		tmpl, err string
+	}{
+		{"{nobody}", "8:7: undefined: nobody [VT004]"},
+		{"{user.age}", "8:12: user.age undefined (testdata.User has no field age) [VT005]"},
+		{"{user.secret}", "8:12: user.secret undefined (testdata.User has no field secret) [VT005]"},
+		{"{user.extra.x}", "8:18: user.extra.x undefined (any has no field x) [VT005]"},
+		{"{user.name.first}", "8:17: user.name.first undefined (string has no field first) [VT005]"},
+		{"{user.active[0]}", "8:18: invalid operation: cannot index user.active (bool) [VT006]"},
+		{"{user.tags[user.name]}", "8:17: invalid index user.name (string) of user.tags ([]string) [VT006]"},
+		{"{if user.name}{/if}", "8:10: non-bool user.name (string) used as if condition [VT007]"},
+		{"{count: yes}", "8:7: non-bool count (int32) used as conditional text [VT007]"},
+		{"{if user.likes > \"a\"}{/if}", "8:21: invalid operation: user.likes > \"a\" (mismatched types int and untyped string) [VT008]"},
+		{"{if user.likes == count}{/if}", "8:21: invalid operation: user.likes == count (mismatched types int and int32) [VT008]"},
+		{"{if !user.name}{/if}", "8:10: invalid operation: operator ! not defined on user.name (string) [VT008]"},
+		{"{if user.tags == tags}{/if}", "8:20: invalid operation: user.tags == tags (mismatched types []string and []any) [VT008]"},
+		{"{if user.active && user.likes}{/if}", "8:25: invalid operation: operator && not defined on user.likes (int) [VT008]"},
+		{"{for i in user.likes}{/for}", ""}, // range over int
+		{"{for i in user.active}{/for}", "8:16: cannot range over user.active (bool) [VT009]"},
+		{"{for i in 1..user.name}{/for}", "8:19: user.name (string) must be an integer [VT008]"},
+		{"{len(user.likes)}", "8:11: invalid argument user.likes (int) for len [VT010]"},
+		{"{escape(user.likes)}", "8:14: invalid argument user.likes (int) for escape, expected a string [VT010]"},
+		{"{upper(user.name)}", "8:7: undefined function upper, only len, escape and url are built in [VT010]"},
+		{"<a href={url(BlogIdIndex,user.likes)}></a>", ""},
+		{"<a href=\"{url(BlogIdIndex, user.nam)}\"></a>", "8:38: user.nam undefined (testdata.User has no field nam) [VT005]"},
+		{"<a href=\"{url(user.name)}\"></a>", "8:16: url expects the name of a page as first argument, aka url(BlogPostIndex, post.id) [VT010]"},
+		{"{for i in 1..3}{/for}{i}", "8:28: undefined: i [VT004]"},
+	}
+	loader := NewGoLoader()
+	for _, test := range tests {
+		_, _, errs := check(t, loader, script+"<div>"+test.tmpl+"</div>")
+		want := ""
+		if test.err != "" {
+			want = "testdata/Hello.html:" + test.err
+		}
+		if got := strings.Join(errs, "\n"); got != want {
+			t.Errorf("%s:\ngot  %q\nwant %q", test.tmpl, got, want)
+		}
+	}
+}
+
+func TestCheckImports(t *testing.T) {
+	tests := []struct {
+		script, err string
+	}{
+		{`import {Admin} from "./user.go"`, "testdata/Hello.html:2:13: Admin is not an exported type of package testdata [VT002]"},
+		{`import {Code} from "./user.go"
+    let code = prop(Code())`, "testdata/Hello.html:3:21: prop code must be a struct, map or slice type, found testdata.Code [VT003]"},
+		{`import {User} from "./missing.go"`, "testdata/Hello.html:2:24: cannot find Go file ./missing.go [VT001]"},
+	}
+	loader := NewGoLoader()
+	for _, test := range tests {
+		_, _, errs := check(t, loader, "<script>\n    "+test.script+"\n</script>\n<div></div>")
+		if got := strings.Join(errs, "\n"); got != test.err {
+			t.Errorf("%s:\ngot  %q\nwant %q", test.script, got, test.err)
+		}
+	}
+}
+
+func TestFieldsAndDoc(t *testing.T) {
+	loader := NewGoLoader()
+	pkg, err := loader.Load("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lookup := func(name string) types.Type { return pkg.Scope().Lookup(name).Type() }
+
+	tests := []struct {
+		typ  types.Type
+		want string
+	}{
+		{lookup("User"), "Name Active Likes Tags Profile Posts Extra"},
+		{types.NewPointer(lookup("User")), "Name Active Likes Tags Profile Posts Extra"},
+		{lookup("Member"), "User Name Role Active Likes Tags Profile Posts Extra"},
+		{lookup("Code"), ""},
+	}
+	for _, test := range tests {
+		var names []string
+		for _, f := range Fields(test.typ) {
+			names = append(names, f.Name())
+		}
+		if got := strings.Join(names, " "); got != test.want {
+			t.Errorf("Fields(%s) = %q, want %q", test.typ, got, test.want)
+		}
+	}
+
+	user := lookup("User")
+	docs := []struct {
+		obj  types.Object
+		want string
+	}{
+		{pkg.Scope().Lookup("User"), "User is a user of the app.\n"},
+		{lookupField(user, "name"), "Name is the name displayed to the other users.\n"},
+		{lookupField(user, "likes"), "count of likes of the posts\n"},
+		{lookupField(user, "tags"), ""},
+		{lookupField(lookup("Member"), "User"), ""},
+		{lookupField(lookup("Member"), "name"), "name in the team\n"},
+	}
+	for _, test := range docs {
+		if got := loader.Doc(test.obj); got != test.want {
+			t.Errorf("Doc(%s) = %q, want %q", test.obj, got, test.want)
+		}
+	}
+}
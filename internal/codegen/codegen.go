@@ -0,0 +1,1069 @@
+// Package codegen generates the Go source of type-checked components.
+//
+// Each component is compiled into one Go file declaring a component type, a
+// props struct derived from its prop() declarations and a Render method.
+// Static HTML runs are written as precomputed byte slices, held in a table
+// which the development server may swap in a running binary; code blocks are
+// evaluated directly on the typed props, so that rendering uses no reflection.
+// Generated code carries //line directives mapping back to the component.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	goast "go/ast"
+	"go/format"
+	goparser "go/parser"
+	gotoken "go/token"
+	"go/types"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/supaleon/vanilla/internal/ast"
+	"github.com/supaleon/vanilla/internal/checker"
+	"github.com/supaleon/vanilla/internal/linker"
+	"github.com/supaleon/vanilla/internal/router"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+// vanillaPath is the import path of the runtime package of generated code.
+const vanillaPath = "github.com/supaleon/vanilla"
+
+// Config configures the code generation.
+type Config struct {
+	Package string             // name of the package of the generated files
+	Routes  *router.Table      // routes of the pages; nil if none
+	Assets  map[string]*Assets // bundles of the pages and layouts by path; nil if none
+	BaseURL string             // prefix of the URLs of the pages, aka "/docs/"; "" for "/"
+}
+
+// Assets are the URLs of the bundles of a page or layout, which the Renderer
+// links in the <head> of the page.
+type Assets struct {
+	Scripts []string // ES modules
+	Styles  []string // stylesheets
+}
+
+// Generate returns the formatted Go source of the component f of the graph g.
+// infos holds the result of [checker.Check] by file path. Child components
+// are referred to by [Name], they are expected to be generated into the same
+// package.
+func (conf *Config) Generate(fset *token.FileSet, g *linker.Graph, infos map[string]*checker.Info, f *linker.File) ([]byte, error) {
+	gen := &generator{
+		conf:    conf,
+		fset:    fset,
+		g:       g,
+		f:       f,
+		infos:   infos,
+		info:    infos[f.Path],
+		used:    make(map[*ast.Ident]bool),
+		name:    Name(f.Path),
+		imports: map[string]string{vanillaPath: "vanilla", "io": "io"},
+		props:   make(map[*ast.Ident]*ast.PropDecl),
+	}
+	if m := f.Component.ESModule; m != nil {
+		for _, d := range m.Props {
+			gen.props[d.Name] = d
+		}
+	}
+	for _, decl := range gen.info.Uses {
+		gen.used[decl] = true
+	}
+	gen.genRender()
+	gen.errors.Sort()
+	if err := gen.errors.Err(); err != nil {
+		return nil, err
+	}
+
+	var decls bytes.Buffer
+	gen.genDecls(&decls)
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by vanilla from %s. DO NOT EDIT.\n\n", f.Path)
+	fmt.Fprintf(&out, "package %s\n\n", conf.Package)
+	out.WriteString("import (\n")
+	paths := make([]string, 0, len(gen.imports))
+	for path := range gen.imports {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		fmt.Fprintf(&out, "\t%s %q\n", gen.imports[path], path)
+	}
+	out.WriteString(")\n\n")
+	out.Write(decls.Bytes())
+	out.Write(gen.body.Bytes())
+	if len(gen.statics) > 0 {
+		fmt.Fprintf(&out, "var %s = vanilla.NewStatics(\n", gen.staticsName())
+		for _, s := range gen.statics {
+			fmt.Fprintf(&out, "%s,\n", strconv.Quote(s))
+		}
+		out.WriteString(")\n")
+	}
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%s: formatting generated code: %v", f.Path, err)
+	}
+	return src, nil
+}
+
+// SplitStatics splits src, a file generated by [Config.Generate], into its
+// code without the static runs of its component nor //line directives, and
+// the runs, along with the name of the component. Two generations of a
+// component of equal code differ in their static HTML only, which the
+// development server swaps in a running binary, see vanilla.Statics. The name
+// and runs are empty if the component has no static runs.
+func SplitStatics(src []byte) (code []byte, name string, runs []string, err error) {
+	fset := gotoken.NewFileSet()
+	f, err := goparser.ParseFile(fset, "", src, goparser.SkipObjectResolution)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	for _, d := range f.Decls {
+		d, ok := d.(*goast.GenDecl)
+		if !ok || d.Tok != gotoken.VAR || len(d.Specs) != 1 {
+			continue
+		}
+		spec := d.Specs[0].(*goast.ValueSpec)
+		if len(spec.Names) != 1 || len(spec.Values) != 1 {
+			continue
+		}
+		call, ok := spec.Values[0].(*goast.CallExpr)
+		if !ok {
+			continue
+		}
+		if sel, ok := call.Fun.(*goast.SelectorExpr); !ok || sel.Sel.Name != "NewStatics" {
+			continue
+		}
+		for _, arg := range call.Args {
+			lit, ok := arg.(*goast.BasicLit)
+			if !ok || lit.Kind != gotoken.STRING {
+				return nil, "", nil, fmt.Errorf("static run is not a string literal")
+			}
+			run, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			runs = append(runs, run)
+		}
+		name = strings.TrimPrefix(spec.Names[0].Name, "_")
+		call.Args = nil
+	}
+	// comments, and so //line directives, are not parsed
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return nil, "", nil, err
+	}
+	return buf.Bytes(), name, runs, nil
+}
+
+type generator struct {
+	conf  *Config
+	fset  *token.FileSet
+	g     *linker.Graph
+	f     *linker.File
+	infos map[string]*checker.Info
+	info  *checker.Info
+	name  string                       // Go name of the component
+	props map[*ast.Ident]*ast.PropDecl // by declaring identifier
+	used  map[*ast.Ident]bool          // used props and loop variables
+
+	imports map[string]string // names by import path
+	body    bytes.Buffer      // render method
+	static  strings.Builder   // pending static run
+	statics []string          // precomputed static runs
+	nvars   int               // number of temporary variables
+	errors  checker.ErrorList
+}
+
+func (gen *generator) errorf(loc token.Loc, code checker.Code, format string, args ...any) {
+	gen.errors.Add(gen.fset.Position(loc), code, fmt.Sprintf(format, args...))
+}
+
+// Name returns the Go name of the component at the given path relative to the
+// project root, aka `BlogPostIndex` for `pages/blog/[post]/Index.html`.
+func Name(path string) string {
+	path = strings.TrimPrefix(path, "pages/")
+	path = strings.TrimSuffix(path, ".html")
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		// `[id=int]` and `[...path]` are named after their parameter
+		if strings.HasPrefix(seg, "[") {
+			seg, _, _ = strings.Cut(strings.Trim(seg, "[]"), "=")
+			segs[i] = strings.TrimPrefix(seg, "...")
+		}
+	}
+	path = strings.Join(segs, "/")
+	var b strings.Builder
+	upper := true
+	for _, r := range path {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if b.Len() == 0 && unicode.IsDigit(r) {
+				b.WriteByte('P')
+			}
+			if upper {
+				r = unicode.ToUpper(r)
+			}
+			b.WriteRune(r)
+			upper = false
+		default:
+			upper = true
+		}
+	}
+	return b.String()
+}
+
+// CheckNames reports the components of the given paths whose Go names, see
+// [Name], collide. Names differing only in case collide as well, since they
+// name the generated files. The error is reported on the path sorted last.
+func CheckNames(paths []string) checker.ErrorList {
+	paths = slices.Sorted(slices.Values(paths))
+	var list checker.ErrorList
+	seen := make(map[string]string) // paths by lower-case name
+	for _, p := range paths {
+		name := Name(p)
+		if prev, ok := seen[strings.ToLower(name)]; ok {
+			list.Add(token.Position{Filename: p, Line: 1, Column: 1}, checker.DuplicateName,
+				fmt.Sprintf("Go name %s of %s collides with %s of %s, rename one of the files", name, p, Name(prev), prev))
+			continue
+		}
+		seen[strings.ToLower(name)] = p
+	}
+	return list
+}
+
+// FieldName returns the name of the props struct field of the prop name, aka
+// `Theme` for `theme`.
+func FieldName(prop string) string {
+	if prop == "" {
+		return prop
+	}
+	return strings.ToUpper(prop[:1]) + prop[1:]
+}
+
+// PropsName returns the name of the props struct of the component name.
+func PropsName(name string) string {
+	return name + "Props"
+}
+
+func (gen *generator) importName(path, name string) string {
+	if n, ok := gen.imports[path]; ok {
+		return n
+	}
+	n := name
+	for i := 2; slices.Contains(slices.Collect(maps.Values(gen.imports)), n); i++ {
+		n = name + strconv.Itoa(i)
+	}
+	gen.imports[path] = n
+	return n
+}
+
+func (gen *generator) typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		return gen.importName(p.Path(), p.Name())
+	})
+}
+
+// staticsName returns the name of the variable holding the static runs.
+func (gen *generator) staticsName() string {
+	return "_" + gen.name
+}
+
+func (gen *generator) tmp() string {
+	gen.nvars++
+	return fmt.Sprintf("v%d", gen.nvars)
+}
+
+// ----------------------------------------------------------------------------
+// Declarations
+
+func (gen *generator) genDecls(out *bytes.Buffer) {
+	name, props := gen.name, PropsName(gen.name)
+	var decls []*ast.PropDecl
+	if m := gen.f.Component.ESModule; m != nil {
+		decls = m.Props
+	}
+
+	fmt.Fprintf(out, "// %s is the component %s.\ntype %s struct{}\n\n", name, gen.f.Path, name)
+	fmt.Fprintf(out, "// %s holds the props of %s.\ntype %s struct {\n", props, name, props)
+	for _, d := range decls {
+		if t := gen.info.Props[d.Name.Name]; t != nil {
+			fmt.Fprintf(out, "\t%s %s\n", FieldName(d.Name.Name), gen.typeString(t))
+		}
+	}
+	out.WriteString("}\n\n")
+
+	fmt.Fprintf(out, "// Default%s returns the props of %s initialized as declared.\n", props, name)
+	fmt.Fprintf(out, "func Default%s() %s {\n\treturn %s{\n", props, props, props)
+	for _, d := range decls {
+		if v := defaultValue(d); v != "" && gen.info.Props[d.Name.Name] != nil {
+			fmt.Fprintf(out, "\t\t%s: %s,\n", FieldName(d.Name.Name), v)
+		}
+	}
+	out.WriteString("\t}\n}\n\n")
+
+	fmt.Fprintf(out, "// Render writes the HTML of %s for props to w.\n", name)
+	fmt.Fprintf(out, "func (c %s) Render(w io.Writer, props %s) error {\n", name, props)
+	out.WriteString("\tvw := vanilla.NewWriter(w)\n\tc.render(vw, props, nil)\n\treturn vw.Err()\n}\n\n")
+
+	route, layout := gen.conf.Routes.Lookup(gen.f.Path), gen.layout()
+	pattern := ""
+	if route != nil {
+		pattern = route.Pattern
+	}
+	out.WriteString("func init() {\n\tvanilla.Register(&vanilla.Component{\n")
+	fmt.Fprintf(out, "\t\tName: %q,\n\t\tPath: %q,\n\t\tRoute: %q,\n\t\tLayout: %q,\n", name, gen.f.Path, pattern, layout)
+	if a := gen.conf.Assets[gen.f.Path]; a != nil {
+		if len(a.Scripts) > 0 {
+			fmt.Fprintf(out, "\t\tScripts: %#v,\n", a.Scripts)
+		}
+		if len(a.Styles) > 0 {
+			fmt.Fprintf(out, "\t\tStyles: %#v,\n", a.Styles)
+		}
+	}
+	out.WriteString("\t\tRender: func(w *vanilla.Writer, props any, ctx *vanilla.Context) error {\n\t\t\tswitch p := props.(type) {\n")
+	fmt.Fprintf(out, "\t\t\tcase nil:\n\t\t\t\t%s{}.render(w, Default%s(), ctx)\n", name, props)
+	fmt.Fprintf(out, "\t\t\tcase %s:\n\t\t\t\t%s{}.render(w, p, ctx)\n", props, name)
+	fmt.Fprintf(out, "\t\t\tcase *%s:\n\t\t\t\t%s{}.render(w, *p, ctx)\n", props, name)
+	fmt.Fprintf(out, "\t\t\tdefault:\n\t\t\t\treturn &vanilla.PropsError{Component: %q, Props: props}\n\t\t\t}\n", name)
+	out.WriteString("\t\t\treturn nil\n\t\t},\n")
+	if len(gen.statics) > 0 {
+		fmt.Fprintf(out, "\t\tStatics: %s,\n", gen.staticsName())
+	}
+	if route != nil {
+		gen.genLoad(out, route)
+	}
+	if route != nil && route.Static != nil {
+		fn := route.Static
+		fmt.Fprintf(out, "\t\tStatic: %s.%s,\n", gen.importName(fn.Pkg().Path(), fn.Pkg().Name()), fn.Name())
+	}
+	if route != nil && len(route.Middleware) > 0 {
+		gen.genMiddleware(out, route.Middleware)
+	}
+	out.WriteString("\t})\n}\n\n")
+	if route != nil {
+		gen.genURL(out, route)
+	}
+}
+
+// genLoad writes the Load function of the page of route r, which checks the
+// types of its parameters and calls its loader, if any.
+func (gen *generator) genLoad(out *bytes.Buffer, r *router.Route) {
+	l := r.Loader
+	typed := slices.ContainsFunc(r.Params, func(p *router.Param) bool { return p.Type != "" })
+	if l == nil && !typed {
+		return
+	}
+	var pkg string
+	if l != nil {
+		pkg = gen.importName(l.Func.Pkg().Path(), l.Func.Pkg().Name())
+	}
+	fmt.Fprintf(out, "\t\tLoad: func(r *%s.Request) (any, error) {\n", gen.importName("net/http", "http"))
+	args := []string{"r"}
+	for _, p := range r.Params {
+		pass := l != nil && l.Params
+		v := gen.paramVar(p)
+		fn, ok := paramFuncs[p.Type]
+		switch {
+		case ok && pass:
+			fmt.Fprintf(out, "\t\t\t%s, ok := vanilla.%s(r, %q)\n\t\t\tif !ok {\n", v, fn, p.Name)
+		case ok:
+			fmt.Fprintf(out, "\t\t\tif _, ok := vanilla.%s(r, %q); !ok {\n", fn, p.Name)
+		case pass:
+			fmt.Fprintf(out, "\t\t\t%s := vanilla.Param(r, %q)\n", v, p.Name)
+		}
+		if ok {
+			out.WriteString("\t\t\t\treturn nil, vanilla.ErrNotFound\n\t\t\t}\n")
+		}
+		args = append(args, v)
+	}
+	if l == nil {
+		fmt.Fprintf(out, "\t\t\treturn Default%s(), nil\n\t\t},\n", PropsName(gen.name))
+		return
+	}
+	if !l.Params {
+		args = args[:1]
+	}
+	fmt.Fprintf(out, "\t\t\tv, err := %s.%s(%s)\n", pkg, l.Func.Name(), strings.Join(args, ", "))
+	out.WriteString("\t\t\tif err != nil {\n\t\t\t\treturn nil, err\n\t\t\t}\n")
+	fmt.Fprintf(out, "\t\t\tp := Default%s()\n", PropsName(gen.name))
+	if l.Ptr && len(l.Fields) > 0 {
+		out.WriteString("\t\t\tif v != nil {\n")
+	}
+	for _, f := range l.Fields {
+		fmt.Fprintf(out, "\t\t\tp.%s = v.%s\n", FieldName(f.Prop), f.Name)
+	}
+	if l.Ptr && len(l.Fields) > 0 {
+		out.WriteString("\t\t\t}\n")
+	}
+	out.WriteString("\t\t\treturn p, nil\n\t\t},\n")
+}
+
+// paramFuncs holds the runtime functions checking route parameters by type.
+var paramFuncs = map[string]string{
+	router.Int:  "IntParam",
+	router.UUID: "UUIDParam",
+	router.Slug: "SlugParam",
+}
+
+// paramVar returns the name of the Go variable of the route parameter p,
+// which must not shadow the keywords, imports and locals of generated code.
+func (gen *generator) paramVar(p *router.Param) string {
+	switch {
+	case gotoken.IsKeyword(p.Name), slices.Contains(slices.Collect(maps.Values(gen.imports)), p.Name),
+		slices.Contains([]string{"r", "v", "p", "ok", "err"}, p.Name):
+		return "p_" + p.Name
+	}
+	return p.Name
+}
+
+// genURL writes the function returning the URL of the page of route r for
+// the values of its parameters, aka `BlogIdIndexURL(id int) string`.
+func (gen *generator) genURL(out *bytes.Buffer, r *router.Route) {
+	var params, parts []string
+	lit := strings.TrimSuffix(gen.conf.BaseURL, "/")
+	i := 0
+	for _, seg := range strings.Split(r.Pattern, "/")[1:] {
+		lit += "/"
+		if seg == "" || (seg[0] != ':' && seg[0] != '*') {
+			lit += seg
+			continue
+		}
+		p := r.Params[i]
+		i++
+		v := gen.paramVar(p)
+		params = append(params, v+" "+p.GoType().String())
+		parts = append(parts, strconv.Quote(lit))
+		lit = ""
+		switch {
+		case p.Type == router.Int:
+			parts = append(parts, gen.importName("strconv", "strconv")+".Itoa("+v+")")
+		case p.CatchAll:
+			parts = append(parts, "vanilla.EscapePath("+v+")")
+		default:
+			parts = append(parts, gen.importName("net/url", "url")+".PathEscape("+v+")")
+		}
+	}
+	if lit != "" {
+		parts = append(parts, strconv.Quote(lit))
+	}
+	fmt.Fprintf(out, "// %sURL returns the URL of %s for the given parameters.\n", gen.name, gen.name)
+	fmt.Fprintf(out, "func %sURL(%s) string {\n\treturn %s\n}\n\n", gen.name, strings.Join(params, ", "), strings.Join(parts, " + "))
+}
+
+// genMiddleware writes the Middleware function of the page composing the
+// middleware list, outermost first.
+func (gen *generator) genMiddleware(out *bytes.Buffer, list []*types.Func) {
+	http := gen.importName("net/http", "http")
+	fmt.Fprintf(out, "\t\tMiddleware: func(h %s.Handler) %s.Handler {\n\t\t\treturn ", http, http)
+	for _, fn := range list {
+		fmt.Fprintf(out, "%s.%s(", gen.importName(fn.Pkg().Path(), fn.Pkg().Name()), fn.Name())
+	}
+	out.WriteString("h" + strings.Repeat(")", len(list)) + "\n\t\t},\n")
+}
+
+// layout returns the Go name of the layout wrapping the component, that is
+// the nearest layout file up the directory chain of a page, or above the
+// directory of a layout; "" if there is none or the component opts out.
+func (gen *generator) layout() string {
+	dir := path.Dir(gen.f.Path)
+	switch {
+	case path.Base(gen.f.Path) == checker.LayoutFile:
+		dir = path.Dir(dir)
+	case gen.conf.Routes.Lookup(gen.f.Path) == nil:
+		return ""
+	}
+	if checker.NoLayout(gen.f.Component) {
+		return ""
+	}
+	for ; dir == "pages" || strings.HasPrefix(dir, "pages/"); dir = path.Dir(dir) {
+		if p := dir + "/" + checker.LayoutFile; gen.g.File(p) != nil {
+			return Name(p)
+		}
+	}
+	return ""
+}
+
+// defaultValue returns the Go expression of the initial value of d, or "" for
+// the zero value.
+func defaultValue(d *ast.PropDecl) string {
+	switch d.Kind {
+	case ast.PropBool, ast.PropInt, ast.PropFloat:
+		return d.Value
+	case ast.PropString:
+		return goString(d.Value)
+	case ast.PropArray:
+		return "[]any{}"
+	case ast.PropObject:
+		return "map[string]any{}"
+	}
+	return ""
+}
+
+// goString converts a JS string literal to a Go one.
+func goString(lit string) string {
+	if len(lit) < 2 {
+		return `""`
+	}
+	s := lit[1 : len(lit)-1]
+	if lit[0] == '"' {
+		return lit
+	}
+	s = strings.ReplaceAll(s, `\'`, `'`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// ----------------------------------------------------------------------------
+// Render method
+
+func (gen *generator) genRender() {
+	gen.genNodes(gen.f.Component.Template.Nodes)
+	gen.flush()
+	body := bytes.Clone(gen.body.Bytes())
+	gen.body.Reset()
+	fmt.Fprintf(&gen.body, "func (%s) render(w *vanilla.Writer, props %s, ctx *vanilla.Context) {\n", gen.name, PropsName(gen.name))
+	if len(gen.statics) > 0 {
+		// loaded once, see vanilla.Statics
+		fmt.Fprintf(&gen.body, "static := %s.Runs()\n", gen.staticsName())
+	}
+	gen.body.Write(body)
+	gen.body.WriteString("}\n\n")
+}
+
+// flush writes the pending static run.
+func (gen *generator) flush() {
+	if gen.static.Len() == 0 {
+		return
+	}
+	gen.statics = append(gen.statics, gen.static.String())
+	gen.static.Reset()
+	fmt.Fprintf(&gen.body, "w.WriteRaw(static[%d])\n", len(gen.statics)-1)
+}
+
+// stmt writes a statement of the code block at loc, preceded by a //line
+// directive.
+func (gen *generator) stmt(loc token.Loc, format string, args ...any) {
+	gen.flush()
+	gen.line(loc)
+	fmt.Fprintf(&gen.body, format+"\n", args...)
+}
+
+func (gen *generator) line(loc token.Loc) {
+	pos := gen.fset.Position(loc)
+	fmt.Fprintf(&gen.body, "//line %s:%d:%d\n", gen.f.Path, pos.Line, pos.Column)
+}
+
+func (gen *generator) genNodes(nodes []ast.Node) {
+	for _, n := range nodes {
+		gen.genNode(n)
+	}
+}
+
+func (gen *generator) genNode(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.Text:
+		gen.static.WriteString(n.Value)
+	case *ast.Comment:
+		gen.static.WriteString(n.Value)
+	case *ast.Element:
+		gen.genElement(n)
+	case *ast.Interpolation:
+		gen.genValue(n.X)
+	case *ast.ConditionalText:
+		gen.stmt(n.LBrace, "if %s {", gen.expr(n.X))
+		gen.static.WriteString(n.Text)
+		gen.flush()
+		gen.body.WriteString("}\n")
+	case *ast.FormatSpec:
+		fmtName := gen.importName("fmt", "fmt")
+		gen.stmt(n.LBrace, "w.WriteEscaped(%s.Sprintf(%s, %s))", fmtName, strconv.Quote("%"+n.Format), gen.expr(n.X))
+	case *ast.IfBlock:
+		gen.stmt(n.Start, "if %s {", gen.expr(n.Cond))
+		gen.genNodes(n.Then)
+		gen.flush()
+		if n.ElseLoc.IsValid() {
+			gen.body.WriteString("} else {\n")
+			gen.genNodes(n.Else)
+			gen.flush()
+		}
+		gen.body.WriteString("}\n")
+	case *ast.ForBlock:
+		gen.genFor(n)
+	}
+}
+
+func (gen *generator) genFor(b *ast.ForBlock) {
+	key := gen.localName(b.Key)
+	if r, ok := b.X.(*ast.Range); ok {
+		// {for i in 1..9} or {for i, v in 1..9}
+		v := key
+		if b.Value != nil {
+			v = gen.localName(b.Value)
+		}
+		if v == "_" {
+			v = gen.tmp()
+		}
+		if b.Value != nil && key != "_" {
+			gen.stmt(b.Start, "for %s, %s := 0, %s; %s <= %s; %s, %s = %s+1, %s+1 {",
+				key, v, gen.expr(r.Low), v, gen.expr(r.High), key, v, key, v)
+		} else {
+			gen.stmt(b.Start, "for %s := %s; %s <= %s; %s++ {", v, gen.expr(r.Low), v, gen.expr(r.High), v)
+		}
+	} else if b.Value != nil && gen.localName(b.Value) != "_" {
+		gen.stmt(b.Start, "for %s, %s := range %s {", key, gen.localName(b.Value), gen.expr(b.X))
+	} else if key == "_" {
+		gen.stmt(b.Start, "for range %s {", gen.expr(b.X))
+	} else {
+		gen.stmt(b.Start, "for %s := range %s {", key, gen.expr(b.X))
+	}
+	gen.genNodes(b.Body)
+	gen.flush()
+	gen.body.WriteString("}\n")
+}
+
+// localName returns the Go name of the loop variable id, or "_" if id is
+// not used.
+func (gen *generator) localName(id *ast.Ident) string {
+	if id.Name == "_" || !gen.used[id] {
+		return "_"
+	}
+	return "v_" + id.Name
+}
+
+// voidElements are the elements without end tag.
+var voidElements = []string{"area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "param", "source", "track", "wbr"}
+
+func (gen *generator) genElement(e *ast.Element) {
+	switch {
+	case e.Name == "metadata" || e.Name == "fragment":
+		// empty wrapper of the template
+		gen.genNodes(e.Children)
+		return
+	case e.Name == "context":
+		gen.genContext(e)
+		return
+	case e.Name == "slot":
+		gen.genSlot(e)
+		return
+	case linker.IsComponentTag(e.Name):
+		gen.genComponent(e)
+		return
+	}
+
+	gen.static.WriteString("<" + e.Name)
+	for _, a := range e.Attrs {
+		gen.genAttr(a)
+	}
+	if e.SelfClosing {
+		gen.static.WriteString("/>")
+		return
+	}
+	gen.static.WriteString(">")
+	if slices.Contains(voidElements, strings.ToLower(e.Name)) {
+		return
+	}
+	gen.genNodes(e.Children)
+	gen.static.WriteString("</" + e.Name + ">")
+}
+
+func (gen *generator) genAttr(a *ast.Attribute) {
+	switch a.Kind {
+	case ast.AttrStatic:
+		gen.static.WriteString(" " + a.Name)
+		if a.Value != nil {
+			gen.static.WriteString("=")
+			gen.writeQuoted(a, func() {
+				for _, part := range a.Value {
+					gen.genNode(part)
+				}
+			})
+		}
+	case ast.AttrExpr:
+		x := a.Value[0].(*ast.Interpolation).X
+		if t := gen.info.TypeOf(x); t != nil && isBoolean(t) {
+			// boolean attribute, aka `disabled={!user.active}`
+			gen.stmt(a.NameLoc, "if %s {", gen.expr(x))
+			gen.static.WriteString(" " + a.Name)
+			gen.flush()
+			gen.body.WriteString("}\n")
+			return
+		}
+		gen.static.WriteString(" " + a.Name + `="`)
+		gen.genValue(x)
+		gen.static.WriteString(`"`)
+	case ast.AttrInterp:
+		gen.static.WriteString(" " + a.Name + "=")
+		gen.writeQuoted(a, func() {
+			for _, part := range a.Value {
+				gen.genNode(part)
+			}
+		})
+	}
+}
+
+func (gen *generator) writeQuoted(a *ast.Attribute, value func()) {
+	if a.Quote != 0 {
+		gen.static.WriteByte(a.Quote)
+	}
+	value()
+	if a.Quote != 0 {
+		gen.static.WriteByte(a.Quote)
+	}
+}
+
+// genValue writes the statement rendering the value of x.
+func (gen *generator) genValue(x ast.Expr) {
+	if call, ok := x.(*ast.Call); ok && call.Fun.Name == "escape" {
+		gen.stmt(x.Range().Start, "w.WriteString(%s)", gen.expr(x))
+		return
+	}
+	t := gen.info.TypeOf(x)
+	if t == nil {
+		return // reported by the checker
+	}
+	if !hasInfo(t, types.IsString|types.IsBoolean|types.IsNumeric) && !isStringer(t) && !isInterface(t) {
+		gen.errorf(x.Range().Start, checker.UnrenderableValue, "cannot render %s (%s), only basic types, fmt.Stringer and any values can be rendered",
+			ast.ExprString(x), gen.typeString(t))
+		return
+	}
+	switch {
+	case isInterface(t) && !isStringer(t):
+		gen.stmt(x.Range().Start, "w.WriteAny(%s)", gen.expr(x))
+	case hasInfo(t, types.IsBoolean):
+		gen.stmt(x.Range().Start, "w.WriteBool(%s)", convert("bool", t, gen.expr(x)))
+	case hasInfo(t, types.IsUnsigned):
+		gen.stmt(x.Range().Start, "w.WriteUint(%s)", convert("uint64", t, gen.expr(x)))
+	case hasInfo(t, types.IsInteger):
+		gen.stmt(x.Range().Start, "w.WriteInt(%s)", convert("int64", t, gen.expr(x)))
+	case hasInfo(t, types.IsFloat):
+		gen.stmt(x.Range().Start, "w.WriteFloat(%s, %d)", convert("float64", t, gen.expr(x)), floatSize(t))
+	default:
+		s, _ := gen.stringExpr(x, t)
+		gen.stmt(x.Range().Start, "w.WriteEscaped(%s)", s)
+	}
+}
+
+// stringExpr returns the Go expression of the string form of x of type t.
+func (gen *generator) stringExpr(x ast.Expr, t types.Type) (string, bool) {
+	e := gen.expr(x)
+	switch {
+	case hasInfo(t, types.IsString):
+		return convert("string", t, e), true
+	case isStringer(t):
+		return e + ".String()", true
+	case isInterface(t):
+		return gen.importName("fmt", "fmt") + ".Sprint(" + e + ")", true
+	case hasInfo(t, types.IsBoolean):
+		return gen.importName("strconv", "strconv") + ".FormatBool(" + convert("bool", t, e) + ")", true
+	case hasInfo(t, types.IsUnsigned):
+		return gen.importName("strconv", "strconv") + ".FormatUint(" + convert("uint64", t, e) + ", 10)", true
+	case hasInfo(t, types.IsInteger):
+		return gen.importName("strconv", "strconv") + ".FormatInt(" + convert("int64", t, e) + ", 10)", true
+	case hasInfo(t, types.IsFloat):
+		return fmt.Sprintf("%s.FormatFloat(%s, 'g', -1, %d)", gen.importName("strconv", "strconv"), convert("float64", t, e), floatSize(t)), true
+	}
+	return "", false
+}
+
+// convert returns the expression e of type t converted to the basic type
+// named to, unless it is already of that type.
+func convert(to string, t types.Type, e string) string {
+	if b, ok := t.(*types.Basic); ok && (b.Name() == to || b.Info()&types.IsUntyped != 0) {
+		return e
+	}
+	return to + "(" + e + ")"
+}
+
+func floatSize(t types.Type) int {
+	if b, ok := t.Underlying().(*types.Basic); ok && b.Kind() == types.Float32 {
+		return 32
+	}
+	return 64
+}
+
+func hasInfo(t types.Type, info types.BasicInfo) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&info != 0
+}
+
+func isBoolean(t types.Type) bool { return hasInfo(t, types.IsBoolean) }
+
+func isInterface(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Interface)
+	return ok
+}
+
+// isStringer reports whether t implements fmt.Stringer.
+func isStringer(t types.Type) bool {
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, "String")
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+	sig := fn.Type().(*types.Signature)
+	return sig.Params().Len() == 0 && sig.Results().Len() == 1 && types.Identical(sig.Results().At(0).Type(), types.Typ[types.String])
+}
+
+// ----------------------------------------------------------------------------
+// Components and contexts
+
+// genComponent writes the call of the component element e.
+func (gen *generator) genComponent(e *ast.Element) {
+	target := gen.g.Resolve(gen.f.Path, e.Name)
+	if target == nil {
+		return // reported by the linker
+	}
+	name := Name(target.Path)
+	gen.stmt(e.Start, "{")
+	gen.body.WriteString("p := Default" + PropsName(name) + "()\n")
+	var decls []*ast.PropDecl
+	if m := target.Component.ESModule; m != nil {
+		decls = m.Props
+	}
+	var props map[string]types.Type
+	if info := gen.infos[target.Path]; info != nil {
+		props = info.Props
+	}
+	for _, d := range decls {
+		field, t := FieldName(d.Name.Name), props[d.Name.Name]
+		if t == nil {
+			continue
+		}
+		i := slices.IndexFunc(e.Attrs, func(a *ast.Attribute) bool { return a.Name == d.Name.Name })
+		if i < 0 {
+			fmt.Fprintf(&gen.body, "if v, ok := vanilla.Lookup[%s](ctx, %q); ok {\np.%s = v\n}\n", gen.typeString(t), d.Name.Name, field)
+			continue
+		}
+		a := e.Attrs[i]
+		gen.line(a.NameLoc)
+		fmt.Fprintf(&gen.body, "p.%s = %s\n", field, gen.attrValue(a, t))
+	}
+	slots := target.Component.Slots()
+	if len(slots) == 0 {
+		gen.body.WriteString(name + "{}.render(w, p, ctx)\n}\n")
+		return
+	}
+	content := slotContent(e, slots)
+	if len(content) == 0 {
+		gen.body.WriteString(name + "{}.render(w, p, ctx.WithSlots(nil))\n}\n")
+		return
+	}
+	gen.body.WriteString(name + "{}.render(w, p, ctx.WithSlots(map[string]vanilla.Slot{\n")
+	for _, slot := range slices.Sorted(maps.Keys(content)) {
+		fmt.Fprintf(&gen.body, "%q: func(w *vanilla.Writer) {\n", slot)
+		gen.genNodes(content[slot])
+		gen.flush()
+		gen.body.WriteString("},\n")
+	}
+	gen.body.WriteString("}))\n}\n")
+}
+
+// slotContent returns the content of the component element e by name of the
+// slots of its component: the children with a slot attribute go to the named
+// slot, without the attribute, and the others to the default slot. Blank
+// content is left out.
+func slotContent(e *ast.Element, slots []string) map[string][]ast.Node {
+	content := make(map[string][]ast.Node)
+	for _, n := range e.Children {
+		name := ""
+		if c, ok := n.(*ast.Element); ok && c.SlotName() != "" {
+			name = c.SlotName()
+			clone := *c
+			clone.Attrs = slices.DeleteFunc(slices.Clone(c.Attrs), func(a *ast.Attribute) bool { return a.Name == "slot" })
+			n = &clone
+		}
+		if slices.Contains(slots, name) {
+			content[name] = append(content[name], n)
+		}
+	}
+	maps.DeleteFunc(content, func(_ string, nodes []ast.Node) bool { return isBlank(nodes) })
+	return content
+}
+
+// genSlot writes the rendering of the <slot> element e, or of its fallback
+// content if no content is passed to the slot.
+func (gen *generator) genSlot(e *ast.Element) {
+	if isBlank(e.Children) {
+		gen.stmt(e.Start, "vanilla.RenderSlot(w, ctx, %q)", e.SlotName())
+		return
+	}
+	gen.stmt(e.Start, "if !vanilla.RenderSlot(w, ctx, %q) {", e.SlotName())
+	gen.genNodes(e.Children)
+	gen.flush()
+	gen.body.WriteString("}\n")
+}
+
+// isBlank reports whether nodes are whitespace text only.
+func isBlank(nodes []ast.Node) bool {
+	for _, n := range nodes {
+		if t, ok := n.(*ast.Text); !ok || strings.TrimSpace(t.Value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// genContext writes the block of the <context> element e.
+func (gen *generator) genContext(e *ast.Element) {
+	gen.stmt(e.Start, "{")
+	values := "ctx"
+	for _, a := range e.Attrs {
+		values += fmt.Sprintf(".With(%q, %s)", a.Name, gen.attrValue(a, gen.attrType(a)))
+	}
+	gen.body.WriteString("ctx := " + values + "\n")
+	gen.genNodes(e.Children)
+	gen.flush()
+	gen.body.WriteString("}\n")
+}
+
+// attrType returns the type of the value of a, or nil if unknown.
+func (gen *generator) attrType(a *ast.Attribute) types.Type {
+	if a.Kind != ast.AttrStatic && len(a.Value) == 1 {
+		if x, ok := a.Value[0].(*ast.Interpolation); ok {
+			return gen.info.TypeOf(x.X)
+		}
+	}
+	if a.Kind == ast.AttrStatic && a.Value == nil {
+		return types.Typ[types.Bool]
+	}
+	return types.Typ[types.String]
+}
+
+// attrValue returns the Go expression of the value of a assigned to type t.
+// Interpolated values are built by statements written before.
+func (gen *generator) attrValue(a *ast.Attribute, t types.Type) string {
+	switch {
+	case a.Value == nil:
+		return "true"
+	case a.Kind == ast.AttrStatic:
+		text := a.Value[0].(*ast.Text).Value
+		if t == nil || hasInfo(t, types.IsString) || isInterface(t) {
+			return strconv.Quote(text)
+		}
+		return text // checked by the linker, aka `size=2`
+	case len(a.Value) == 1:
+		if x, ok := a.Value[0].(*ast.Interpolation); ok {
+			return gen.expr(x.X)
+		}
+	}
+
+	// `class="dark {theme}"`
+	sb := gen.tmp()
+	fmt.Fprintf(&gen.body, "var %s %s.Builder\n", sb, gen.importName("strings", "strings"))
+	for _, part := range a.Value {
+		switch part := part.(type) {
+		case *ast.Text:
+			fmt.Fprintf(&gen.body, "%s.WriteString(%s)\n", sb, strconv.Quote(part.Value))
+		case *ast.Interpolation:
+			if xt := gen.info.TypeOf(part.X); xt != nil {
+				if s, ok := gen.stringExpr(part.X, xt); ok {
+					fmt.Fprintf(&gen.body, "%s.WriteString(%s)\n", sb, s)
+				}
+			}
+		case *ast.ConditionalText:
+			fmt.Fprintf(&gen.body, "if %s {\n%s.WriteString(%s)\n}\n", gen.expr(part.X), sb, strconv.Quote(part.Text))
+		case *ast.FormatSpec:
+			fmt.Fprintf(&gen.body, "%s.WriteString(%s.Sprintf(%s, %s))\n", sb, gen.importName("fmt", "fmt"), strconv.Quote("%"+part.Format), gen.expr(part.X))
+		}
+	}
+	return sb + ".String()"
+}
+
+// ----------------------------------------------------------------------------
+// Expressions
+
+// expr returns the Go expression of x.
+func (gen *generator) expr(x ast.Expr) string {
+	switch x := x.(type) {
+	case *ast.Ident:
+		decl := gen.info.Uses[x]
+		if d, ok := gen.props[decl]; ok {
+			return "props." + FieldName(d.Name.Name)
+		}
+		return "v_" + x.Name
+	case *ast.BasicLit:
+		return x.Value
+	case *ast.Paren:
+		return "(" + gen.expr(x.X) + ")"
+	case *ast.Selector:
+		if f, ok := gen.info.Fields[x]; ok {
+			return gen.expr(x.X) + "." + f.Name()
+		}
+		// user.profile.city is profile["city"]
+		return gen.expr(x.X) + "[" + strconv.Quote(x.Sel.Name) + "]"
+	case *ast.Index:
+		return gen.expr(x.X) + "[" + gen.expr(x.Index) + "]"
+	case *ast.Call:
+		if x.Fun.Name == "url" {
+			return gen.urlCall(x)
+		}
+		var args []string
+		for _, arg := range x.Args {
+			args = append(args, gen.expr(arg))
+		}
+		fun := x.Fun.Name
+		if fun == "escape" {
+			fun = "vanilla.EscapeString"
+		}
+		return fun + "(" + strings.Join(args, ", ") + ")"
+	case *ast.Unary:
+		return x.Op.String() + gen.expr(x.X)
+	case *ast.Binary:
+		return gen.expr(x.X) + " " + x.Op.String() + " " + gen.expr(x.Y)
+	}
+	return "nil"
+}
+
+// urlCall returns the Go expression of the call `url(Page, args...)`, which
+// calls the URL function of the page with the arguments converted to the
+// types of its parameters.
+func (gen *generator) urlCall(x *ast.Call) string {
+	id, _ := x.Args[0].(*ast.Ident)
+	if id == nil {
+		return `""` // reported by the checker
+	}
+	var route *router.Route
+	if t := gen.conf.Routes; t != nil {
+		i := slices.IndexFunc(t.Routes, func(r *router.Route) bool { return Name(r.Page) == id.Name })
+		if i >= 0 {
+			route = t.Routes[i]
+		}
+	}
+	if route == nil {
+		gen.errorf(id.NameLoc, checker.InvalidURL, "url of %s undefined (%s is not a page)", id.Name, id.Name)
+		return `""`
+	}
+	args := x.Args[1:]
+	if len(args) != len(route.Params) {
+		gen.errorf(x.Fun.NameLoc, checker.InvalidURL, "url of %s expects %d arguments after the page, got %d", id.Name, len(route.Params), len(args))
+		return `""`
+	}
+	var list []string
+	for i, arg := range args {
+		p, t := route.Params[i], gen.info.TypeOf(arg)
+		if t == nil {
+			return `""` // reported by the checker
+		}
+		switch {
+		case p.Type == router.Int && hasInfo(t, types.IsInteger):
+			list = append(list, convert("int", t, gen.expr(arg)))
+		case p.Type == router.Int:
+			gen.errorf(arg.Range().Start, checker.InvalidURL, "cannot use %s (%s) as parameter %s of %s, want an integer",
+				ast.ExprString(arg), gen.typeString(t), p.Name, id.Name)
+		case hasInfo(t, types.IsString|types.IsInteger):
+			s, _ := gen.stringExpr(arg, t)
+			list = append(list, s)
+		default:
+			gen.errorf(arg.Range().Start, checker.InvalidURL, "cannot use %s (%s) as parameter %s of %s, want a string or an integer",
+				ast.ExprString(arg), gen.typeString(t), p.Name, id.Name)
+		}
+	}
+	return Name(route.Page) + "URL(" + strings.Join(list, ", ") + ")"
+}
@@ -0,0 +1,265 @@
+package codegen
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/supaleon/vanilla/internal/checker"
+	"github.com/supaleon/vanilla/internal/linker"
+	"github.com/supaleon/vanilla/internal/parser"
+	"github.com/supaleon/vanilla/internal/router"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+func TestName(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{"pages/Index.html", "Index"},
+		{"pages/SideBar.html", "SideBar"},
+		{"pages/blog/Index.html", "BlogIndex"},
+		{"pages/blog/[post]/Index.html", "BlogPostIndex"},
+		{"pages/blog/[id=int]/Index.html", "BlogIdIndex"},
+		{"pages/docs/[...path]/Index.html", "DocsPathIndex"},
+		{"pages/my-blog/2024/Index.html", "MyBlog2024Index"},
+		{"pages/404.html", "P404"},
+	}
+	for _, test := range tests {
+		if got := Name(test.path); got != test.want {
+			t.Errorf("Name(%q) = %q; want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestCheckNames(t *testing.T) {
+	tests := []struct {
+		paths []string
+		want  string
+	}{
+		{[]string{"pages/Index.html", "pages/blog/Index.html", "pages/BlogCard.html"}, ""},
+		{[]string{"pages/blog/Card.html", "pages/BlogCard.html"},
+			"pages/blog/Card.html:1:1: Go name BlogCard of pages/blog/Card.html collides with BlogCard of pages/BlogCard.html, rename one of the files [VG002]"},
+		{[]string{"pages/my-blog/Index.html", "pages/myBlog/Index.html"},
+			"pages/myBlog/Index.html:1:1: Go name MyBlogIndex of pages/myBlog/Index.html collides with MyBlogIndex of pages/my-blog/Index.html, rename one of the files [VG002]"},
+		{[]string{"pages/BlogCard.html", "pages/Blogcard.html"},
+			"pages/Blogcard.html:1:1: Go name Blogcard of pages/Blogcard.html collides with BlogCard of pages/BlogCard.html, rename one of the files [VG002]"},
+	}
+	for _, test := range tests {
+		var errs []string
+		for _, e := range CheckNames(test.paths) {
+			errs = append(errs, e.Error())
+		}
+		if got := strings.Join(errs, "\n"); got != test.want {
+			t.Errorf("CheckNames(%q):\ngot  %s\nwant %s", test.paths, got, test.want)
+		}
+	}
+}
+
+// generate compiles the components in the pages/ directory of root into
+// package gen of root.
+func generate(t *testing.T, root string) {
+	t.Helper()
+	fset := token.NewFileSet()
+	var paths []string
+	filepath.WalkDir(filepath.Join(root, "pages"), func(p string, d fs.DirEntry, err error) error {
+		if err == nil && filepath.Ext(p) == ".html" {
+			paths = append(paths, p)
+		}
+		return err
+	})
+	var files []*linker.File
+	infos := make(map[string]*checker.Info)
+	loader := checker.NewGoLoader()
+	for _, p := range paths {
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		src, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := parser.ParseFile(fset, rel, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, list := checker.Check(fset, p, c, loader)
+		if list != nil {
+			t.Fatal(list)
+		}
+		files = append(files, &linker.File{Path: rel, Component: c})
+		infos[rel] = info
+	}
+	g, list := linker.Link(fset, files)
+	if list != nil {
+		t.Fatal(list)
+	}
+	if list := g.CheckProps(fset, infos); list != nil {
+		t.Fatal(list)
+	}
+	var rels []string
+	for _, f := range files {
+		rels = append(rels, f.Path)
+	}
+	routes, list := router.Build(root, rels, infos, loader)
+	if list != nil {
+		t.Fatal(list)
+	}
+
+	conf := &Config{Package: "gen", Routes: routes}
+	if err := os.MkdirAll(filepath.Join(root, "gen"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		src, err := conf.Generate(fset, g, infos, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(src), "//line "+f.Path+":") {
+			t.Errorf("%s: missing //line directives", f.Path)
+		}
+		name := filepath.Join(root, "gen", strings.ToLower(Name(f.Path))+".go")
+		if err := os.WriteFile(name, src, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping go run in short mode")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	module, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// example.com/app with the components of testdata
+	root := t.TempDir()
+	if err := os.CopyFS(root, os.DirFS("testdata")); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := os.ReadFile(filepath.Join(module, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.24\n\nrequire github.com/supaleon/vanilla v0.0.0\n\nreplace github.com/supaleon/vanilla => " + module + "\n",
+		"go.sum": string(sum),
+		"main.go": `package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+
+	"example.com/app/gen"
+	"github.com/supaleon/vanilla"
+)
+
+func main() {
+	r := vanilla.NewRenderer()
+	rec := httptest.NewRecorder()
+	vanilla.NewRouter(r).ServeHTTP(rec, httptest.NewRequest("GET", "/?name=<Ann>", nil))
+	fmt.Println(rec.Code, rec.Header().Get("Content-Type"), rec.Header().Get("X-Page"))
+	fmt.Println(rec.Body.String())
+	for _, path := range []string{"/post/7", "/post/x", "/plain"} {
+		rec := httptest.NewRecorder()
+		vanilla.NewRouter(r).ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		fmt.Println(rec.Code, rec.Body.String())
+	}
+	fmt.Println(gen.PostIdIndexURL(-1))
+	if err := r.RenderFragment(os.Stdout, "Card", &gen.CardProps{Title: "t", Theme: "x"}); err != nil {
+		panic(err)
+	}
+	fmt.Println()
+	fmt.Print(r.Render(os.Stdout, "Card", gen.IndexProps{}))
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	generate(t, root)
+
+	cmd := exec.Command(gobin, "run", ".")
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run: %v\n%s", err, out)
+	}
+	const want = `200 text/html; charset=utf-8 /
+<html>
+    <header>Home</header>
+    <body><div class="page dark" id=main>
+    <h1>&lt;Ann&gt;</h1>
+    <ul><li data-i="0">a</li><li data-i="1">b</li></ul>
+    123
+    <input>
+    <section class="dark">&lt;Ann&gt; 4.25 <b>dark</b>end</section>
+    PRO 4.2
+    <a href="/post/2">post</a>
+</div></body>
+</html>
+200 <html>
+    <header>Home</header>
+    <body><main><article data-id="7">Post 7</article></main></body>
+</html>
+404 404 page not found
+
+200 <p>plain</p>
+/post/-1
+<section class="x">t 0<i>none</i></section>
+vanilla: cannot render Card with props of type gen.IndexProps`
+	if got := string(out); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSplitStatics(t *testing.T) {
+	gen := func(line int, runs ...string) []byte {
+		var b strings.Builder
+		fmt.Fprintf(&b, "package gen\n\nimport \"github.com/supaleon/vanilla\"\n\ntype Index struct{}\n\nfunc (Index) render(w *vanilla.Writer) {\n\tstatic := _Index.Runs()\n//line pages/Index.html:%d:1\n\tw.WriteRaw(static[0])\n}\n\nvar _Index = vanilla.NewStatics(\n", line)
+		for _, r := range runs {
+			fmt.Fprintf(&b, "\t%q,\n", r)
+		}
+		b.WriteString(")\n")
+		return []byte(b.String())
+	}
+	code1, name, runs, err := SplitStatics(gen(4, "<p>home</p>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "Index" || !reflect.DeepEqual(runs, []string{"<p>home</p>"}) {
+		t.Errorf("got %q %q, want Index [<p>home</p>]", name, runs)
+	}
+	code2, _, runs, err := SplitStatics(gen(5, "<p>\n    changed\n</p>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(runs, []string{"<p>\n    changed\n</p>"}) {
+		t.Errorf("got runs %q", runs)
+	}
+	if string(code1) != string(code2) {
+		t.Errorf("code differs:\n%s\n%s", code1, code2)
+	}
+	if strings.Contains(string(code1), "home") || strings.Contains(string(code1), "//line") {
+		t.Errorf("code keeps the runs or //line directives:\n%s", code1)
+	}
+
+	_, name, runs, err = SplitStatics([]byte("package gen\n\nvar x = 1\n"))
+	if err != nil || name != "" || runs != nil {
+		t.Errorf("without statics: got %q %q %v", name, runs, err)
+	}
+}
@@ -0,0 +1,15 @@
+package post
+
+import (
+	"net/http"
+	"strconv"
+)
+
+type Post struct {
+	Id    int32
+	Title string
+}
+
+func LoadId(r *http.Request, id int) (*Post, error) {
+	return &Post{Id: int32(id), Title: "Post " + strconv.Itoa(id)}, nil
+}
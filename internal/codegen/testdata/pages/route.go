@@ -0,0 +1,19 @@
+package pages
+
+import "net/http"
+
+type Page struct {
+	User User
+}
+
+func Load(r *http.Request) (Page, error) {
+	name := r.URL.Query().Get("name")
+	return Page{User: User{Name: name, Active: true, Pro: true, Score: 4.25, Tags: []string{"a", "b"}, Likes: 2}}, nil
+}
+
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Page", r.URL.Path)
+		next.ServeHTTP(w, r)
+	})
+}
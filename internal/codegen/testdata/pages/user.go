@@ -0,0 +1,10 @@
+package pages
+
+type User struct {
+	Name   string
+	Active bool
+	Pro    bool
+	Score  float32
+	Tags   []string
+	Likes  int
+}
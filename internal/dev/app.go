@@ -0,0 +1,98 @@
+package dev
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/supaleon/vanilla"
+)
+
+// startTimeout is the time a binary is given to listen on its port.
+var startTimeout = 30 * time.Second
+
+// app is a running binary of the project.
+type app struct {
+	bin      string
+	cmd      *exec.Cmd
+	url      *url.URL      // of the server of the binary
+	swapAddr string        // of its listener of static runs, see vanilla.SwapEnv
+	done     chan struct{} // closed once the binary exited
+	err      error         // of the exit, once done
+}
+
+// startApp runs the binary bin of the project at root with the PORT variable
+// set to a free port, and returns once it listens on it. Its output is
+// written to w.
+func startApp(bin, root string, w io.Writer) (*app, error) {
+	addrs, err := freeAddrs(2)
+	if err != nil {
+		return nil, err
+	}
+	addr, swapAddr := addrs[0], addrs[1]
+	_, port, _ := net.SplitHostPort(addr)
+
+	a := &app{bin: bin, cmd: exec.Command(bin), url: &url.URL{Scheme: "http", Host: addr}, swapAddr: swapAddr, done: make(chan struct{})}
+	a.cmd.Dir = root
+	a.cmd.Env = append(os.Environ(), "PORT="+port, vanilla.SwapEnv+"="+swapAddr)
+	a.cmd.Stdout, a.cmd.Stderr = w, w
+	if err := a.cmd.Start(); err != nil {
+		return nil, err
+	}
+	go func() {
+		a.err = a.cmd.Wait()
+		close(a.done)
+	}()
+
+	deadline := time.Now().Add(startTimeout)
+	for {
+		if c, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+			c.Close()
+			return a, nil
+		}
+		select {
+		case <-a.done:
+			return nil, fmt.Errorf("the project exited before listening on $PORT: %v", a.err)
+		case <-time.After(50 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			a.stop()
+			return nil, fmt.Errorf("the project did not listen on $PORT within %v", startTimeout)
+		}
+	}
+}
+
+// freeAddrs returns n distinct free local addresses.
+func freeAddrs(n int) ([]string, error) {
+	addrs := make([]string, n)
+	for i := range addrs {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		defer l.Close()
+		addrs[i] = l.Addr().String()
+	}
+	return addrs, nil
+}
+
+// stop stops the binary, interrupting it first so that it may shut down
+// gracefully, and waits for it to exit.
+func (a *app) stop() {
+	if runtime.GOOS == "windows" {
+		a.cmd.Process.Kill()
+	} else {
+		a.cmd.Process.Signal(os.Interrupt)
+	}
+	select {
+	case <-a.done:
+	case <-time.After(5 * time.Second):
+		a.cmd.Process.Kill()
+		<-a.done
+	}
+}
@@ -0,0 +1,81 @@
+// Client of the Vanilla development server, injected into the pages it
+// serves. It reloads the page or swaps its stylesheets when the project
+// changes, and shows the errors of the builds in an overlay.
+
+const events = new EventSource("/_vanilla/events");
+let connected = false;
+
+events.addEventListener("open", () => {
+    // the server restarted while the page was open
+    if (connected) {
+        location.reload();
+    }
+    connected = true;
+});
+
+events.addEventListener("reload", () => location.reload());
+
+events.addEventListener("css", (e) => {
+    const urls = JSON.parse(e.data);
+    for (const link of document.querySelectorAll('link[rel="stylesheet"]')) {
+        const url = new URL(link.href);
+        if (!urls.includes(url.pathname)) {
+            continue;
+        }
+        // the new stylesheet replaces the old one once loaded, without flash
+        const next = link.cloneNode();
+        next.href = url.pathname + "?t=" + Date.now();
+        next.addEventListener("load", () => link.remove());
+        link.after(next);
+    }
+});
+
+events.addEventListener("failure", (e) => showOverlay(JSON.parse(e.data)));
+
+const overlayId = "vanilla-overlay";
+
+function showOverlay(failure) {
+    document.getElementById(overlayId)?.remove();
+    const overlay = element("div", {
+        position: "fixed", inset: "0", zIndex: "2147483647", overflow: "auto",
+        padding: "2rem", background: "rgba(24, 24, 27, 0.95)", color: "#f4f4f5",
+        font: "14px/1.5 ui-monospace, SFMono-Regular, Menlo, Consolas, monospace",
+    });
+    overlay.id = overlayId;
+    overlay.append(element("h1", {font: "bold 18px sans-serif", margin: "0 0 1rem", color: "#f87171"}, "Build failed"));
+    for (const p of failure.problems) {
+        const section = element("section", {margin: "0 0 1.5rem"});
+        if (p.file) {
+            let pos = p.file;
+            if (p.line) {
+                pos += ":" + p.line + (p.column ? ":" + p.column : "");
+            }
+            section.append(element("div", {color: "#a1a1aa"}, pos));
+        }
+        section.append(element("div", {whiteSpace: "pre-wrap", fontWeight: "bold"}, p.message));
+        if (p.excerpt?.length) {
+            const width = String(p.excerpt[p.excerpt.length - 1].number).length;
+            const pre = element("pre", {margin: "0.5rem 0 0", padding: "0.5rem", background: "#27272a"});
+            for (const line of p.excerpt) {
+                const current = line.number === p.line;
+                const text = (current ? "> " : "  ") + String(line.number).padStart(width) + " | " + line.text;
+                pre.append(element("div", current ? {color: "#fca5a5"} : {}, text));
+                if (current && p.column) {
+                    pre.append(element("div", {color: "#f87171"}, " ".repeat(width + 4 + p.column) + "^"));
+                }
+            }
+            section.append(pre);
+        }
+        overlay.append(section);
+    }
+    (document.body ?? document.documentElement).append(overlay);
+}
+
+function element(tag, style, text) {
+    const e = document.createElement(tag);
+    Object.assign(e.style, style);
+    if (text !== undefined) {
+        e.textContent = text;
+    }
+    return e;
+}
@@ -0,0 +1,529 @@
+// Package dev implements the development server of Vanilla projects.
+//
+// The server compiles a project, builds its binary and runs it behind a
+// reverse proxy, which injects a client script into the pages it serves. It
+// then watches the pages and public directories of the project along with
+// its Go files, and on every change:
+//
+//   - compiles the project again, which with a build cache generates only
+//     the components which changed and their dependents, per the dependency
+//     graph of the linker;
+//   - serves the new bundles of the components from disk: a change of
+//     stylesheets only is pushed to the open pages, which swap them without
+//     reloading, and a change of scripts only reloads the pages;
+//   - builds the binary again if generated or Go code changed, starts it and
+//     swaps it for the running one once it listens, then reloads the pages.
+//     The go command compiles again only the packages which changed, usually
+//     the generated package alone.
+//
+// A change of the markup of a component only, which leaves its generated code
+// unchanged but for its static HTML, is swapped into the running binary
+// without building it again: the binary listens for the static HTML of its
+// components on the address of vanilla.SwapEnv. Other changes of templates
+// restart the binary as changes of Go code do, and the server and the
+// connections of the pages stay up meanwhile. Changes are watched with
+// inotify on Linux, and polled every 300ms on other systems.
+//
+// Errors of the build are shown in an overlay of the pages, with their
+// position and an excerpt of the source, until a build succeeds. The pages
+// receive the events of the server through server-sent events.
+package dev
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/supaleon/vanilla"
+	"github.com/supaleon/vanilla/internal/build"
+	"github.com/supaleon/vanilla/internal/build/cache"
+	"github.com/supaleon/vanilla/internal/codegen"
+	"github.com/supaleon/vanilla/internal/options"
+)
+
+// pollInterval is the interval at which the sources are polled for changes
+// where they cannot be watched.
+var pollInterval = 300 * time.Millisecond
+
+// settleDelay is the time waited after a change for the changes following
+// it, so that they are handled at once.
+var settleDelay = 50 * time.Millisecond
+
+// Server is a development server of a project.
+type Server struct {
+	conf   build.Config // with the development options, see setOptions
+	root   string       // absolute project root
+	log    io.Writer
+	tmp    string              // directory of the binaries
+	builds int                 // number of binaries built
+	files  map[string][32]byte // hashes of the generated files of the last build
+	codes  map[string][32]byte // hashes of their code, see codegen.SplitStatics
+	events hub
+	proxy  *httputil.ReverseProxy
+
+	mu       sync.Mutex
+	basePath string   // path of the base URL, aka "/docs/"
+	app      *app     // running binary; nil if none
+	failure  *failure // errors of the last build; nil if it succeeded
+}
+
+// Serve compiles the project of conf, runs it and serves it on l, until ctx
+// is done. Messages of the server and the output of the project are written
+// to w. Errors of the builds are reported to w and to the pages rather than
+// returned.
+//
+// The options of the project are adapted to development: bundles are not
+// named after their hash, and an absolute base URL is reduced to its path, so
+// that the pages are served by the server. Without a cache in conf, the
+// builds use one in a temporary directory. The output directory, the binary
+// directory and the directories starting with . or _ are not watched.
+func Serve(ctx context.Context, l net.Listener, conf *build.Config, w io.Writer) error {
+	root, err := filepath.Abs(conf.Root)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp("", "vanilla-dev-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	s := &Server{conf: *conf, root: root, log: w, tmp: tmp, files: make(map[string][32]byte), codes: make(map[string][32]byte)}
+	s.conf.Root, s.conf.Production = root, false
+	if s.conf.Cache == nil {
+		// so that a build generates only the components which changed and
+		// their dependents
+		if s.conf.Cache, err = cache.Open(filepath.Join(tmp, "cache")); err != nil {
+			return err
+		}
+	}
+	if s.conf.Out == "" {
+		s.conf.Out = "gen"
+	}
+	opts := conf.Options
+	if opts == nil {
+		opts = options.Default()
+	}
+	s.setOptions(opts)
+	s.proxy = &httputil.ReverseProxy{
+		Rewrite:        s.rewrite,
+		ModifyResponse: injectResponse,
+		ErrorHandler:   s.proxyError,
+	}
+	defer s.stopApp()
+
+	// requests wait for the first build
+	s.update(nil)
+	srv := &http.Server{Handler: s}
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(l) }()
+	defer srv.Close()
+	fmt.Fprintf(w, "serving on http://%s\n", displayAddr(l.Addr()))
+	n := newNotifier()
+	defer n.close()
+	last, err := s.snapshot(n.watch)
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-done:
+			return err
+		case <-n.events():
+		}
+		// an editor saving a file may write it several times
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(settleDelay):
+		}
+		select {
+		case <-n.events():
+		default:
+		}
+		cur, err := s.snapshot(n.watch)
+		if err != nil {
+			return err
+		}
+		if changed := last.diff(cur); len(changed) > 0 {
+			fmt.Fprintf(w, "changed: %s\n", strings.Join(changed, ", "))
+			s.update(changed)
+		}
+		last = cur
+	}
+}
+
+// setOptions sets the options of the builds from the options opts of the
+// project.
+func (s *Server) setOptions(opts *options.Options) {
+	o := *opts
+	o.Assets.Hash = false
+	if u, err := url.Parse(o.BaseURL); err == nil && u.Host != "" {
+		o.BaseURL = u.Path
+	}
+	s.conf.Options = &o
+	s.mu.Lock()
+	s.basePath = o.BaseURL
+	s.mu.Unlock()
+}
+
+// update builds the project once the files changed changed, or for the first
+// time if changed is nil, and notifies the pages.
+func (s *Server) update(changed []string) {
+	restart := changed == nil
+	opts := s.conf.Options
+	for _, name := range changed {
+		switch {
+		case name == options.File:
+			o, err := options.Load(s.root)
+			if err != nil {
+				s.fail(err)
+				return
+			}
+			s.setOptions(o)
+			restart = true
+		case path.Ext(name) == ".go", name == "go.mod", name == "go.sum":
+			restart = true
+		case within(name, opts.PublicDir):
+			restart = true // the files are embedded
+		}
+	}
+
+	res, err := build.Compile(&s.conf)
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	for _, e := range res.Warnings {
+		fmt.Fprintln(s.log, e)
+	}
+	if changed != nil && len(res.Compiled) > 0 {
+		fmt.Fprintf(s.log, "compiled: %s\n", strings.Join(res.Compiled, ", "))
+	}
+	goChanged, swaps, bundles := s.changes(res)
+	s.mu.Lock()
+	failed, running := s.failure != nil, s.app != nil
+	s.mu.Unlock()
+
+	if !restart && !goChanged && running && len(swaps) > 0 {
+		if err := s.swap(swaps); err != nil {
+			fmt.Fprintf(s.log, "restarting: %v\n", err)
+			goChanged = true
+		} else {
+			fmt.Fprintf(s.log, "swapped: %s\n", strings.Join(slices.Sorted(maps.Keys(swaps)), ", "))
+		}
+	}
+	switch {
+	case restart || goChanged || !running:
+		if !restart && running {
+			fmt.Fprintln(s.log, "restarting: the generated code changed")
+		}
+		if err := s.restart(); err != nil {
+			// so that the next build restarts
+			s.files, s.codes = make(map[string][32]byte), make(map[string][32]byte)
+			s.fail(err)
+			return
+		}
+		s.events.send("reload", nil)
+	case len(swaps) > 0:
+		s.events.send("reload", nil)
+	case failed:
+		s.events.send("reload", nil)
+	case len(bundles) == 0:
+	case !slices.ContainsFunc(bundles, func(name string) bool { return path.Ext(name) != ".css" }):
+		urls := make([]string, len(bundles))
+		for i, name := range bundles {
+			urls[i] = s.conf.Options.BaseURL + strings.TrimPrefix(name, s.conf.Out+"/")
+		}
+		s.events.send("css", urls)
+	default:
+		s.events.send("reload", nil)
+	}
+	s.mu.Lock()
+	s.failure = nil
+	s.mu.Unlock()
+}
+
+// changes records the hashes of the files generated by the build res, and
+// reports whether Go files changed since the previous build along with the
+// bundles which changed, other than source maps. A generated file whose code
+// is unchanged but for the static HTML of its component is not reported as
+// changed, its runs are returned in swaps by component name instead.
+func (s *Server) changes(res *build.Result) (goChanged bool, swaps map[string][]string, bundles []string) {
+	prev, prevCodes := s.files, s.codes
+	s.files, s.codes = make(map[string][32]byte), make(map[string][32]byte)
+	for _, name := range slices.Concat(res.Files, res.Bundles) {
+		data, err := os.ReadFile(filepath.Join(s.root, filepath.FromSlash(name)))
+		if err != nil {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		s.files[name] = sum
+		var (
+			code      []byte
+			component string
+			runs      []string
+		)
+		if path.Ext(name) == ".go" {
+			if code, component, runs, err = codegen.SplitStatics(data); err == nil {
+				s.codes[name] = sha256.Sum256(code)
+			}
+		}
+		if old, ok := prev[name]; ok && old == sum {
+			continue
+		}
+		switch path.Ext(name) {
+		case ".go":
+			if old, ok := prevCodes[name]; ok && component != "" && old == s.codes[name] {
+				if swaps == nil {
+					swaps = make(map[string][]string)
+				}
+				swaps[component] = runs
+				continue
+			}
+			goChanged = true
+		case ".map":
+		default:
+			bundles = append(bundles, name)
+		}
+	}
+	for name := range prev {
+		if _, ok := s.files[name]; !ok && path.Ext(name) == ".go" {
+			goChanged = true
+		}
+	}
+	slices.Sort(bundles)
+	return goChanged, swaps, bundles
+}
+
+// swap posts the static runs swaps by component name to the running binary,
+// see [vanilla.SwapEnv].
+func (s *Server) swap(swaps map[string][]string) error {
+	s.mu.Lock()
+	a := s.app
+	s.mu.Unlock()
+	body, err := json.Marshal(swaps)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post("http://"+a.swapAddr, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("swap: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// fail reports the errors err of a build.
+func (s *Server) fail(err error) {
+	f := newFailure(err, filepath.Join(s.root, filepath.FromSlash(path.Dir(s.conf.Options.PagesDir))), s.root)
+	for _, p := range f.Problems {
+		fmt.Fprintln(s.log, p)
+	}
+	s.mu.Lock()
+	s.failure = f
+	s.mu.Unlock()
+	s.events.send("failure", f)
+}
+
+// restart builds the binary of the project, runs it and swaps it for the
+// running one once it listens.
+func (s *Server) restart() error {
+	s.builds++
+	bin := filepath.Join(s.tmp, fmt.Sprintf("app%d", s.builds))
+	if runtime.GOOS == "windows" {
+		bin += ".exe"
+	}
+	if err := build.GoBuild(s.root, bin); err != nil {
+		return err
+	}
+	a, err := startApp(bin, s.root, s.log)
+	if err != nil {
+		os.Remove(bin)
+		return err
+	}
+	s.mu.Lock()
+	old := s.app
+	s.app = a
+	s.mu.Unlock()
+	if old != nil {
+		old.stop()
+		os.Remove(old.bin)
+	}
+	return nil
+}
+
+func (s *Server) stopApp() {
+	s.mu.Lock()
+	a := s.app
+	s.app = nil
+	s.mu.Unlock()
+	if a != nil {
+		a.stop()
+	}
+}
+
+// client is the script injected into the pages.
+//
+//go:embed client.js
+var client []byte
+
+// Paths of the endpoints of the server.
+const (
+	eventsPath = "/_vanilla/events"
+	clientPath = "/_vanilla/client.js"
+)
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case eventsPath:
+		s.events.serve(w, r, s.currentFailure)
+		return
+	case clientPath:
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(client)
+		return
+	}
+	s.mu.Lock()
+	base := s.basePath
+	s.mu.Unlock()
+	if base != "/" && strings.HasPrefix(r.URL.Path, base) {
+		r = r.Clone(r.Context())
+		r.URL.Path = "/" + strings.TrimPrefix(r.URL.Path, base)
+		r.URL.RawPath = ""
+	}
+	if strings.HasPrefix(r.URL.Path, vanilla.AssetsPath) {
+		name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+		file := filepath.Join(s.root, filepath.FromSlash(s.conf.Out), filepath.FromSlash(name))
+		if fi, err := os.Stat(file); err == nil && fi.Mode().IsRegular() {
+			w.Header().Set("Cache-Control", "no-cache")
+			http.ServeFile(w, r, file)
+			return
+		}
+	}
+	s.mu.Lock()
+	f, running := s.failure, s.app != nil
+	s.mu.Unlock()
+	if f != nil || !running {
+		serveFailurePage(w)
+		return
+	}
+	s.proxy.ServeHTTP(w, r)
+}
+
+func (s *Server) currentFailure() *failure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failure
+}
+
+// rewrite directs a request to the running binary. The response is requested
+// without content encoding, so that the client script can be injected.
+func (s *Server) rewrite(r *httputil.ProxyRequest) {
+	s.mu.Lock()
+	a := s.app
+	s.mu.Unlock()
+	if a != nil {
+		r.SetURL(a.url)
+	}
+	r.Out.Host = r.In.Host
+	r.Out.Header.Del("Accept-Encoding")
+}
+
+// proxyError answers a request which the running binary did not answer,
+// aka after it crashed.
+func (s *Server) proxyError(w http.ResponseWriter, r *http.Request, err error) {
+	fmt.Fprintf(s.log, "%s %s: %v\n", r.Method, r.URL.Path, err)
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+}
+
+// clientTag is the element loading the client script.
+var clientTag = []byte(`<script type="module" src="` + clientPath + `"></script>`)
+
+// injectResponse injects the client script into an HTML response.
+func injectResponse(resp *http.Response) error {
+	ctype := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(ctype, "text/html") || resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	body = inject(body)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", fmt.Sprint(len(body)))
+	return nil
+}
+
+// inject returns the HTML document page with the client script added at the
+// end of its head, or else of its body, or else of the document.
+func inject(page []byte) []byte {
+	lower := bytes.ToLower(page)
+	i := bytes.Index(lower, []byte("</head>"))
+	if i < 0 {
+		i = bytes.LastIndex(lower, []byte("</body>"))
+	}
+	if i < 0 {
+		i = len(page)
+	}
+	return slices.Concat(page[:i], clientTag, page[i:])
+}
+
+// serveFailurePage answers a request while the project cannot be served with
+// a page running the client script, which shows the errors of the build.
+func serveFailurePage(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>Build failed</title>%s</head><body></body></html>\n", clientTag)
+}
+
+// within reports whether the slash-separated path name is in the directory
+// dir.
+func within(name, dir string) bool {
+	return strings.HasPrefix(name, dir+"/")
+}
+
+// displayAddr returns the host and port of addr to show in a URL.
+func displayAddr(addr net.Addr) string {
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,332 @@
+package dev
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/supaleon/vanilla/internal/build"
+	"github.com/supaleon/vanilla/internal/checker"
+	"github.com/supaleon/vanilla/internal/options"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+func TestInject(t *testing.T) {
+	const tag = `<script type="module" src="/_vanilla/client.js"></script>`
+	tests := []struct {
+		page, want string
+	}{
+		{"<html><head><title>t</title></head><body></body></html>", "<html><head><title>t</title>" + tag + "</head><body></body></html>"},
+		{"<HTML><HEAD></HEAD></HTML>", "<HTML><HEAD>" + tag + "</HEAD></HTML>"},
+		{"<body><p>a</p></body>", "<body><p>a</p>" + tag + "</body>"},
+		{"<p>fragment</p>", "<p>fragment</p>" + tag},
+	}
+	for _, test := range tests {
+		if got := string(inject([]byte(test.page))); got != test.want {
+			t.Errorf("inject(%q):\ngot  %s\nwant %s", test.page, got, test.want)
+		}
+	}
+}
+
+func TestFailure(t *testing.T) {
+	dir := t.TempDir()
+	src := "<script>\n</script>\n\n<div>\n    {title}\n</div>\n"
+	if err := os.MkdirAll(filepath.Join(dir, "pages"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "pages", "Index.html"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {\n\tx\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		err  error
+		want []*problem
+	}{
+		{
+			checker.ErrorList{{Pos: token.Position{Filename: "pages/Index.html", Line: 5, Column: 6}, Code: checker.UndefinedName, Msg: "undefined: title"}},
+			[]*problem{{File: "pages/Index.html", Line: 5, Column: 6, Message: "undefined: title [" + string(checker.UndefinedName) + "]", Excerpt: []line{
+				{3, ""}, {4, "<div>"}, {5, "    {title}"}, {6, "</div>"},
+			}}},
+		},
+		{
+			&build.GoError{Args: []string{"build"}, Output: []byte("# example.com/app\n./main.go:4:2: x (variable of type int) is not used\n"), Err: errors.New("exit status 1")},
+			[]*problem{{File: "main.go", Line: 4, Column: 2, Message: "x (variable of type int) is not used", Excerpt: []line{
+				{2, ""}, {3, "func main() {"}, {4, "\tx"}, {5, "}"},
+			}}},
+		},
+		{
+			errors.New("disk full"),
+			[]*problem{{Message: "disk full"}},
+		},
+	}
+	for _, test := range tests {
+		if got := newFailure(test.err, filepath.Join(dir, "src"), dir).Problems; !reflect.DeepEqual(got, test.want) {
+			t.Errorf("newFailure(%v):\ngot  %+v\nwant %+v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	s := &Server{root: t.TempDir(), conf: build.Config{Out: "gen"}}
+	s.setOptions(options.Default())
+	write := func(name, content string) {
+		t.Helper()
+		file := filepath.Join(s.root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"go.mod", "main.go", "pages/Index.html", "pages/app.css", "public/robots.txt",
+		"gen/index.go", "bin/app", "node_modules/x/x.go", ".git/x.go", "README.md", "lib/lib.go", "lib/testdata/x.go"} {
+		write(name, "x")
+	}
+	var dirs []string
+	before, err := s.snapshot(func(dir string) error {
+		rel, err := filepath.Rel(s.root, dir)
+		dirs = append(dirs, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"go.mod", "lib/lib.go", "main.go", "pages/Index.html", "pages/app.css", "public/robots.txt"}
+	if got := snapshot(nil).diff(before); !reflect.DeepEqual(got, want) {
+		t.Errorf("got watched files %q, want %q", got, want)
+	}
+	if want := []string{".", "lib", "pages", "public"}; !reflect.DeepEqual(dirs, want) {
+		t.Errorf("got watched directories %q, want %q", dirs, want)
+	}
+
+	write("pages/app.css", "body {}")
+	write("gen/index.go", "package gen")
+	if err := os.Remove(filepath.Join(s.root, "lib", "lib.go")); err != nil {
+		t.Fatal(err)
+	}
+	after, err := s.snapshot(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := before.diff(after), []string{"lib/lib.go", "pages/app.css"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got changes %q, want %q", got, want)
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	dir := t.TempDir()
+	n := newNotifier()
+	defer n.close()
+	if err := n.watch(dir); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "Index.html", "<p>home</p>")
+	select {
+	case <-n.events():
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event after writing a file")
+	}
+	if err := n.watch(filepath.Join(dir, "missing")); !errors.Is(err, os.ErrNotExist) && err != nil {
+		t.Errorf("watch of a missing directory: got %v", err)
+	}
+}
+
+// writeProject writes the files of a project example.com/app using the
+// vanilla module of this tree into a temporary directory.
+func writeProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	module, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := os.ReadFile(filepath.Join(module, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	files["go.mod"] = "module example.com/app\n\ngo 1.24\n\nrequire github.com/supaleon/vanilla v0.0.0\n\nreplace github.com/supaleon/vanilla => " + module + "\n"
+	files["go.sum"] = string(sum)
+	for name, content := range files {
+		writeFile(t, root, name, content)
+	}
+	return root
+}
+
+func writeFile(t *testing.T, root, name, content string) {
+	t.Helper()
+	name = filepath.Join(root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServe(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping go build in short mode")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOPROXY", "off")
+	pollInterval, settleDelay = 20*time.Millisecond, 10*time.Millisecond
+
+	root := writeProject(t, map[string]string{
+		"pages/app.css": "p { color: red; }\n",
+		"pages/Layout.html": `<script>
+    import "./app.css"
+</script>
+
+<html><head></head><body><slot/></body></html>`,
+		"pages/Index.html": "<script>\n</script>\n\n<p>home</p>",
+		"main.go": `package main
+
+import (
+	"net/http"
+	"os"
+
+	_ "example.com/app/gen"
+	"github.com/supaleon/vanilla"
+)
+
+func main() {
+	http.ListenAndServe(":"+os.Getenv("PORT"), vanilla.NewRouter(vanilla.NewRenderer()))
+}
+`,
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := "http://" + l.Addr().String()
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	var log logBuffer
+	go func() { served <- Serve(ctx, l, &build.Config{Root: root}, &log) }()
+	defer func() {
+		cancel()
+		if err := <-served; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(base + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(body)
+	}
+	// the server listens once the first build is done
+	if code, body := get("/"); code != http.StatusOK || !strings.Contains(body, "<p>home</p>") || !strings.Contains(body, clientPath) {
+		t.Fatalf("GET /: got %d\n%s", code, body)
+	}
+	if code, body := get("/assets/Layout.css"); code != http.StatusOK || !strings.Contains(body, "color: red") {
+		t.Fatalf("GET /assets/Layout.css: got %d\n%s", code, body)
+	}
+
+	resp, err := http.Get(base + eventsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+	next := func(name string) string {
+		t.Helper()
+		timer := time.AfterFunc(time.Minute, func() { resp.Body.Close() })
+		defer timer.Stop()
+		for {
+			ev, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatalf("waiting for %s event: %v", name, err)
+			}
+			data, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatalf("waiting for %s event: %v", name, err)
+			}
+			events.ReadString('\n')
+			if strings.TrimSpace(ev) == "event: "+name {
+				return strings.TrimSpace(strings.TrimPrefix(data, "data: "))
+			}
+		}
+	}
+
+	writeFile(t, root, "pages/app.css", "p { color: blue; }\n")
+	if got, want := next("css"), `["/assets/Layout.css"]`; got != want {
+		t.Errorf("got css event %s, want %s", got, want)
+	}
+	if _, body := get("/assets/Layout.css"); !strings.Contains(body, "color: blue") {
+		t.Errorf("GET /assets/Layout.css after the change: got\n%s", body)
+	}
+
+	writeFile(t, root, "pages/Index.html", "<script>\n</script>\n\n<p>\n    {missing}\n</p>")
+	if got := next("failure"); !strings.Contains(got, `"file":"pages/Index.html","line":5,"column":6`) {
+		t.Errorf("got failure event %s", got)
+	}
+	if code, body := get("/"); code != http.StatusInternalServerError || !strings.Contains(body, clientPath) {
+		t.Errorf("GET / after an error: got %d\n%s", code, body)
+	}
+
+	writeFile(t, root, "pages/Index.html", "<script>\n</script>\n\n<p>changed</p>")
+	next("reload")
+	if code, body := get("/"); code != http.StatusOK || !strings.Contains(body, "<p>changed</p>") {
+		t.Errorf("GET / after the fix: got %d\n%s", code, body)
+	}
+	// the layout is reused from the cache
+	if got := log.String(); !strings.Contains(got, "compiled: pages/Index.html\n") {
+		t.Errorf("got log\n%s\nwant only pages/Index.html compiled again", got)
+	}
+
+	// markup only is swapped into the running binary
+	writeFile(t, root, "pages/Index.html", "<script>\n</script>\n\n\n<p>\n    swapped\n</p>")
+	next("reload")
+	if code, body := get("/"); code != http.StatusOK || !strings.Contains(body, "<p>\n    swapped\n</p>") {
+		t.Errorf("GET / after a change of markup: got %d\n%s", code, body)
+	}
+	if got := log.String(); !strings.Contains(got, "swapped: Index\n") || strings.Contains(got, "restarting") {
+		t.Errorf("got log\n%s\nwant Index swapped without restart", got)
+	}
+}
+
+// logBuffer is a buffer safe for concurrent writes.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
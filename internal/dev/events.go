@@ -0,0 +1,77 @@
+package dev
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// hub sends the events of the server to the connected pages.
+type hub struct {
+	mu      sync.Mutex
+	clients map[chan event]bool
+}
+
+// event is a server-sent event.
+type event struct {
+	name string
+	data []byte // JSON
+}
+
+// send sends the event name with the JSON encoding of data to the connected
+// pages. Pages which do not keep up miss it.
+func (h *hub) send(name string, data any) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		panic(err)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		select {
+		case c <- event{name, b}:
+		default:
+		}
+	}
+}
+
+// serve streams the events to the client of r, starting with the errors of
+// the last build if any, as returned by current.
+func (h *hub) serve(w http.ResponseWriter, r *http.Request, current func() *failure) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	c := make(chan event, 8)
+	h.mu.Lock()
+	if h.clients == nil {
+		h.clients = make(map[chan event]bool)
+	}
+	h.clients[c] = true
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.clients, c)
+		h.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if f := current(); f != nil {
+		b, _ := json.Marshal(f)
+		fmt.Fprintf(w, "event: failure\ndata: %s\n\n", b)
+	}
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-c:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.name, e.data)
+			flusher.Flush()
+		}
+	}
+}
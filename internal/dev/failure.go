@@ -0,0 +1,118 @@
+package dev
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/supaleon/vanilla/internal/build"
+	"github.com/supaleon/vanilla/internal/checker"
+	"github.com/supaleon/vanilla/internal/scanner"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+// failure holds the errors of a build, as shown by the overlay of the pages.
+type failure struct {
+	Problems []*problem `json:"problems"`
+}
+
+// problem is an error of a build.
+type problem struct {
+	File    string `json:"file,omitempty"` // slash-separated, relative to its directory
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+	Excerpt []line `json:"excerpt,omitempty"` // lines around Line
+}
+
+// line is a line of an excerpt.
+type line struct {
+	Number int    `json:"number"`
+	Text   string `json:"text"`
+}
+
+func (p *problem) String() string {
+	if p.File == "" {
+		return p.Message
+	}
+	pos := token.Position{Filename: p.File, Line: p.Line, Column: p.Column}
+	return pos.String() + ": " + p.Message
+}
+
+// excerptLines is the number of lines shown before and after the line of a
+// problem.
+const excerptLines = 2
+
+// goPosition matches the position of an error of the go command.
+var goPosition = regexp.MustCompile(`^(\S+\.go):(\d+)(?::(\d+))?: (.*)$`)
+
+// newFailure returns the failure of the error err of a build. The excerpts of
+// the problems are read from their file in the first of dirs holding it.
+func newFailure(err error, dirs ...string) *failure {
+	f := &failure{}
+	var (
+		parseErrs scanner.ErrorList
+		checkErrs checker.ErrorList
+		goErr     *build.GoError
+	)
+	switch {
+	case errors.As(err, &parseErrs):
+		for _, e := range parseErrs {
+			f.add(e.Pos, e.Msg)
+		}
+	case errors.As(err, &checkErrs):
+		for _, e := range checkErrs {
+			f.add(e.Pos, fmt.Sprintf("%s [%s]", e.Msg, e.Code))
+		}
+	case errors.As(err, &goErr):
+		for l := range strings.Lines(string(goErr.Output)) {
+			m := goPosition.FindStringSubmatch(strings.TrimSpace(l))
+			if m == nil {
+				continue
+			}
+			ln, _ := strconv.Atoi(m[2])
+			col, _ := strconv.Atoi(m[3])
+			f.add(token.Position{Filename: strings.TrimPrefix(m[1], "./"), Line: ln, Column: col}, m[4])
+		}
+	}
+	if len(f.Problems) == 0 {
+		f.Problems = append(f.Problems, &problem{Message: err.Error()})
+	}
+	for _, p := range f.Problems {
+		p.Excerpt = excerpt(p.File, p.Line, dirs)
+	}
+	return f
+}
+
+func (f *failure) add(pos token.Position, msg string) {
+	f.Problems = append(f.Problems, &problem{File: pos.Filename, Line: pos.Line, Column: pos.Column, Message: msg})
+}
+
+// excerpt returns the lines around the line n of the file name in the first
+// of dirs holding it, or nil.
+func excerpt(name string, n int, dirs []string) []line {
+	if name == "" || n <= 0 {
+		return nil
+	}
+	for _, dir := range dirs {
+		file, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			continue
+		}
+		defer file.Close()
+		var lines []line
+		sc := bufio.NewScanner(file)
+		for i := 1; sc.Scan() && i <= n+excerptLines; i++ {
+			if i >= n-excerptLines {
+				lines = append(lines, line{i, strings.TrimRight(sc.Text(), "\r")})
+			}
+		}
+		return lines
+	}
+	return nil
+}
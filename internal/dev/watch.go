@@ -0,0 +1,150 @@
+package dev
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/supaleon/vanilla/internal/options"
+)
+
+// snapshot holds the state of the watched files of a project by
+// slash-separated path relative to its root.
+type snapshot map[string]fileState
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshot returns the state of the watched files of the project: the files
+// of its pages and public directories, its Go files, go.mod, go.sum, the
+// options file and the Tailwind configuration file if any. watch, if not nil,
+// is called with every directory walked, so that the changes of their files
+// are notified.
+func (s *Server) snapshot(watch func(dir string) error) (snapshot, error) {
+	opts := s.conf.Options
+	skip := []string{s.conf.Out, opts.BinDir, "node_modules"}
+	roots := map[string]bool{"go.mod": true, "go.sum": true, options.File: true}
+	if opts.Tailwind.Config != "" {
+		roots[opts.Tailwind.Config] = true
+	}
+	snap := make(snapshot)
+	err := filepath.WalkDir(s.root, func(name string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // removed meanwhile
+		}
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.root, name)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			base := d.Name()
+			if rel != "." && (slices.Contains(skip, rel) || base == "testdata" || strings.HasPrefix(base, ".") || strings.HasPrefix(base, "_")) {
+				return filepath.SkipDir
+			}
+			if watch != nil {
+				if err := watch(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					return err
+				}
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if !roots[rel] && path.Ext(rel) != ".go" && !within(rel, opts.PagesDir) && !within(rel, opts.PublicDir) {
+			return nil
+		}
+		fi, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		snap[rel] = fileState{fi.ModTime(), fi.Size()}
+		return nil
+	})
+	return snap, err
+}
+
+// diff returns the sorted paths of the files added, removed or modified in
+// cur since s.
+func (s snapshot) diff(cur snapshot) []string {
+	var changed []string
+	for name, st := range cur {
+		if old, ok := s[name]; !ok || old != st {
+			changed = append(changed, name)
+		}
+	}
+	for name := range s {
+		if _, ok := cur[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// notifier notifies the changes of the files of the watched directories.
+type notifier interface {
+	// events returns the channel receiving a value after changes; the changes
+	// until the value is received are coalesced.
+	events() <-chan struct{}
+	// watch watches the files of the directory dir, not recursively.
+	watch(dir string) error
+	close() error
+}
+
+// poller is a notifier for the systems without notifications, which signals
+// every pollInterval that the files may have changed.
+type poller struct {
+	c    chan struct{}
+	done chan struct{}
+}
+
+func newPoller() *poller {
+	p := &poller{c: make(chan struct{}, 1), done: make(chan struct{})}
+	go tick(p.c, p.done)
+	return p
+}
+
+// tick signals c every pollInterval until done is closed.
+func tick(c chan struct{}, done <-chan struct{}) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			signal(c)
+		}
+	}
+}
+
+func (p *poller) events() <-chan struct{} { return p.c }
+
+func (p *poller) watch(dir string) error { return nil }
+
+func (p *poller) close() error {
+	close(p.done)
+	return nil
+}
+
+// signal sends a value to c, a channel of capacity 1, unless one is pending.
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
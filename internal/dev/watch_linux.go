@@ -0,0 +1,67 @@
+package dev
+
+import (
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// inotifyMask selects the events of the watched directories.
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_ATTRIB |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE_SELF | unix.IN_ONLYDIR
+
+// inotify is a notifier using the inotify API of Linux.
+type inotify struct {
+	fd   int
+	f    *os.File // of fd, so that reads wait in the poller of the runtime
+	c    chan struct{}
+	poll sync.Once // falls back to polling, see watch
+	done chan struct{}
+}
+
+// newNotifier returns an inotify notifier, or a poller if inotify is not
+// available.
+func newNotifier() notifier {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return newPoller()
+	}
+	n := &inotify{fd: fd, f: os.NewFile(uintptr(fd), "inotify"), c: make(chan struct{}, 1), done: make(chan struct{})}
+	go n.read()
+	return n
+}
+
+// read signals the events read until n is closed. Their contents do not
+// matter, since the changes are found by comparing snapshots.
+func (n *inotify) read() {
+	buf := make([]byte, 64<<10)
+	for {
+		if _, err := n.f.Read(buf); err != nil {
+			return
+		}
+		signal(n.c)
+	}
+}
+
+func (n *inotify) events() <-chan struct{} { return n.c }
+
+// watch watches dir, again if it is already. When dir cannot be watched for
+// another reason than its removal, aka past the limit of watches of the user,
+// n also signals every pollInterval from then on.
+func (n *inotify) watch(dir string) error {
+	_, err := unix.InotifyAddWatch(n.fd, dir, inotifyMask)
+	switch err {
+	case nil:
+		return nil
+	case unix.ENOENT, unix.ENOTDIR:
+		return os.ErrNotExist
+	}
+	n.poll.Do(func() { go tick(n.c, n.done) })
+	return nil
+}
+
+func (n *inotify) close() error {
+	close(n.done)
+	return n.f.Close()
+}
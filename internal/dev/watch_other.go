@@ -0,0 +1,9 @@
+//go:build !linux
+
+package dev
+
+// newNotifier returns a poller, notifications being implemented on Linux
+// only.
+func newNotifier() notifier {
+	return newPoller()
+}
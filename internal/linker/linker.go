@@ -1 +1,288 @@
+// Package linker resolves the component imports of a set of parsed components
+// into a dependency graph, and reports unresolved imports, unknown component
+// tags, unused imports, name collisions and import cycles.
 package linker
+
+import (
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/supaleon/vanilla/internal/ast"
+	"github.com/supaleon/vanilla/internal/checker"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+// File is a parsed component file.
+type File struct {
+	Path      string // slash-separated path relative to the project root, aka `pages/Home.html`
+	Component *ast.Component
+}
+
+// Import is a resolved component import of a file.
+type Import struct {
+	Spec   *ast.ImportSpec
+	Name   string // name bound by the import, aka `Card`
+	Target *File  // imported file; nil if the import could not be resolved
+	used   bool
+}
+
+// Graph is the dependency graph of the linked components.
+type Graph struct {
+	files      map[string]*File
+	imports    map[string][]*Import // by importing file path
+	dependents map[string][]string  // by imported file path; sorted
+}
+
+// Link resolves the component imports of files into a [Graph]. The returned
+// error list is sorted by position; the graph is complete even if there are
+// errors, with unresolved imports left out of it.
+func Link(fset *token.FileSet, files []*File) (*Graph, checker.ErrorList) {
+	l := &linker{fset: fset}
+	g := &Graph{
+		files:      make(map[string]*File, len(files)),
+		imports:    make(map[string][]*Import, len(files)),
+		dependents: make(map[string][]string),
+	}
+	l.g = g
+	for _, f := range files {
+		g.files[f.Path] = f
+	}
+	for _, f := range files {
+		l.resolveImports(f)
+	}
+	for _, f := range files {
+		l.resolveTags(f)
+	}
+	for _, deps := range g.dependents {
+		sort.Strings(deps)
+	}
+	l.findCycles(files)
+	l.errors.Sort()
+	return g, l.errors
+}
+
+type linker struct {
+	fset   *token.FileSet
+	g      *Graph
+	errors checker.ErrorList
+}
+
+func (l *linker) error(loc token.Loc, code checker.Code, msg string) {
+	l.errors.Add(l.fset.Position(loc), code, msg)
+}
+
+// resolveImports resolves the component imports of f.
+func (l *linker) resolveImports(f *File) {
+	m := f.Component.ESModule
+	if m == nil {
+		return
+	}
+	names := make(map[string]*Import)
+	for _, spec := range m.Imports {
+		if spec.Kind != ast.ImportSTMT || spec.File != ast.FileComponent {
+			continue
+		}
+		imp := &Import{Spec: spec, Name: ComponentName(spec.Path)}
+		if spec.Name != nil {
+			imp.Name = spec.Name.Name
+		}
+		if target, ok := l.g.files[path.Join(path.Dir(f.Path), spec.Path)]; ok {
+			imp.Target = target
+		} else {
+			l.error(spec.PathLoc, checker.UnresolvedImport, "cannot find component "+spec.Path)
+		}
+		if prev, ok := names[imp.Name]; ok {
+			l.error(spec.PathLoc, checker.NameCollision, "component "+imp.Name+" is already imported from "+
+				prev.Spec.Path+", use a default import to rename it")
+			continue
+		}
+		names[imp.Name] = imp
+		l.g.imports[f.Path] = append(l.g.imports[f.Path], imp)
+		if imp.Target != nil && !slices.Contains(l.g.dependents[imp.Target.Path], f.Path) {
+			l.g.dependents[imp.Target.Path] = append(l.g.dependents[imp.Target.Path], f.Path)
+		}
+	}
+}
+
+// resolveTags matches the component tags in the template of f with its imports.
+func (l *linker) resolveTags(f *File) {
+	imports := l.g.imports[f.Path]
+	ast.Inspect(f.Component.Template, func(n ast.Node) bool {
+		e, ok := n.(*ast.Element)
+		if !ok || !IsComponentTag(e.Name) {
+			return true
+		}
+		i := slices.IndexFunc(imports, func(imp *Import) bool { return imp.Name == e.Name })
+		if i < 0 {
+			l.error(e.Start, checker.UnknownComponent, "unknown component <"+e.Name+">, missing import of ./"+e.Name+".html")
+			return true
+		}
+		imports[i].used = true
+		if target := imports[i].Target; target != nil {
+			l.checkContent(e, target)
+		}
+		return true
+	})
+	for _, imp := range imports {
+		if !imp.used {
+			l.error(imp.Spec.PathLoc, checker.UnusedImport, "component "+imp.Name+" is imported and not used")
+		}
+	}
+}
+
+// checkContent checks the content passed to the component element e against
+// the slots of its target: elements with a slot attribute must target a named
+// slot of the component, the rest goes to its default slot.
+func (l *linker) checkContent(e *ast.Element, target *File) {
+	slots := target.Component.Slots()
+	unused := token.NoLoc
+	for _, n := range e.Children {
+		switch n := n.(type) {
+		case *ast.Comment:
+			continue
+		case *ast.Text:
+			i := strings.IndexFunc(n.Value, func(r rune) bool { return !unicode.IsSpace(r) })
+			if i < 0 {
+				continue
+			}
+			if !unused.IsValid() && !slices.Contains(slots, "") {
+				unused = n.Start + token.Loc(i)
+			}
+			continue
+		case *ast.Element:
+			if name := n.SlotName(); name != "" {
+				if !slices.Contains(slots, name) {
+					l.error(n.Start, checker.UnknownSlot, "<"+e.Name+"> has no slot "+name+", aka <slot name=\""+name+"\">")
+				}
+				continue
+			}
+		}
+		if !unused.IsValid() && !slices.Contains(slots, "") {
+			unused = n.Range().Start
+		}
+	}
+	if unused.IsValid() {
+		l.error(unused, checker.UnusedContent, "content of <"+e.Name+"> is not rendered, "+target.Path+" has no default <slot>")
+	}
+}
+
+// findCycles reports every import cycle once, at the import which closes it.
+func (l *linker) findCycles(files []*File) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(files))
+	var stack []string
+	var visit func(p string)
+	visit = func(p string) {
+		state[p] = visiting
+		stack = append(stack, p)
+		for _, imp := range l.g.imports[p] {
+			if imp.Target == nil {
+				continue
+			}
+			switch state[imp.Target.Path] {
+			case unvisited:
+				visit(imp.Target.Path)
+			case visiting:
+				i := slices.Index(stack, imp.Target.Path)
+				cycle := append(slices.Clone(stack[i:]), imp.Target.Path)
+				l.error(imp.Spec.PathLoc, checker.ImportCycle, "import cycle not allowed: "+strings.Join(cycle, " -> "))
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[p] = done
+	}
+	for _, f := range files {
+		if state[f.Path] == unvisited {
+			visit(f.Path)
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Queries
+
+// File returns the file of the given path, or nil.
+func (g *Graph) File(path string) *File {
+	return g.files[path]
+}
+
+// Imports returns the component imports of the file of the given path.
+func (g *Graph) Imports(path string) []*Import {
+	return g.imports[path]
+}
+
+// Resolve returns the file of the component referenced by the tag name in the
+// file of the given path, or nil.
+func (g *Graph) Resolve(path, tag string) *File {
+	for _, imp := range g.imports[path] {
+		if imp.Name == tag {
+			return imp.Target
+		}
+	}
+	return nil
+}
+
+// Dependencies returns the sorted paths of the files directly imported by the
+// file of the given path.
+func (g *Graph) Dependencies(path string) []string {
+	var deps []string
+	for _, imp := range g.imports[path] {
+		if imp.Target != nil && !slices.Contains(deps, imp.Target.Path) {
+			deps = append(deps, imp.Target.Path)
+		}
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+// Dependents returns the sorted paths of the files directly importing the file
+// of the given path.
+func (g *Graph) Dependents(path string) []string {
+	return slices.Clone(g.dependents[path])
+}
+
+// Affected returns the sorted paths of the files directly or indirectly
+// importing the file of the given path, that is the files to rebuild once it
+// changes. The file itself is not included.
+func (g *Graph) Affected(path string) []string {
+	seen := map[string]bool{path: true}
+	var affected []string
+	queue := []string{path}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, d := range g.dependents[p] {
+			if !seen[d] {
+				seen[d] = true
+				affected = append(affected, d)
+				queue = append(queue, d)
+			}
+		}
+	}
+	sort.Strings(affected)
+	return affected
+}
+
+// ----------------------------------------------------------------------------
+// Names
+
+// ComponentName returns the name bound by a bare import of the component at
+// importPath, aka `Card` for `./Card.html`.
+func ComponentName(importPath string) string {
+	return strings.TrimSuffix(path.Base(importPath), path.Ext(importPath))
+}
+
+// IsComponentTag reports whether the tag name refers to a component, that is
+// whether it is UpperCamelCase, aka `<Card>`.
+func IsComponentTag(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r)
+}
@@ -0,0 +1,127 @@
+package linker
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/supaleon/vanilla/internal/parser"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+func link(t *testing.T, srcs map[string]string) (*Graph, []string) {
+	t.Helper()
+	fset := token.NewFileSet()
+	var files []*File
+	for _, p := range slices.Sorted(maps.Keys(srcs)) {
+		c, err := parser.ParseFile(fset, p, []byte(srcs[p]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, &File{Path: p, Component: c})
+	}
+	g, list := Link(fset, files)
+	var errs []string
+	for _, e := range list {
+		errs = append(errs, e.Error())
+	}
+	return g, errs
+}
+
+func TestGraph(t *testing.T) {
+	g, errs := link(t, map[string]string{
+		"pages/Home.html": `<script>
+    import "./Layout.html"
+    import Card from "./widgets/Card.html"
+</script>
+<Layout><Card></Card></Layout>`,
+		"pages/Layout.html": `<script>
+    import "./widgets/Card.html"
+</script>
+<div><Card/><slot/></div>`,
+		"pages/widgets/Card.html": "<script>\n</script>\n<div></div>",
+	})
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"Dependencies(Home)", g.Dependencies("pages/Home.html"), []string{"pages/Layout.html", "pages/widgets/Card.html"}},
+		{"Dependencies(Card)", g.Dependencies("pages/widgets/Card.html"), nil},
+		{"Dependents(Card)", g.Dependents("pages/widgets/Card.html"), []string{"pages/Home.html", "pages/Layout.html"}},
+		{"Dependents(Layout)", g.Dependents("pages/Layout.html"), []string{"pages/Home.html"}},
+		{"Affected(Card)", g.Affected("pages/widgets/Card.html"), []string{"pages/Home.html", "pages/Layout.html"}},
+	}
+	for _, test := range tests {
+		if !slices.Equal(test.got, test.want) {
+			t.Errorf("%s: got %q; want %q", test.name, test.got, test.want)
+		}
+	}
+	if f := g.Resolve("pages/Home.html", "Card"); f == nil || f.Path != "pages/widgets/Card.html" {
+		t.Errorf("Resolve(Home, Card): got %v; want pages/widgets/Card.html", f)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		srcs map[string]string
+		errs []string
+	}{
+		{map[string]string{
+			"pages/A.html": "<script>\n    import \"./B.html\"\n</script>\n<div><B/></div>",
+		}, []string{
+			"pages/A.html:2:12: cannot find component ./B.html [VK001]",
+		}},
+		{map[string]string{
+			"pages/A.html": "<script>\n</script>\n<div>\n  <Card/>\n</div>",
+		}, []string{
+			"pages/A.html:4:3: unknown component <Card>, missing import of ./Card.html [VK002]",
+		}},
+		{map[string]string{
+			"pages/A.html": "<script>\n    import \"./B.html\"\n</script>\n<div></div>",
+			"pages/B.html": "<script>\n</script>\n<div></div>",
+		}, []string{
+			"pages/A.html:2:12: component B is imported and not used [VK003]",
+		}},
+		{map[string]string{
+			"pages/A.html":   "<script>\n    import \"./B.html\"\n    import \"./x/B.html\"\n</script>\n<div><B/></div>",
+			"pages/B.html":   "<script>\n</script>\n<div></div>",
+			"pages/x/B.html": "<script>\n</script>\n<div></div>",
+		}, []string{
+			"pages/A.html:3:12: component B is already imported from ./B.html, use a default import to rename it [VK004]",
+		}},
+		{map[string]string{
+			"pages/A.html": "<script>\n    import \"./B.html\"\n</script>\n<div><B/></div>",
+			"pages/B.html": "<script>\n    import \"./C.html\"\n</script>\n<div><C/></div>",
+			"pages/C.html": "<script>\n    import \"./A.html\"\n</script>\n<div><A/></div>",
+		}, []string{
+			"pages/C.html:2:12: import cycle not allowed: pages/A.html -> pages/B.html -> pages/C.html -> pages/A.html [VK005]",
+		}},
+		{map[string]string{
+			"pages/A.html": "<script>\n    import \"./A.html\"\n</script>\n<div><A/></div>",
+		}, []string{
+			"pages/A.html:2:12: import cycle not allowed: pages/A.html -> pages/A.html [VK005]",
+		}},
+		{map[string]string{
+			"pages/A.html": "<script>\n    import \"./B.html\"\n</script>\n<div><B>\n  <!--x-->\n  <p slot=\"footer\">f</p>\n</B></div>",
+			"pages/B.html": "<script>\n</script>\n<div><slot/><slot name=\"footer\">none</slot></div>",
+		}, nil},
+		{map[string]string{
+			"pages/A.html": "<script>\n    import \"./B.html\"\n</script>\n<div><B>\n  hello <b>x</b>\n  <p slot=\"footer\">f</p>\n</B></div>",
+			"pages/B.html": "<script>\n</script>\n<div></div>",
+		}, []string{
+			"pages/A.html:5:3: warning: content of <B> is not rendered, pages/B.html has no default <slot> [VK010]",
+			"pages/A.html:6:3: <B> has no slot footer, aka <slot name=\"footer\"> [VK011]",
+		}},
+	}
+	for _, test := range tests {
+		_, errs := link(t, test.srcs)
+		if strings.Join(errs, "\n") != strings.Join(test.errs, "\n") {
+			t.Errorf("got  %q\nwant %q", errs, test.errs)
+		}
+	}
+}
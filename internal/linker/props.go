@@ -0,0 +1,321 @@
+package linker
+
+import (
+	"go/types"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/supaleon/vanilla/internal/ast"
+	"github.com/supaleon/vanilla/internal/checker"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+// CheckProps checks the prop assignments of the component elements of the
+// linked files, aka `<Children tags={user.tags}>`, and of the <context>
+// elements, aka `<context theme={theme}>`, against the prop declarations of
+// the components. infos holds the result of [checker.Check] by file path.
+//
+// Props of Go types are required: each must be assigned by an attribute or
+// provided by a <context> enclosing every use of the component. Props of JS
+// literal types default to their literal.
+//
+// The returned list is sorted by position.
+func (g *Graph) CheckProps(fset *token.FileSet, infos map[string]*checker.Info) checker.ErrorList {
+	pc := &propChecker{
+		fset:      fset,
+		g:         g,
+		infos:     infos,
+		provided:  make(map[string]map[string]bool),
+		computing: make(map[string]bool),
+	}
+	for _, p := range slices.Sorted(maps.Keys(g.files)) {
+		f := g.files[p]
+		pc.checkNodes(f, f.Component.Template.Nodes, nil)
+	}
+	pc.errors.Sort()
+	return pc.errors
+}
+
+type propChecker struct {
+	fset   *token.FileSet
+	g      *Graph
+	infos  map[string]*checker.Info
+	errors checker.ErrorList
+
+	provided  map[string]map[string]bool // context names provided to every use of a file
+	computing map[string]bool
+}
+
+// contextValue is a value of a <context> element.
+type contextValue struct {
+	attr *ast.Attribute
+	typ  types.Type // nil if unknown
+}
+
+// contextFrame holds the values of a <context> element.
+type contextFrame struct {
+	outer  *contextFrame
+	values map[string]*contextValue
+}
+
+func (c *contextFrame) lookup(name string) *contextValue {
+	for ; c != nil; c = c.outer {
+		if v, ok := c.values[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func (pc *propChecker) errorf(loc token.Loc, code checker.Code, msg string) {
+	pc.errors.Add(pc.fset.Position(loc), code, msg)
+}
+
+func (pc *propChecker) checkNodes(f *File, nodes []ast.Node, ctx *contextFrame) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *ast.Element:
+			switch {
+			case n.Name == "context":
+				ctx := pc.checkContext(f, n, ctx)
+				pc.checkNodes(f, n.Children, ctx)
+			case IsComponentTag(n.Name):
+				pc.checkElement(f, n, ctx)
+				pc.checkNodes(f, n.Children, ctx)
+			default:
+				pc.checkNodes(f, n.Children, ctx)
+			}
+		case *ast.IfBlock:
+			pc.checkNodes(f, n.Then, ctx)
+			pc.checkNodes(f, n.Else, ctx)
+		case *ast.ForBlock:
+			pc.checkNodes(f, n.Body, ctx)
+		}
+	}
+}
+
+// checkElement checks the attributes of the component element e in f.
+func (pc *propChecker) checkElement(f *File, e *ast.Element, ctx *contextFrame) {
+	target := pc.g.Resolve(f.Path, e.Name)
+	if target == nil {
+		return // reported by Link
+	}
+	props := pc.propTypes(target)
+	assigned := make(map[string]bool)
+	for _, a := range e.Attrs {
+		if a.Name == "slot" {
+			continue // targets a slot of the enclosing component element
+		}
+		assigned[a.Name] = true
+		d := target.module().Prop(a.Name)
+		if d == nil {
+			pc.errorf(a.NameLoc, checker.UnknownAttribute, "unknown attribute "+a.Name+", <"+e.Name+"> has no prop "+a.Name)
+			continue
+		}
+		if v := ctx.lookup(a.Name); v != nil {
+			pos := pc.fset.Position(v.attr.NameLoc)
+			pc.errorf(a.NameLoc, checker.ContextShadowing, "attribute "+a.Name+" of <"+e.Name+"> shadows the value of the enclosing <context> at "+
+				pos.String())
+		}
+		vt, pt := attrType(pc.infos[f.Path], a), props[a.Name]
+		if a.Kind == ast.AttrStatic && pt != nil && !checker.AssignableTo(vt, pt) {
+			// `size=2` or `open="false"`
+			vt = literalType(a)
+		}
+		if vt != nil && pt != nil && !checker.AssignableTo(vt, pt) {
+			pc.errorf(a.NameLoc, checker.PropMismatch, "cannot use "+attrString(a)+" ("+typeString(vt)+") as prop "+a.Name+
+				" of <"+e.Name+"> ("+typeString(pt)+")")
+		}
+	}
+	provided := pc.providedTo(f.Path)
+	for _, d := range target.module().Props {
+		name := d.Name.Name
+		if d.Kind != ast.PropGoType || assigned[name] || ctx.lookup(name) != nil || provided[name] {
+			continue
+		}
+		pc.errorf(e.Start, checker.MissingProp, "missing prop "+name+" of <"+e.Name+">")
+	}
+}
+
+// checkContext checks the values of the <context> element e in f against the
+// props of the same name of the components used within e, directly or not,
+// and returns the frame of e.
+func (pc *propChecker) checkContext(f *File, e *ast.Element, outer *contextFrame) *contextFrame {
+	ctx := &contextFrame{outer: outer, values: make(map[string]*contextValue)}
+	for _, a := range e.Attrs {
+		ctx.values[a.Name] = &contextValue{attr: a, typ: attrType(pc.infos[f.Path], a)}
+	}
+
+	// components used within e, and their dependencies
+	seen := make(map[string]bool)
+	var queue []*File
+	ast.Inspect(&ast.Template{Nodes: e.Children}, func(n ast.Node) bool {
+		if c, ok := n.(*ast.Element); ok && IsComponentTag(c.Name) {
+			if t := pc.g.Resolve(f.Path, c.Name); t != nil && !seen[t.Path] {
+				seen[t.Path] = true
+				queue = append(queue, t)
+			}
+		}
+		return true
+	})
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+		props := pc.propTypes(t)
+		for _, a := range e.Attrs {
+			v, pt := ctx.values[a.Name], props[a.Name]
+			if v.typ != nil && pt != nil && !checker.AssignableTo(v.typ, pt) {
+				pc.errorf(a.NameLoc, checker.PropMismatch, "cannot use "+attrString(a)+" ("+typeString(v.typ)+") as prop "+a.Name+
+					" of "+t.Path+" ("+typeString(pt)+") in <context>")
+			}
+		}
+		for _, dep := range pc.g.Dependencies(t.Path) {
+			if !seen[dep] {
+				seen[dep] = true
+				queue = append(queue, pc.g.files[dep])
+			}
+		}
+	}
+	return ctx
+}
+
+// providedTo returns the names of the <context> values enclosing every use of
+// the file of the given path, including the ones provided to its users.
+func (pc *propChecker) providedTo(path string) map[string]bool {
+	if p, ok := pc.provided[path]; ok {
+		return p
+	}
+	if pc.computing[path] {
+		return nil // import cycle, reported by Link
+	}
+	pc.computing[path] = true
+	defer delete(pc.computing, path)
+
+	var provided map[string]bool
+	first := true
+	for _, d := range pc.g.dependents[path] {
+		user := pc.g.files[d]
+		outer := pc.providedTo(d)
+		var visit func(nodes []ast.Node, names map[string]bool)
+		visit = func(nodes []ast.Node, names map[string]bool) {
+			for _, n := range nodes {
+				switch n := n.(type) {
+				case *ast.Element:
+					if n.Name == "context" {
+						names = maps.Clone(names)
+						for _, a := range n.Attrs {
+							names[a.Name] = true
+						}
+					} else if IsComponentTag(n.Name) {
+						if t := pc.g.Resolve(d, n.Name); t != nil && t.Path == path {
+							// the names provided at this use
+							site := maps.Clone(names)
+							maps.Copy(site, outer)
+							if first {
+								provided, first = site, false
+							} else {
+								maps.DeleteFunc(provided, func(name string, _ bool) bool { return !site[name] })
+							}
+						}
+					}
+					visit(n.Children, names)
+				case *ast.IfBlock:
+					visit(n.Then, names)
+					visit(n.Else, names)
+				case *ast.ForBlock:
+					visit(n.Body, names)
+				}
+			}
+		}
+		visit(user.Component.Template.Nodes, map[string]bool{})
+	}
+	pc.provided[path] = provided
+	return provided
+}
+
+// propTypes returns the prop types of the file f.
+func (pc *propChecker) propTypes(f *File) map[string]types.Type {
+	if info := pc.infos[f.Path]; info != nil {
+		return info.Props
+	}
+	return nil
+}
+
+// module returns the ES module of f, or an empty one.
+func (f *File) module() *ast.ESModule {
+	if f.Component.ESModule != nil {
+		return f.Component.ESModule
+	}
+	return &ast.ESModule{}
+}
+
+// attrType returns the type of the value of the attribute a, or nil if unknown.
+func attrType(info *checker.Info, a *ast.Attribute) types.Type {
+	switch a.Kind {
+	case ast.AttrStatic:
+		if a.Value == nil {
+			// boolean attribute, aka `<Card disabled>`
+			return types.Typ[types.UntypedBool]
+		}
+		return types.Typ[types.UntypedString]
+	case ast.AttrExpr, ast.AttrInterp:
+		if len(a.Value) == 1 {
+			// `tags={user.tags}` or `tags="{user.tags}"`
+			if x, ok := a.Value[0].(*ast.Interpolation); ok {
+				if info == nil {
+					return nil
+				}
+				return info.TypeOf(x.X)
+			}
+		}
+	}
+	return types.Typ[types.String]
+}
+
+// literalType returns the type of the value of the static attribute a read as
+// a literal, aka untyped int for `size=2`.
+func literalType(a *ast.Attribute) types.Type {
+	text := attrString(a)
+	text = strings.Trim(text, `"`)
+	switch {
+	case text == "true" || text == "false":
+		return types.Typ[types.UntypedBool]
+	case isInt(text):
+		return types.Typ[types.UntypedInt]
+	case isFloat(text):
+		return types.Typ[types.UntypedFloat]
+	}
+	return types.Typ[types.UntypedString]
+}
+
+func isInt(s string) bool {
+	_, err := strconv.ParseInt(s, 0, 64)
+	return err == nil
+}
+
+func isFloat(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// attrString returns the source of the value of the attribute a.
+func attrString(a *ast.Attribute) string {
+	if len(a.Value) == 1 {
+		switch v := a.Value[0].(type) {
+		case *ast.Interpolation:
+			return ast.ExprString(v.X)
+		case *ast.Text:
+			return `"` + v.Value + `"`
+		}
+	}
+	if a.Value == nil {
+		return "true"
+	}
+	return a.Name
+}
+
+func typeString(t types.Type) string {
+	return types.TypeString(t, (*types.Package).Name)
+}
@@ -0,0 +1,133 @@
+package linker
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/supaleon/vanilla/internal/checker"
+	"github.com/supaleon/vanilla/internal/parser"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+const children = `<script>
+    import {Tags} from "./user.go"
+    let tags = prop(Tags())
+    let theme = prop("dark")
+    let size = prop(1)
+</script>
+<div></div>`
+
+func checkProps(t *testing.T, loader *checker.GoLoader, srcs map[string]string) []string {
+	t.Helper()
+	fset := token.NewFileSet()
+	var files []*File
+	infos := make(map[string]*checker.Info)
+	for _, p := range slices.Sorted(maps.Keys(srcs)) {
+		c, err := parser.ParseFile(fset, p, []byte(srcs[p]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, &File{Path: p, Component: c})
+		info, list := checker.Check(fset, filepath.Join("testdata", p), c, loader)
+		if list != nil {
+			t.Fatal(list)
+		}
+		infos[p] = info
+	}
+	g, list := Link(fset, files)
+	if list != nil {
+		t.Fatal(list)
+	}
+	var errs []string
+	for _, e := range g.CheckProps(fset, infos) {
+		errs = append(errs, e.Error())
+	}
+	return errs
+}
+
+func TestCheckProps(t *testing.T) {
+	const parent = `<script>
+    import {User} from "./user.go"
+    import "./Children.html"
+    let user = prop(User())
+    let theme = prop("dark")
+</script>
+`
+	tests := []struct {
+		parent string
+		errs   []string
+	}{
+		{parent + `<div><Children tags={user.tags} theme={theme} size=2/></div>`, nil},
+		{parent + `<div><Children tags="{user.tags}" theme="x {theme}"/></div>`, nil},
+		{parent + `<div><context tags={user.tags}><p><Children/></p></context></div>`, nil},
+		{parent + `<div><Children tags={user.tags} slot="footer"/></div>`, nil},
+		{parent + `<div><Children tags={user.name}/></div>`, []string{
+			"pages/Parent.html:7:16: cannot use user.name (string) as prop tags of <Children> (pages.Tags) [VK008]",
+		}},
+		{parent + `<div><Children tags={user.ints} size="big"/></div>`, []string{
+			"pages/Parent.html:7:16: cannot use user.ints ([]int) as prop tags of <Children> (pages.Tags) [VK008]",
+			"pages/Parent.html:7:33: cannot use \"big\" (untyped string) as prop size of <Children> (int32) [VK008]",
+		}},
+		{parent + `<div><Children tags={user.tags} color="red"/></div>`, []string{
+			"pages/Parent.html:7:33: unknown attribute color, <Children> has no prop color [VK006]",
+		}},
+		{parent + `<div><Children/></div>`, []string{
+			"pages/Parent.html:7:6: missing prop tags of <Children> [VK007]",
+		}},
+		{parent + `<div><context tags={user.name}><Children/></context></div>`, []string{
+			"pages/Parent.html:7:15: cannot use user.name (string) as prop tags of pages/Children.html (pages.Tags) in <context> [VK008]",
+		}},
+		{parent + `<div><context theme={theme}><Children tags={user.tags} theme="light"/></context></div>`, []string{
+			"pages/Parent.html:7:56: attribute theme of <Children> shadows the value of the enclosing <context> at pages/Parent.html:7:15 [VK009]",
+		}},
+	}
+	loader := checker.NewGoLoader()
+	for _, test := range tests {
+		errs := checkProps(t, loader, map[string]string{
+			"pages/Parent.html":   test.parent,
+			"pages/Children.html": children,
+		})
+		if strings.Join(errs, "\n") != strings.Join(test.errs, "\n") {
+			t.Errorf("%s:\ngot  %q\nwant %q", test.parent[len(parent):], errs, test.errs)
+		}
+	}
+}
+
+func TestCheckPropsContext(t *testing.T) {
+	// Top provides tags to every use of Middle, so Middle may omit it.
+	errs := checkProps(t, checker.NewGoLoader(), map[string]string{
+		"pages/Top.html": `<script>
+    import {User} from "./user.go"
+    import "./Middle.html"
+    let user = prop(User())
+</script>
+<div><context tags={user.tags}><Middle/></context></div>`,
+		"pages/Middle.html": `<script>
+    import "./Children.html"
+</script>
+<div><Children/></div>`,
+		"pages/Children.html": children,
+	})
+	if errs != nil {
+		t.Error(errs)
+	}
+
+	// Other uses Middle without a <context>.
+	errs = checkProps(t, checker.NewGoLoader(), map[string]string{
+		"pages/Other.html": `<script>
+    import "./Middle.html"
+</script>
+<div><Middle/></div>`,
+		"pages/Middle.html": `<script>
+    import "./Children.html"
+</script>
+<div><Children/></div>`,
+		"pages/Children.html": children,
+	})
+	if want := "pages/Middle.html:4:6: missing prop tags of <Children> [VK007]"; strings.Join(errs, "\n") != want {
+		t.Errorf("got %q; want %q", errs, want)
+	}
+}
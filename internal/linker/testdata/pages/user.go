@@ -0,0 +1,9 @@
+package pages
+
+type User struct {
+	Name string
+	Tags Tags
+	Ints []int
+}
+
+type Tags []string
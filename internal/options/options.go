@@ -0,0 +1,346 @@
+// Package options loads the configuration of a Vanilla project from the
+// optional vanilla.options.json file at its root. Every option has a default
+// and may be overridden by an environment variable named after its path,
+// aka VANILLA_DEV_PORT for dev.port, which takes precedence over the file.
+//
+// The options are described by the fields of [Options]: their json tag names
+// the option, their doc tag describes it, and the pattern, minimum and
+// maximum tags constrain its value. [Schema] derives the JSON schema of the
+// file from them, published at the root of the repository.
+package options
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/supaleon/vanilla/internal/checker"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+// File is the name of the options file at the root of a project.
+const File = "vanilla.options.json"
+
+// Options is the configuration of a project.
+type Options struct {
+	PagesDir  string   `json:"pagesDir" pattern:"^([A-Za-z0-9_-][A-Za-z0-9_.-]*/)*pages$" doc:"Directory of the components relative to the project root. Its last element must be pages: the paths of the components, aka pages/blog/Index.html, are relative to its parent."`
+	PublicDir string   `json:"publicDir" pattern:"^[A-Za-z0-9_-][A-Za-z0-9_.-]*(/[A-Za-z0-9_-][A-Za-z0-9_.-]*)*$" doc:"Directory of the static files served as is, relative to the project root."`
+	BinDir    string   `json:"binDir" pattern:"^[A-Za-z0-9_-][A-Za-z0-9_.-]*(/[A-Za-z0-9_-][A-Za-z0-9_.-]*)*$" doc:"Directory of the artifacts of the builds relative to the project root."`
+	BaseURL   string   `json:"baseURL" pattern:"^(/|https?://[^/]+/)([^?#]*/)?$" doc:"URL prefix of the site, aka /docs/ or https://example.com/docs/, prefixed to the URLs of the pages and bundles. The server receives the paths without it, aka behind a proxy or http.StripPrefix."`
+	Assets    Assets   `json:"assets" doc:"Bundling of the scripts and stylesheets of the components."`
+	Tailwind  Tailwind `json:"tailwind" doc:"Tailwind CSS integration."`
+	Static    Static   `json:"static" doc:"Static site generation."`
+	Dev       Dev      `json:"dev" doc:"Development server."`
+}
+
+// Assets configures the bundles.
+type Assets struct {
+	Hash       bool `json:"hash" doc:"Name the bundles after the hash of their content, so that clients cache them forever. Otherwise they are revalidated."`
+	Minify     bool `json:"minify" doc:"Minify the bundles of production builds."`
+	SourceMaps bool `json:"sourceMaps" doc:"Write the source maps of the bundles."`
+}
+
+// Tailwind configures the Tailwind CSS integration.
+type Tailwind struct {
+	Enabled bool   `json:"enabled" doc:"Process the input stylesheet with the tailwindcss command of node_modules/.bin, or else of the PATH."`
+	Input   string `json:"input" doc:"Stylesheet holding the Tailwind directives relative to the project root, aka pages/app.css. Components import it as any stylesheet."`
+	Config  string `json:"config" doc:"Tailwind configuration file relative to the project root, if any."`
+}
+
+// Static configures static site generation.
+type Static struct {
+	OutDir string `json:"outDir" pattern:"^([A-Za-z0-9_-][A-Za-z0-9_.-]*(/[A-Za-z0-9_-][A-Za-z0-9_.-]*)*)?$" doc:"Directory of the static site relative to the project root; the static directory of binDir if empty."`
+}
+
+// Dev configures the development server.
+type Dev struct {
+	Port int `json:"port" minimum:"1" maximum:"65535" doc:"Port of the development server."`
+}
+
+// Default returns the default options.
+func Default() *Options {
+	return &Options{
+		PagesDir:  "pages",
+		PublicDir: "public",
+		BinDir:    "bin",
+		BaseURL:   "/",
+		Assets:    Assets{Hash: true, Minify: true, SourceMaps: true},
+		Dev:       Dev{Port: 8080},
+	}
+}
+
+// StaticDir returns the directory of the static site relative to the project
+// root: static.outDir, or else the static directory of binDir.
+func (o *Options) StaticDir() string {
+	if o.Static.OutDir != "" {
+		return o.Static.OutDir
+	}
+	return path.Join(o.BinDir, "static")
+}
+
+// Load returns the options of the project at root: the defaults, overridden
+// by its options file if any, overridden by the environment. Errors of the
+// options are returned as a [checker.ErrorList], positioned in the options
+// file or at the name of the environment variable, aka $VANILLA_DEV_PORT.
+func Load(root string) (*Options, error) {
+	data, err := os.ReadFile(filepath.Join(root, File))
+	if errors.Is(err, os.ErrNotExist) {
+		data, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parse(File, data, os.LookupEnv)
+}
+
+// parse returns the options of the options file filename of content data, or
+// of no file if data is nil, overridden by the variables of lookupEnv.
+func parse(filename string, data []byte, lookupEnv func(string) (string, bool)) (*Options, error) {
+	p := &parser{filename: filename, data: data, pos: make(map[string]token.Position)}
+	opts := Default()
+	v := reflect.ValueOf(opts).Elem()
+	if data != nil {
+		p.object(v, "", 0, data)
+	}
+	p.env(v, "", lookupEnv)
+	if len(p.errors) == 0 {
+		p.validate(v, "")
+	}
+	p.errors.Sort()
+	if err := p.errors.Err(); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+type parser struct {
+	filename string
+	data     []byte
+	pos      map[string]token.Position // of the values set, by option
+	errors   checker.ErrorList
+}
+
+// position returns the position of the byte at offset off of the file.
+func (p *parser) position(off int) token.Position {
+	line := 1 + bytes.Count(p.data[:off], []byte("\n"))
+	col := off - (bytes.LastIndexByte(p.data[:off], '\n') + 1) + 1
+	return token.Position{Filename: p.filename, Offset: off, Line: line, Column: col}
+}
+
+// skip returns the offset of the first byte of data from off which is not
+// white space nor a separator.
+func skip(data []byte, off int) int {
+	for off < len(data) && strings.IndexByte(" \t\r\n,:", data[off]) >= 0 {
+		off++
+	}
+	return off
+}
+
+// object sets the fields of the struct v from the JSON object data, at offset
+// base of the file. prefix is the path of v, aka "dev." for Dev.
+func (p *parser) object(v reflect.Value, prefix string, base int, data []byte) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	syntaxError := func(err error) {
+		off := base + int(dec.InputOffset())
+		var serr *json.SyntaxError
+		if errors.As(err, &serr) {
+			off = base + int(serr.Offset)
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			off, err = base+len(data), errors.New("unexpected end of JSON input")
+		}
+		p.errors.Add(p.position(min(off, len(p.data))), checker.InvalidOption, "invalid JSON: "+strings.TrimPrefix(err.Error(), "json: "))
+	}
+	if tok, err := dec.Token(); err != nil {
+		syntaxError(err)
+		return
+	} else if tok != json.Delim('{') {
+		name := strings.TrimSuffix(prefix, ".")
+		if name == "" {
+			p.errors.Add(p.position(base+skip(data, 0)), checker.InvalidOption, "options must be a JSON object")
+		} else {
+			p.errors.Add(p.position(base+skip(data, 0)), checker.InvalidOption, "option "+name+" must be an object")
+		}
+		return
+	}
+	for dec.More() {
+		keyOff := base + skip(data, int(dec.InputOffset()))
+		tok, err := dec.Token()
+		if err != nil {
+			syntaxError(err)
+			return
+		}
+		key := tok.(string)
+		valOff := base + skip(data, int(dec.InputOffset()))
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			syntaxError(err)
+			return
+		}
+		f, ok := field(v.Type(), key)
+		switch {
+		case ok && f.Type.Kind() == reflect.Struct:
+			p.object(v.FieldByIndex(f.Index), prefix+key+".", valOff, raw)
+		case ok:
+			name := prefix + key
+			p.pos[name] = p.position(valOff)
+			if err := json.Unmarshal(raw, v.FieldByIndex(f.Index).Addr().Interface()); err != nil || raw[0] == 'n' {
+				p.errors.Add(p.pos[name], checker.InvalidOption, "option "+name+" must be "+kindName(f.Type)+", not "+jsonKind(raw))
+			}
+		case prefix == "" && key == "$schema":
+		default:
+			p.errors.Add(p.position(keyOff), checker.UnknownOption, "unknown option "+prefix+key)
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		syntaxError(err)
+	}
+}
+
+// env sets the fields of the struct v, of path prefix, from the environment
+// variables of lookupEnv.
+func (p *parser) env(v reflect.Value, prefix string, lookupEnv func(string) (string, bool)) {
+	for i := range v.NumField() {
+		f := v.Type().Field(i)
+		name := prefix + jsonName(f)
+		if f.Type.Kind() == reflect.Struct {
+			p.env(v.Field(i), name+".", lookupEnv)
+			continue
+		}
+		env := EnvName(name)
+		s, ok := lookupEnv(env)
+		if !ok {
+			continue
+		}
+		p.pos[name] = token.Position{Filename: "$" + env}
+		var err error
+		switch fv := v.Field(i); f.Type.Kind() {
+		case reflect.String:
+			fv.SetString(s)
+		case reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(s)
+			fv.SetBool(b)
+		case reflect.Int:
+			var n int64
+			n, err = strconv.ParseInt(s, 10, 0)
+			fv.SetInt(n)
+		}
+		if err != nil {
+			p.errors.Add(p.pos[name], checker.InvalidOption, "option "+name+" must be "+kindName(f.Type)+", not "+strconv.Quote(s))
+		}
+	}
+}
+
+// validate checks the values of the fields of the struct v, of path prefix,
+// against the constraints of their tags.
+func (p *parser) validate(v reflect.Value, prefix string) {
+	for i := range v.NumField() {
+		f := v.Type().Field(i)
+		name := prefix + jsonName(f)
+		fv := v.Field(i)
+		if f.Type.Kind() == reflect.Struct {
+			p.validate(fv, name+".")
+			continue
+		}
+		invalid := false
+		if pattern := f.Tag.Get("pattern"); pattern != "" {
+			invalid = !regexp.MustCompile(pattern).MatchString(fv.String())
+		}
+		if min := f.Tag.Get("minimum"); min != "" {
+			n, _ := strconv.ParseInt(min, 10, 0)
+			invalid = invalid || fv.Int() < n
+		}
+		if max := f.Tag.Get("maximum"); max != "" {
+			n, _ := strconv.ParseInt(max, 10, 0)
+			invalid = invalid || fv.Int() > n
+		}
+		if invalid {
+			p.errors.Add(p.pos[name], checker.InvalidOption, fmt.Sprintf("invalid value %v of option %s: %s", jsonValue(fv), name, f.Tag.Get("doc")))
+		}
+	}
+	if t, ok := v.Addr().Interface().(*Tailwind); ok && t.Enabled && t.Input == "" {
+		p.errors.Add(p.pos[prefix+"enabled"], checker.InvalidOption, "option "+prefix+"input must be set if "+prefix+"enabled is")
+	}
+}
+
+// field returns the field of the struct type t of the given JSON name.
+func field(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := range t.NumField() {
+		if f := t.Field(i); jsonName(f) == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func jsonName(f reflect.StructField) string {
+	return f.Tag.Get("json")
+}
+
+// EnvName returns the name of the environment variable overriding the option
+// of the given path, aka VANILLA_ASSETS_SOURCE_MAPS for assets.sourceMaps.
+func EnvName(option string) string {
+	var b strings.Builder
+	b.WriteString("VANILLA_")
+	rs := []rune(option)
+	for i, r := range rs {
+		switch {
+		case r == '.':
+			b.WriteByte('_')
+			continue
+		case unicode.IsUpper(r) && i > 0 && rs[i-1] != '.' &&
+			(unicode.IsLower(rs[i-1]) || i+1 < len(rs) && unicode.IsLower(rs[i+1])):
+			b.WriteByte('_') // aka baseURL, not base_U_R_L
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// kindName returns the JSON kind of the values of the Go type t.
+func kindName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int:
+		return "an integer"
+	}
+	return "a string"
+}
+
+// jsonKind returns the kind of the JSON value raw.
+func jsonKind(raw []byte) string {
+	switch raw[0] {
+	case '"':
+		return "a string"
+	case 't', 'f':
+		return "a boolean"
+	case 'n':
+		return "null"
+	case '{':
+		return "an object"
+	case '[':
+		return "an array"
+	}
+	if bytes.ContainsAny(raw, ".eE") {
+		return "a number"
+	}
+	return "an integer"
+}
+
+// jsonValue returns the JSON form of v.
+func jsonValue(v reflect.Value) string {
+	data, _ := json.Marshal(v.Interface())
+	return string(data)
+}
@@ -0,0 +1,132 @@
+package options
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func env(vars ...string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		for i := 0; i+1 < len(vars); i += 2 {
+			if vars[i] == name {
+				return vars[i+1], true
+			}
+		}
+		return "", false
+	}
+}
+
+func TestParse(t *testing.T) {
+	src := `{
+  "$schema": "./vanilla.options.schema.json",
+  "pagesDir": "src/pages",
+  "baseURL": "/docs/",
+  "assets": {"hash": false, "sourceMaps": false},
+  "tailwind": {"enabled": true, "input": "src/pages/app.css"},
+  "dev": {"port": 3000}
+}`
+	opts, err := parse(File, []byte(src), env("VANILLA_DEV_PORT", "4000", "VANILLA_ASSETS_MINIFY", "false"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	want.PagesDir = "src/pages"
+	want.BaseURL = "/docs/"
+	want.Assets = Assets{Hash: false, Minify: false, SourceMaps: false}
+	want.Tailwind = Tailwind{Enabled: true, Input: "src/pages/app.css"}
+	want.Dev.Port = 4000
+	if !reflect.DeepEqual(opts, want) {
+		t.Errorf("got %+v\nwant %+v", opts, want)
+	}
+
+	if opts, err := parse(File, nil, env()); err != nil || !reflect.DeepEqual(opts, Default()) {
+		t.Errorf("without a file: got %+v, %v; want the defaults", opts, err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		env  []string
+		want string
+	}{
+		{`{"pages": "x"}`, nil, "vanilla.options.json:1:2: unknown option pages [VO001]"},
+		{"{\n  \"dev\": {\n    \"host\": \"x\"\n  }\n}", nil, "vanilla.options.json:3:5: unknown option dev.host [VO001]"},
+		{`{"dev": {"port": "80"}}`, nil, "vanilla.options.json:1:18: option dev.port must be an integer, not a string [VO002]"},
+		{`{"dev": {"port": 80.5}}`, nil, "vanilla.options.json:1:18: option dev.port must be an integer, not a number [VO002]"},
+		{`{"assets": {"hash": null}}`, nil, "vanilla.options.json:1:21: option assets.hash must be a boolean, not null [VO002]"},
+		{`{"dev": 8080}`, nil, "vanilla.options.json:1:9: option dev must be an object [VO002]"},
+		{`[]`, nil, "vanilla.options.json:1:1: options must be a JSON object [VO002]"},
+		{`{"dev": {"port": 0}}`, nil, "vanilla.options.json:1:18: invalid value 0 of option dev.port: Port of the development server. [VO002]"},
+		{`{"pagesDir": "../pages"}`, nil, `vanilla.options.json:1:14: invalid value "../pages" of option pagesDir: Directory of the components relative to the project root. Its last element must be pages: the paths of the components, aka pages/blog/Index.html, are relative to its parent. [VO002]`},
+		{`{"baseURL": "docs"}`, nil, `vanilla.options.json:1:13: invalid value "docs" of option baseURL: URL prefix of the site, aka /docs/ or https://example.com/docs/, prefixed to the URLs of the pages and bundles. The server receives the paths without it, aka behind a proxy or http.StripPrefix. [VO002]`},
+		{`{"tailwind": {"enabled": true}}`, nil, "vanilla.options.json:1:26: option tailwind.input must be set if tailwind.enabled is [VO002]"},
+		{`{"dev": {"port": 80`, nil, "vanilla.options.json:1:20: invalid JSON: unexpected end of JSON input [VO002]"},
+		{`{}`, []string{"VANILLA_DEV_PORT", "http"}, `$VANILLA_DEV_PORT: option dev.port must be an integer, not "http" [VO002]`},
+		{`{}`, []string{"VANILLA_BIN_DIR", "/bin"}, `$VANILLA_BIN_DIR: invalid value "/bin" of option binDir: Directory of the artifacts of the builds relative to the project root. [VO002]`},
+	}
+	for _, test := range tests {
+		_, err := parse(File, []byte(test.src), env(test.env...))
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%s %q:\ngot  %v\nwant %s", test.src, test.env, err, test.want)
+		}
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	for option, want := range map[string]string{
+		"pagesDir":          "VANILLA_PAGES_DIR",
+		"baseURL":           "VANILLA_BASE_URL",
+		"assets.sourceMaps": "VANILLA_ASSETS_SOURCE_MAPS",
+		"dev.port":          "VANILLA_DEV_PORT",
+	} {
+		if got := EnvName(option); got != want {
+			t.Errorf("EnvName(%q) = %s, want %s", option, got, want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("VANILLA_STATIC_OUT_DIR", "dist")
+	opts, err := Load(root)
+	if err != nil || opts.Static.OutDir != "dist" {
+		t.Errorf("without a file: got %+v, %v", opts, err)
+	}
+	if err := os.WriteFile(filepath.Join(root, File), []byte(`{"binDir": "out"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if opts, err = Load(root); err != nil || opts.BinDir != "out" || opts.StaticDir() != "dist" {
+		t.Errorf("got %+v, %v", opts, err)
+	}
+
+	// the static site follows the binaries by default
+	t.Setenv("VANILLA_STATIC_OUT_DIR", "")
+	if opts, err = Load(root); err != nil || opts.StaticDir() != "out/static" {
+		t.Errorf("without static.outDir: got %+v, %v", opts, err)
+	}
+}
+
+var update = flag.Bool("update", false, "update the published schema")
+
+// TestSchema checks that the published schema is up to date; run with -update
+// to update it.
+func TestSchema(t *testing.T) {
+	name := filepath.Join("..", "..", SchemaFile)
+	if *update {
+		if err := os.WriteFile(name, Schema(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, Schema()) {
+		t.Errorf("%s is out of date, run go test -run TestSchema -update ./internal/options", SchemaFile)
+	}
+}
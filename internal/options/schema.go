@@ -0,0 +1,64 @@
+package options
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+)
+
+// SchemaFile is the name of the published JSON schema of the options file.
+const SchemaFile = "vanilla.options.schema.json"
+
+// Schema returns the JSON schema of the options file, indented.
+func Schema() []byte {
+	s := object(reflect.ValueOf(Default()).Elem())
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["title"] = "Vanilla options"
+	s["description"] = "Configuration of a Vanilla project, read from " + File + " at its root."
+	s["properties"].(map[string]any)["$schema"] = map[string]any{
+		"type":        "string",
+		"description": "URI of the JSON schema of the file, ignored.",
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(s)
+	return buf.Bytes()
+}
+
+// object returns the schema of the options of the struct v, whose values are
+// the defaults.
+func object(v reflect.Value) map[string]any {
+	props := make(map[string]any)
+	for i := range v.NumField() {
+		f := v.Type().Field(i)
+		var s map[string]any
+		switch f.Type.Kind() {
+		case reflect.Struct:
+			s = object(v.Field(i))
+		case reflect.Bool:
+			s = map[string]any{"type": "boolean", "default": v.Field(i).Bool()}
+		case reflect.Int:
+			s = map[string]any{"type": "integer", "default": v.Field(i).Int()}
+		default:
+			s = map[string]any{"type": "string", "default": v.Field(i).String()}
+		}
+		s["description"] = f.Tag.Get("doc")
+		if pattern := f.Tag.Get("pattern"); pattern != "" {
+			s["pattern"] = pattern
+		}
+		for _, key := range []string{"minimum", "maximum"} {
+			if n, err := strconv.Atoi(f.Tag.Get(key)); err == nil {
+				s[key] = n
+			}
+		}
+		props[jsonName(f)] = s
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+}
@@ -0,0 +1,399 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"github.com/supaleon/vanilla/internal/ast"
+	"github.com/supaleon/vanilla/internal/token"
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/js"
+)
+
+// NB: esbuild keeps its JS parser internal, so the script code block is parsed
+// with tdewolff/parse, whose AST is public. The AST carries no positions, but
+// every name and literal is a subslice of the source buffer; their offsets are
+// recovered by lexing the same buffer and summing the lengths of the tokens.
+
+// esParser extracts the imports and prop declarations of a script code block.
+type esParser struct {
+	*Parser
+	module *ast.ESModule
+	start  token.Loc     // position of the script text
+	buf    []byte        // script text; cap(buf) == len(buf)+1 so that the lexer does not copy it
+	tokens map[*byte]int // offsets of the tokens of buf, by their first byte
+
+	goTypes map[string]bool         // names imported from .go files
+	props   map[*js.CallExpr]string // prop() calls of prop declarations
+}
+
+// parseESModule parses the script code block of module.
+func (p *Parser) parseESModule(module *ast.ESModule) {
+	var text *ast.Text
+	for _, n := range module.Script.Children {
+		if t, ok := n.(*ast.Text); ok {
+			text = t
+		}
+	}
+	if text == nil {
+		return
+	}
+
+	e := &esParser{
+		Parser:  p,
+		module:  module,
+		start:   text.Start,
+		buf:     make([]byte, len(text.Value), len(text.Value)+1),
+		goTypes: make(map[string]bool),
+		props:   make(map[*js.CallExpr]string),
+	}
+	copy(e.buf, text.Value)
+	e.tokens = lexOffsets(e.buf)
+	program, err := js.Parse(parse.NewInputBytes(e.buf), js.Options{})
+	if err != nil {
+		var perr *parse.Error
+		if errors.As(err, &perr) {
+			p.error(e.lineLoc(perr.Line, perr.Column), perr.Message)
+		} else {
+			p.error(e.start, err.Error())
+		}
+		return
+	}
+
+	for _, stmt := range program.List {
+		if s, ok := stmt.(*js.ImportStmt); ok {
+			e.parseImport(s)
+		}
+	}
+	for _, stmt := range program.List {
+		if d, ok := stmt.(*js.VarDecl); ok {
+			e.parseVarDecl(d)
+		}
+	}
+	// The prop macro is only expanded in top-level declarations.
+	js.Walk(e, program)
+}
+
+// loc returns the position of data, a name or literal of the script text.
+func (e *esParser) loc(data []byte) token.Loc {
+	if len(data) == 0 {
+		return e.start
+	}
+	off, ok := e.tokens[&data[0]]
+	if !ok {
+		return e.start
+	}
+	return e.start + token.Loc(off)
+}
+
+// lexOffsets returns the offsets of the tokens of buf, keyed by the address of
+// their first byte, which the lexemes of the parser share as neither copies buf.
+func lexOffsets(buf []byte) map[*byte]int {
+	tokens := make(map[*byte]int)
+	l := js.NewLexer(parse.NewInputBytes(buf))
+	prev := js.ErrorToken // last token other than whitespace and comments
+	for off := 0; ; {
+		tt, data := l.Next()
+		if (tt == js.DivToken || tt == js.DivEqToken) && !endsOperand(prev) {
+			tt, data = l.RegExp()
+		}
+		if tt == js.ErrorToken {
+			return tokens
+		}
+		if len(data) > 0 {
+			tokens[&data[0]] = off
+		}
+		off += len(data)
+		switch tt {
+		case js.WhitespaceToken, js.LineTerminatorToken, js.CommentToken, js.CommentLineTerminatorToken:
+		default:
+			prev = tt
+		}
+	}
+}
+
+// endsOperand reports whether a slash after a token of type tt is a division
+// rather than the start of a regular expression.
+func endsOperand(tt js.TokenType) bool {
+	switch tt {
+	case js.StringToken, js.RegExpToken, js.TemplateToken, js.TemplateEndToken,
+		js.CloseParenToken, js.CloseBracketToken, js.CloseBraceToken,
+		js.ThisToken, js.SuperToken, js.NullToken, js.TrueToken, js.FalseToken,
+		js.IncrToken, js.DecrToken:
+		return true
+	}
+	return js.IsIdentifier(tt) || js.IsNumeric(tt)
+}
+
+// lineLoc returns the position of the 1-based line and column of the script text.
+func (e *esParser) lineLoc(line, column int) token.Loc {
+	off := 0
+	for ; line > 1; line-- {
+		i := bytes.IndexByte(e.buf[off:], '\n')
+		if i < 0 {
+			break
+		}
+		off += i + 1
+	}
+	return e.start + token.Loc(min(off+column-1, len(e.buf)))
+}
+
+// ----------------------------------------------------------------------------
+// Imports
+
+func (e *esParser) parseImport(s *js.ImportStmt) {
+	spec := &ast.ImportSpec{
+		Kind:    ast.ImportSTMT,
+		Path:    unquote(s.Module),
+		PathLoc: e.loc(s.Module),
+	}
+	spec.File = ast.FileKindOf(spec.Path)
+	if s.Default != nil {
+		spec.Form = ast.ImportDefault
+		spec.Name = &ast.Ident{NameLoc: e.loc(s.Default), Name: string(s.Default)}
+	}
+	for _, alias := range s.List {
+		spec.Form = ast.ImportNamed
+		if alias.Name != nil {
+			// `{User as U}` or `* as x`
+			loc := e.loc(alias.Binding)
+			if n := e.loc(alias.Name); n != e.start {
+				loc = n
+			}
+			e.error(loc, "aliased imports are not supported")
+			continue
+		}
+		spec.Names = append(spec.Names, &ast.Ident{NameLoc: e.loc(alias.Binding), Name: string(alias.Binding)})
+	}
+
+	switch spec.File {
+	case ast.FileComponent:
+		if len(s.List) > 0 {
+			e.error(spec.PathLoc, "components must be imported by a bare or default import")
+		}
+	case ast.FileGo:
+		if s.Default != nil || len(s.List) == 0 {
+			e.error(spec.PathLoc, "Go types must be imported by name, aka `import {User} from \"./user.go\"`")
+		}
+		for _, name := range spec.Names {
+			e.goTypes[name.Name] = true
+		}
+	case ast.FileCSS:
+		if s.Default != nil || len(s.List) > 0 {
+			e.error(spec.PathLoc, "stylesheets must be imported by a bare import")
+		}
+	}
+	e.module.Imports = append(e.module.Imports, spec)
+}
+
+// parseDynamicImport records call if it is an `import("./x.js")` expression.
+func (e *esParser) parseDynamicImport(call *js.CallExpr) {
+	if x, ok := call.X.(*js.LiteralExpr); !ok || x.TokenType != js.ImportToken || len(call.Args.List) == 0 {
+		return
+	}
+	lit, ok := call.Args.List[0].Value.(*js.LiteralExpr)
+	if !ok || lit.TokenType != js.StringToken {
+		return
+	}
+	spec := &ast.ImportSpec{
+		Kind:    ast.ImportDynamic,
+		Path:    unquote(lit.Data),
+		PathLoc: e.loc(lit.Data),
+	}
+	spec.File = ast.FileKindOf(spec.Path)
+	e.module.Imports = append(e.module.Imports, spec)
+}
+
+func unquote(lit []byte) string {
+	if len(lit) >= 2 {
+		return string(lit[1 : len(lit)-1])
+	}
+	return string(lit)
+}
+
+// ----------------------------------------------------------------------------
+// Prop declarations
+
+// propCall returns the prop macro call of expr, or nil.
+func propCall(expr js.INode) *js.CallExpr {
+	if call, ok := expr.(*js.CallExpr); ok {
+		if v, ok := call.X.(*js.Var); ok && string(v.Data) == "prop" {
+			return call
+		}
+	}
+	return nil
+}
+
+func (e *esParser) parseVarDecl(d *js.VarDecl) {
+	for _, elem := range d.List {
+		call := propCall(elem.Default)
+		if call == nil {
+			continue
+		}
+		v, ok := elem.Binding.(*js.Var)
+		if !ok {
+			e.props[call] = ""
+			e.error(e.bindingLoc(elem.Binding), "prop declarations do not support destructuring")
+			continue
+		}
+		e.props[call] = string(v.Data)
+		decl := &ast.PropDecl{Name: &ast.Ident{NameLoc: e.loc(v.Data), Name: string(v.Data)}}
+		decl.End = e.callEnd(decl.Name.NameLoc)
+		if d.TokenType != js.LetToken {
+			e.error(decl.Name.NameLoc, "prop declarations must use the let keyword")
+		}
+		if e.parsePropArg(decl, call) {
+			e.module.Props = append(e.module.Props, decl)
+		}
+	}
+}
+
+// parsePropArg sets the kind of decl from the argument of the prop macro call
+// and reports whether the argument is valid.
+func (e *esParser) parsePropArg(decl *ast.PropDecl, call *js.CallExpr) bool {
+	loc := decl.Name.NameLoc
+	if len(call.Args.List) != 1 || call.Args.List[0].Rest {
+		e.error(loc, "prop macro requires exactly one argument")
+		return false
+	}
+	switch arg := call.Args.List[0].Value.(type) {
+	case *js.CallExpr:
+		// prop(User())
+		v, ok := arg.X.(*js.Var)
+		if !ok || len(arg.Args.List) > 0 {
+			break
+		}
+		name := string(v.Data)
+		decl.Kind = ast.PropGoType
+		decl.Type = &ast.Ident{NameLoc: e.nameLoc(decl, name), Name: name}
+		if !e.goTypes[name] {
+			e.error(decl.Type.NameLoc, name+" is not a Go type imported from a .go file")
+			return false
+		}
+		return true
+	case *js.LiteralExpr:
+		decl.Value = string(arg.Data)
+		switch arg.TokenType {
+		case js.TrueToken, js.FalseToken:
+			decl.Kind = ast.PropBool
+			return true
+		case js.StringToken:
+			decl.Kind = ast.PropString
+			return true
+		case js.DecimalToken, js.IntegerToken, js.BinaryToken, js.OctalToken, js.HexadecimalToken:
+			decl.Kind = ast.PropInt
+			if arg.TokenType == js.DecimalToken && bytes.ContainsAny(arg.Data, ".eE") {
+				decl.Kind = ast.PropFloat
+			}
+			return true
+		}
+	case *js.UnaryExpr:
+		// prop(-1)
+		if lit, ok := arg.X.(*js.LiteralExpr); ok && arg.Op == js.NegToken {
+			decl.Value = "-" + string(lit.Data)
+			switch lit.TokenType {
+			case js.DecimalToken, js.IntegerToken:
+				decl.Kind = ast.PropInt
+				if bytes.ContainsAny(lit.Data, ".eE") {
+					decl.Kind = ast.PropFloat
+				}
+				return true
+			}
+		}
+	case *js.ArrayExpr:
+		if len(arg.List) == 0 {
+			decl.Kind = ast.PropArray
+			return true
+		}
+		e.error(loc, "prop macro only accepts an empty array literal, aka `[]`")
+		return false
+	case *js.ObjectExpr:
+		if len(arg.List) == 0 {
+			decl.Kind = ast.PropObject
+			return true
+		}
+		e.error(loc, "prop macro only accepts an empty object literal, aka `{}`")
+		return false
+	}
+	e.error(loc, "prop macro only accepts a Go type instance, aka `User()`, or a JS literal")
+	return false
+}
+
+// bindingLoc returns the position of the first name bound by b.
+func (e *esParser) bindingLoc(b js.IBinding) token.Loc {
+	switch b := b.(type) {
+	case *js.Var:
+		return e.loc(b.Data)
+	case *js.BindingArray:
+		for _, elem := range b.List {
+			if elem.Binding != nil {
+				return e.bindingLoc(elem.Binding)
+			}
+		}
+	case *js.BindingObject:
+		for _, item := range b.List {
+			if item.Value.Binding != nil {
+				return e.bindingLoc(item.Value.Binding)
+			}
+		}
+	}
+	return e.start
+}
+
+// callEnd returns the position after the closing parenthesis of the prop macro
+// call of the declaration whose name is at loc.
+func (e *esParser) callEnd(loc token.Loc) token.Loc {
+	off := int(loc - e.start)
+	i := bytes.IndexByte(e.buf[off:], '(')
+	if i < 0 {
+		return loc
+	}
+	depth := 0
+	var quote byte
+	for off += i; off < len(e.buf); off++ {
+		switch c := e.buf[off]; {
+		case quote != 0:
+			if c == '\\' {
+				off++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			if depth--; depth == 0 {
+				return e.start + token.Loc(off+1)
+			}
+		}
+	}
+	return e.start + token.Loc(off)
+}
+
+// nameLoc returns the position of name within the prop macro call of decl.
+func (e *esParser) nameLoc(decl *ast.PropDecl, name string) token.Loc {
+	src := string(e.buf[decl.Name.NameLoc-e.start : decl.End-e.start])
+	if i := strings.Index(src[len(decl.Name.Name):], name); i >= 0 {
+		return decl.Name.NameLoc + token.Loc(len(decl.Name.Name)+i)
+	}
+	return decl.Name.NameLoc
+}
+
+// Enter implements js.IVisitor; it records dynamic imports and reports prop
+// macro calls outside of top-level prop declarations.
+func (e *esParser) Enter(n js.INode) js.IVisitor {
+	if call, ok := n.(*js.CallExpr); ok {
+		e.parseDynamicImport(call)
+	}
+	if call := propCall(n); call != nil {
+		if _, ok := e.props[call]; !ok {
+			e.error(e.loc(call.X.(*js.Var).Data), "prop macro can only initialize a top-level let declaration")
+		}
+	}
+	return e
+}
+
+// Exit implements js.IVisitor.
+func (e *esParser) Exit(js.INode) {}
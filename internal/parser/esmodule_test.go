@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/supaleon/vanilla/internal/ast"
+	"github.com/supaleon/vanilla/internal/scanner"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+const module = `<script>
+    import "./Item.html"
+    import Card from "./Card.html"
+    import {User, Post} from "./user.go"
+    import "./theme.css"
+    let user = prop(User())
+    let open = prop(false)
+    let count = prop(-1)
+    let ratio = prop(0.8)
+    let theme = prop("dark")
+    let tags = prop([])
+    let meta = prop({})
+    const local = 1
+    import("./chart.js").then(m => m.draw())
+</script>`
+
+func TestESModule(t *testing.T) {
+	fset := token.NewFileSet()
+	c, err := ParseFile(fset, "Hello.html", []byte(module))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := c.ESModule
+
+	imports := []struct {
+		kind ast.ImportKind
+		form ast.ImportForm
+		file ast.FileKind
+		path string
+		pos  string
+	}{
+		{ast.ImportSTMT, ast.ImportBare, ast.FileComponent, "./Item.html", "2:12"},
+		{ast.ImportSTMT, ast.ImportDefault, ast.FileComponent, "./Card.html", "3:22"},
+		{ast.ImportSTMT, ast.ImportNamed, ast.FileGo, "./user.go", "4:30"},
+		{ast.ImportSTMT, ast.ImportBare, ast.FileCSS, "./theme.css", "5:12"},
+		{ast.ImportDynamic, ast.ImportBare, ast.FileJS, "./chart.js", "14:12"},
+	}
+	if len(m.Imports) != len(imports) {
+		t.Fatalf("got %d imports; want %d", len(m.Imports), len(imports))
+	}
+	for i, want := range imports {
+		s := m.Imports[i]
+		pos := fset.Position(s.PathLoc)
+		if s.Kind != want.kind || s.Form != want.form || s.File != want.file || s.Path != want.path ||
+			want.pos != fmt.Sprintf("%d:%d", pos.Line, pos.Column) {
+			t.Errorf("import %d: got %+v at %d:%d; want %+v", i, s, pos.Line, pos.Column, want)
+		}
+	}
+	if name := m.Imports[1].Name; name == nil || name.Name != "Card" {
+		t.Errorf("got default binding %v; want Card", name)
+	}
+	if names := m.Imports[2].Names; len(names) != 2 || names[0].Name != "User" || names[1].Name != "Post" {
+		t.Errorf("got named bindings %v; want User, Post", names)
+	}
+
+	props := []struct {
+		name  string
+		kind  ast.PropKind
+		value string
+		src   string
+	}{
+		{"user", ast.PropGoType, "", "user = prop(User())"},
+		{"open", ast.PropBool, "false", "open = prop(false)"},
+		{"count", ast.PropInt, "-1", "count = prop(-1)"},
+		{"ratio", ast.PropFloat, "0.8", "ratio = prop(0.8)"},
+		{"theme", ast.PropString, `"dark"`, `theme = prop("dark")`},
+		{"tags", ast.PropArray, "", "tags = prop([])"},
+		{"meta", ast.PropObject, "", "meta = prop({})"},
+	}
+	if len(m.Props) != len(props) {
+		t.Fatalf("got %d props; want %d", len(m.Props), len(props))
+	}
+	base := token.Loc(fset.File(1).Base())
+	for i, want := range props {
+		d := m.Props[i]
+		r := d.Range()
+		src := module[r.Start-base : r.End-base]
+		if d.Name.Name != want.name || d.Kind != want.kind || d.Value != want.value || src != want.src {
+			t.Errorf("prop %d: got %s (kind %d, value %q, source %q); want %+v",
+				i, d.Name.Name, d.Kind, d.Value, src, want)
+		}
+	}
+	if typ := m.Props[0].Type; typ == nil || typ.Name != "User" {
+		t.Errorf("got type %v; want User", typ)
+	}
+}
+
+func TestESModuleErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{"<script>\nconst a = prop(1)\n</script>", "2:7: prop declarations must use the let keyword"},
+		{"<script>\nvar a = prop(1)\n</script>", "2:5: prop declarations must use the let keyword"},
+		{"<script>\nlet {a} = prop({})\n</script>", "2:6: prop declarations do not support destructuring"},
+		{"<script>\nlet [a] = prop([])\n</script>", "2:6: prop declarations do not support destructuring"},
+		{"<script>\nlet a = prop(b)\n</script>", "2:5: prop macro only accepts a Go type instance, aka `User()`, or a JS literal"},
+		{"<script>\nlet a = prop(1 + 2)\n</script>", "2:5: prop macro only accepts a Go type instance, aka `User()`, or a JS literal"},
+		{"<script>\nlet a = prop()\n</script>", "2:5: prop macro requires exactly one argument"},
+		{"<script>\nlet a = prop([1])\n</script>", "2:5: prop macro only accepts an empty array literal, aka `[]`"},
+		{"<script>\nlet a = prop({b: 1})\n</script>", "2:5: prop macro only accepts an empty object literal, aka `{}`"},
+		{"<script>\nlet a = prop(User())\n</script>", "2:14: User is not a Go type imported from a .go file"},
+		{"<script>\nlet a = prop(1)\nlet a = prop(2)\n</script>", "3:5: identifier a has already been declared"},
+		{"<script>\nfunction f() { let a = prop(1) }\n</script>", "2:24: prop macro can only initialize a top-level let declaration"},
+		{"<script>\nimport {User as U} from \"./user.go\"\n</script>", "2:9: aliased imports are not supported"},
+		{"<script>\nimport User from \"./user.go\"\n</script>", "2:18: Go types must be imported by name, aka `import {User} from \"./user.go\"`"},
+		{"<script>\nimport {Card} from \"./Card.html\"\n</script>", "2:20: components must be imported by a bare or default import"},
+		{"<script>\nimport theme from \"./theme.css\"\n</script>", "2:19: stylesheets must be imported by a bare import"},
+		{"<script>\nlet r = /[/]prop(/g, n = r.length / 2, t = `${n}/${`/`}`; function f() { let a = prop(1) }\n</script>", "2:82: prop macro can only initialize a top-level let declaration"},
+		{"<script>\nlet a = (\n</script>", "3:1: unexpected EOF in expression"},
+	}
+	for _, test := range tests {
+		_, err := ParseFile(token.NewFileSet(), "", []byte(test.src))
+		var list scanner.ErrorList
+		if !errors.As(err, &list) {
+			t.Errorf("%q: got %v; want error %q", test.src, err, test.err)
+			continue
+		}
+		if got := list[0].Error(); got != test.err {
+			t.Errorf("%q: got error %q; want %q", test.src, got, test.err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package parser
+
+import (
+	"github.com/supaleon/vanilla/internal/ast"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+// exprMode restricts the expressions allowed in a code block.
+type exprMode uint8
+
+const (
+	valueExpr exprMode = iota // {x}, {x: text}, {x %.2f} and attribute expressions
+	ifExpr                    // {if x}
+	forExpr                   // {for k, v in x}
+)
+
+// precedence returns the operator precedence of the binary operator tok.
+// If tok is not a binary operator, the result is 0.
+func precedence(tok token.Token) int {
+	switch tok {
+	case token.OR:
+		return 1
+	case token.AND:
+		return 2
+	case token.EQ, token.NE, token.LT, token.LE, token.GT, token.GE:
+		return 3
+	}
+	return 0
+}
+
+// parseExpr parses the expression of a code block; mode determines which
+// operators are allowed.
+func (p *Parser) parseExpr(mode exprMode) ast.Expr {
+	p.mode = mode
+	x := p.parseBinaryExpr(1)
+	if p.tok == token.DOTDot {
+		loc := p.loc
+		p.next()
+		if mode != forExpr {
+			p.error(loc, "range expression is only allowed in {for} block")
+		}
+		x = &ast.Range{Low: x, OpLoc: loc, High: p.parseBinaryExpr(1)}
+	}
+	return x
+}
+
+// parseBinaryExpr parses a binary expression whose operators have at least
+// the precedence prec1.
+func (p *Parser) parseBinaryExpr(prec1 int) ast.Expr {
+	x := p.parseUnaryExpr()
+	for {
+		oprec := precedence(p.tok)
+		if oprec < prec1 {
+			return x
+		}
+		op, loc := p.tok, p.loc
+		p.next()
+		if p.mode != ifExpr {
+			p.error(loc, "logical and comparison operators are only allowed in {if} block")
+		}
+		y := p.parseBinaryExpr(oprec + 1)
+		x = &ast.Binary{X: x, OpLoc: loc, Op: op, Y: y}
+	}
+}
+
+func (p *Parser) parseUnaryExpr() ast.Expr {
+	switch p.tok {
+	case token.NOT, token.SUB:
+		loc, op := p.loc, p.tok
+		p.next()
+		x := p.parseUnaryExpr()
+		return &ast.Unary{OpLoc: loc, Op: op, X: x}
+	}
+	return p.parsePrimaryExpr()
+}
+
+func (p *Parser) parsePrimaryExpr() ast.Expr {
+	x := p.parseOperand()
+	for {
+		switch p.tok {
+		case token.DOT:
+			p.next()
+			x = &ast.Selector{X: x, Sel: p.parseIdent()}
+		case token.LBRACKET:
+			lbrack := p.loc
+			p.next()
+			index := p.parseBinaryExpr(1)
+			if lit, ok := index.(*ast.BasicLit); ok && (lit.Kind == token.STRING || lit.Kind == token.CHAR) {
+				p.error(lit.ValueLoc, "bracket notation access is not supported, use a selector instead")
+			}
+			rbrack := p.expect(token.RBRACKET)
+			x = &ast.Index{X: x, LBrack: lbrack, Index: index, RBrack: rbrack}
+		case token.LPAREN:
+			fun, ok := x.(*ast.Ident)
+			if !ok {
+				p.error(x.Range().Start, "only built-in functions can be called")
+				fun = &ast.Ident{NameLoc: x.Range().Start}
+			}
+			x = p.parseCall(fun)
+		default:
+			return x
+		}
+	}
+}
+
+func (p *Parser) parseOperand() ast.Expr {
+	switch p.tok {
+	case token.IDENT:
+		return p.parseIdent()
+	case token.INT, token.FLOAT, token.STRING, token.CHAR, token.TRUE, token.FALSE:
+		x := &ast.BasicLit{ValueLoc: p.loc, Kind: p.tok, Value: p.lit}
+		p.next()
+		return x
+	case token.LPAREN:
+		lparen := p.loc
+		p.next()
+		x := p.parseBinaryExpr(1)
+		rparen := p.expect(token.RPAREN)
+		return &ast.Paren{LParen: lparen, X: x, RParen: rparen}
+	case token.ILLEGAL:
+		// already reported by the scanner
+		x := &ast.BadExpr{From: p.loc, To: p.end()}
+		p.next()
+		return x
+	}
+	p.errorExpected(p.loc, "operand")
+	return &ast.BadExpr{From: p.loc, To: p.loc}
+}
+
+// parseCall parses the arguments of a call of fun; p.tok is LPAREN.
+func (p *Parser) parseCall(fun *ast.Ident) *ast.Call {
+	if p.inCall {
+		p.error(fun.NameLoc, "nested function calls are not supported")
+	}
+	inCall := p.inCall
+	p.inCall = true
+	c := &ast.Call{Fun: fun, LParen: p.loc}
+	p.next()
+	for p.tok != token.RPAREN && isExprToken(p.tok) {
+		c.Args = append(c.Args, p.parseBinaryExpr(1))
+		if p.tok != token.COMMA {
+			break
+		}
+		p.next()
+	}
+	c.RParen = p.expect(token.RPAREN)
+	p.inCall = inCall
+	return c
+}
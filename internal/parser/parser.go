@@ -1,14 +1,532 @@
+// Package parser implements a parser for Vanilla component source files.
+// The parser drives a [scanner.Scanner] and builds an [ast.Component];
+// all scanning and parsing errors are collected into a [scanner.ErrorList].
 package parser
 
-type state uint8
+import (
+	"slices"
+	"strings"
+	"unicode"
 
-const (
-	inText state = iota
-	inTag
+	"github.com/supaleon/vanilla/internal/ast"
+	"github.com/supaleon/vanilla/internal/scanner"
+	"github.com/supaleon/vanilla/internal/token"
 )
 
+// endTag is an end tag which has been scanned but not yet matched with its start tag.
+type endTag struct {
+	start token.Loc // position of `</`
+	name  string
+	end   token.Loc // position after `>`
+}
+
+// Parser holds the parser's internal state.
 type Parser struct {
-	//tokenizer *scanner.Scanner
+	file    *token.File
+	src     []byte
+	errors  scanner.ErrorList
+	scanner *scanner.Scanner
+
+	// Next token
+	loc token.Loc   // token position
+	tok token.Token // one token look-ahead
+	lit string      // token literal
+	gap token.Loc   // end of the previous token; the scanner skips whitespace
+
+	mode   exprMode // expression mode of the current code block
+	inCall bool     // whether the arguments of a call are being parsed
+
+	elems  []string  // names of the open elements, innermost last
+	endTag *endTag   // pending end tag, if any
+	lbrace token.Loc // position of `{` of a pending `{else}` or `{/...}`
 }
 
+// ParseFile parses the component source src and returns the corresponding
+// [ast.Component] node. The file is registered in fset under filename.
 //
+// If the source couldn't be read or contains errors, the returned component
+// is partially filled and err is a [scanner.ErrorList] sorted by position.
+func ParseFile(fset *token.FileSet, filename string, src []byte) (c *ast.Component, err error) {
+	var p Parser
+	p.init(fset, filename, src)
+	c = p.parseComponent()
+	p.errors.Sort()
+	return c, p.errors.Err()
+}
+
+func (p *Parser) init(fset *token.FileSet, filename string, src []byte) {
+	p.file = fset.AddFile(filename, -1, len(src))
+	p.src = src
+	p.scanner = scanner.New(p.file, src, func(pos token.Position, msg string) {
+		p.errors.Add(pos, msg)
+	})
+	p.next()
+	p.gap = p.loc
+}
+
+// ----------------------------------------------------------------------------
+// Parsing support
+
+// next advances to the next token.
+func (p *Parser) next() {
+	p.gap = p.end()
+	p.loc, p.tok, p.lit = p.scanner.Scan()
+}
+
+// space returns the whitespace skipped by the scanner before the current token.
+func (p *Parser) space() string {
+	if p.gap >= p.loc {
+		return ""
+	}
+	from, to := p.file.Offset(p.gap), p.file.Offset(p.loc)
+	if to > len(p.src) || strings.TrimSpace(string(p.src[from:to])) != "" {
+		return ""
+	}
+	return string(p.src[from:to])
+}
+
+// end returns the position immediately after the current token.
+func (p *Parser) end() token.Loc {
+	if p.lit != "" {
+		return p.loc + token.Loc(len(p.lit))
+	}
+	if p.tok.IsOperator() {
+		return p.loc + token.Loc(len(p.tok.String()))
+	}
+	return p.loc
+}
+
+func (p *Parser) error(loc token.Loc, msg string) {
+	p.errors.Add(p.file.Position(loc), msg)
+}
+
+func (p *Parser) errorExpected(loc token.Loc, msg string) {
+	msg = "expected " + msg
+	if loc == p.loc {
+		// the error happened at the current position;
+		// make the error message more specific
+		switch {
+		case p.tok == token.EOF:
+			msg += ", found EOF"
+		case p.tok.IsLiteral() || p.tok.IsKeyword() || p.tok == token.TAGName:
+			msg += ", found " + p.lit
+		default:
+			msg += ", found '" + p.tok.String() + "'"
+		}
+	}
+	p.error(loc, msg)
+}
+
+func (p *Parser) expect(tok token.Token) (loc token.Loc) {
+	loc = p.loc
+	if p.tok != tok {
+		p.errorExpected(loc, "'"+tok.String()+"'")
+		return
+	}
+	p.next() // make progress
+	return
+}
+
+// isExprToken reports whether tok may appear within an expression of a code block.
+func isExprToken(tok token.Token) bool {
+	switch tok {
+	case token.ILLEGAL: // already reported by the scanner
+		return true
+	case token.LBRACE, token.RBRACE, token.SLASH, token.STARTTagOpen, token.TAGClose,
+		token.TAGSelfClose, token.ENDTagOpen, token.ATTRValSep, token.FMT, token.CONDText:
+		return false
+	}
+	return tok.IsLiteral() || tok.IsKeyword() || tok.IsOperator()
+}
+
+// skipCodeBlock skips the rest of a malformed code block including its `}`.
+func (p *Parser) skipCodeBlock() {
+	for isExprToken(p.tok) || p.tok == token.SLASH {
+		p.next()
+	}
+	if p.tok == token.RBRACE {
+		p.next()
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Component
+
+func (p *Parser) parseComponent() *ast.Component {
+	c := &ast.Component{Template: &ast.Template{}}
+	// whitespace between top-level nodes is insignificant
+	nodes := slices.DeleteFunc(p.parseNodes(false), func(n ast.Node) bool {
+		t, ok := n.(*ast.Text)
+		return ok && strings.TrimSpace(t.Value) == ""
+	})
+	if len(nodes) > 0 {
+		if script, ok := nodes[0].(*ast.Element); ok && script.Name == "script" {
+			c.ESModule = &ast.ESModule{Script: script}
+			p.parseESModule(c.ESModule)
+			nodes = nodes[1:]
+		}
+	}
+	c.Template.Nodes = nodes
+	return c
+}
+
+// parseNodes parses a list of nodes up to EOF, the end tag of an open element,
+// or, if inBlock is set, the `{else}` or `{/...}` code block of the enclosing block.
+// In the latter case the `{` has been consumed and p.tok is ELSE or SLASH.
+func (p *Parser) parseNodes(inBlock bool) (list []ast.Node) {
+	for p.endTag == nil {
+		// whitespace between nodes is kept as text
+		if space := p.space(); space != "" && p.tok != token.TEXT {
+			list = append(list, &ast.Text{Start: p.gap, Value: space})
+		}
+		switch p.tok {
+		case token.EOF:
+			return
+		case token.TEXT:
+			if space := p.space(); space != "" {
+				list = append(list, &ast.Text{Start: p.gap, Value: space + p.lit})
+			} else if p.lit != "" {
+				list = append(list, &ast.Text{Start: p.loc, Value: p.lit})
+			}
+			p.next()
+		case token.COMMENT:
+			list = append(list, &ast.Comment{Start: p.loc, Value: p.lit})
+			p.next()
+		case token.STARTTagOpen:
+			list = append(list, p.parseElement())
+		case token.ENDTagOpen:
+			p.parseEndTag()
+		case token.LBRACE:
+			lbrace := p.loc
+			p.next()
+			switch p.tok {
+			case token.IF:
+				list = append(list, p.parseIfBlock(lbrace))
+			case token.FOR:
+				list = append(list, p.parseForBlock(lbrace))
+			case token.ELSE, token.SLASH:
+				if inBlock {
+					p.lbrace = lbrace
+					return
+				}
+				p.error(lbrace, "unexpected "+p.codeBlockName()+" outside of a block")
+				p.skipCodeBlock()
+			default:
+				list = append(list, p.parseCodeBlock(lbrace))
+			}
+		case token.RBRACE:
+			p.error(p.loc, "code block closing character '}' is missing opening character '{'")
+			p.next()
+		default:
+			// DOCTYPE, ILLEGAL, etc. have been reported by the scanner.
+			p.next()
+		}
+	}
+	return
+}
+
+// codeBlockName describes the `{else}` or `{/...}` code block at the current token.
+func (p *Parser) codeBlockName() string {
+	if p.tok == token.ELSE {
+		return "{else}"
+	}
+	return "{/...}"
+}
+
+// ----------------------------------------------------------------------------
+// Elements
+
+func (p *Parser) parseElement() *ast.Element {
+	e := &ast.Element{Start: p.loc}
+	p.next() // consume `<`
+	if p.tok != token.TAGName {
+		p.errorExpected(p.loc, "tag name")
+		e.End = p.loc
+		return e
+	}
+	e.Name = p.lit
+	p.next()
+
+	for p.tok == token.ATTRName {
+		e.Attrs = append(e.Attrs, p.parseAttribute())
+	}
+	p.checkSlotAttr(e)
+
+	switch p.tok {
+	case token.TAGSelfClose:
+		e.SelfClosing = true
+		e.End = p.end()
+		p.next()
+		return e
+	case token.TAGClose:
+		e.End = p.end()
+		p.next()
+	default:
+		p.errorExpected(p.loc, "'>'")
+		e.End = p.loc
+		return e
+	}
+	if scanner.IsVoidTag(e.Name) {
+		return e
+	}
+
+	p.elems = append(p.elems, e.Name)
+	e.Children = p.parseNodes(false)
+	p.elems = p.elems[:len(p.elems)-1]
+
+	switch tag := p.endTag; {
+	case tag == nil:
+		// EOF
+		p.error(e.Start, "element <"+e.Name+"> is not closed")
+		e.End = p.loc
+	case tag.name == e.Name:
+		p.endTag = nil
+		e.End = tag.end
+	default:
+		// the end tag belongs to an ancestor.
+		p.error(e.Start, "element <"+e.Name+"> is not closed")
+		e.End = tag.start
+	}
+	return e
+}
+
+// checkSlotAttr reports the slot name of e, given by the name attribute of a
+// <slot> or the slot attribute of any other element, unless it is a static
+// identifier, aka `footer`.
+func (p *Parser) checkSlotAttr(e *ast.Element) {
+	attr := "slot"
+	if e.Name == "slot" {
+		attr = "name"
+	}
+	a := e.Attr(attr)
+	if a == nil {
+		return
+	}
+	if a.Kind != ast.AttrStatic || !isSlotName(e.SlotName()) {
+		p.error(a.NameLoc, "the "+attr+" attribute of <"+e.Name+"> must be a slot name, aka "+attr+"=\"footer\"")
+	}
+}
+
+// isSlotName reports whether name is a valid slot name: letters, digits,
+// `-` and `_`, starting with a letter.
+func isSlotName(name string) bool {
+	for i, r := range name {
+		if !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r) && r != '-' && r != '_') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// parseEndTag scans an end tag and makes it pending if it closes an open element;
+// otherwise the end tag is reported and dropped.
+func (p *Parser) parseEndTag() {
+	tag := &endTag{start: p.loc}
+	p.next() // consume `</`
+	if p.tok == token.TAGName {
+		tag.name = p.lit
+		p.next()
+	}
+	tag.end = p.loc
+	if p.tok == token.TAGClose {
+		tag.end = p.end()
+		p.next()
+	} else {
+		p.errorExpected(p.loc, "'>'")
+	}
+	if !slices.Contains(p.elems, tag.name) {
+		p.error(tag.start, "unexpected end tag </"+tag.name+">")
+		return
+	}
+	p.endTag = tag
+}
+
+func (p *Parser) parseAttribute() *ast.Attribute {
+	a := &ast.Attribute{NameLoc: p.loc, Name: p.lit}
+	a.End = p.end()
+	p.next()
+	if p.tok != token.ATTRValSep {
+		return a
+	}
+	a.End = p.end()
+	p.next()
+
+	switch p.tok {
+	case token.ATTRValText:
+		// unquoted value, aka `id=main`
+		a.Value = []ast.Node{&ast.Text{Start: p.loc, Value: p.lit}}
+		a.End = p.end()
+		p.next()
+	case token.LBRACE:
+		// attribute expression, aka `disabled={!user.active}`
+		a.Kind = ast.AttrExpr
+		lbrace := p.loc
+		p.next()
+		x := p.parseInterpolation(lbrace)
+		a.Value = []ast.Node{x}
+		a.End = x.Range().End
+	case token.ATTRValDelim:
+		// quoted value, aka `class="dark {theme}"`
+		a.Quote = p.lit[0]
+		a.Value = []ast.Node{}
+		a.End = p.end()
+		p.next()
+		for {
+			switch p.tok {
+			case token.ATTRValText:
+				a.Value = append(a.Value, &ast.Text{Start: p.loc, Value: p.lit})
+				a.End = p.end()
+				p.next()
+				continue
+			case token.LBRACE:
+				a.Kind = ast.AttrInterp
+				lbrace := p.loc
+				p.next()
+				x := p.parseCodeBlock(lbrace)
+				a.Value = append(a.Value, x)
+				a.End = x.Range().End
+				continue
+			case token.ATTRValDelim:
+				a.End = p.end()
+				p.next()
+			case token.ILLEGAL:
+				// unterminated value, already reported by the scanner.
+				p.next()
+			default:
+				p.errorExpected(p.loc, "closing quote of attribute value")
+			}
+			break
+		}
+	}
+	return a
+}
+
+// ----------------------------------------------------------------------------
+// Code blocks
+
+// expectRBrace consumes the closing `}` of a code block;
+// tokens left over by a malformed expression are reported and skipped.
+func (p *Parser) expectRBrace() (loc token.Loc) {
+	if p.tok != token.RBRACE && isExprToken(p.tok) {
+		p.errorExpected(p.loc, "'}'")
+		for isExprToken(p.tok) {
+			p.next()
+		}
+		loc = p.loc
+		if p.tok == token.RBRACE {
+			p.next()
+		}
+		return
+	}
+	return p.expect(token.RBRACE)
+}
+
+// parseInterpolation parses `{x}`; the `{` at lbrace has been consumed.
+func (p *Parser) parseInterpolation(lbrace token.Loc) *ast.Interpolation {
+	x := p.parseExpr(valueExpr)
+	rbrace := p.expectRBrace()
+	return &ast.Interpolation{LBrace: lbrace, X: x, RBrace: rbrace}
+}
+
+// parseCodeBlock parses `{x}`, `{x: text}` or `{x %.2f}`; the `{` at lbrace has been consumed.
+func (p *Parser) parseCodeBlock(lbrace token.Loc) ast.Node {
+	x := p.parseExpr(valueExpr)
+	switch p.tok {
+	case token.CONDText:
+		c := &ast.ConditionalText{LBrace: lbrace, X: x, Colon: p.loc}
+		c.Text = strings.TrimLeft(p.lit[1:], " \t\r\n")
+		p.next()
+		c.RBrace = p.expectRBrace()
+		return c
+	case token.FMT:
+		f := &ast.FormatSpec{LBrace: lbrace, X: x, Percent: p.loc}
+		f.Format = strings.TrimLeft(p.lit[1:], " \t\r\n")
+		p.next()
+		f.RBrace = p.expectRBrace()
+		return f
+	}
+	rbrace := p.expectRBrace()
+	return &ast.Interpolation{LBrace: lbrace, X: x, RBrace: rbrace}
+}
+
+// parseBlockEnd parses the rest of the `{/if}` or `{/for}` closing the block
+// opened with keyword; p.tok is SLASH. It returns the position after `}`.
+func (p *Parser) parseBlockEnd(keyword token.Token) token.Loc {
+	p.next() // consume `/`
+	if p.tok != keyword {
+		p.errorExpected(p.loc, "'"+keyword.String()+"'")
+		p.skipCodeBlock()
+		return p.loc
+	}
+	p.next()
+	end := p.end()
+	p.expectRBrace()
+	return end
+}
+
+// parseIfBlock parses `{if cond}...{else}...{/if}`; the `{` at lbrace has been consumed.
+func (p *Parser) parseIfBlock(lbrace token.Loc) *ast.IfBlock {
+	b := &ast.IfBlock{Start: lbrace}
+	p.next() // consume `if`
+	b.Cond = p.parseExpr(ifExpr)
+	p.expectRBrace()
+
+	b.Then = p.parseNodes(true)
+	if p.tok == token.ELSE {
+		b.ElseLoc = p.lbrace
+		p.next()
+		p.expectRBrace()
+		b.Else = p.parseNodes(true)
+		for p.tok == token.ELSE {
+			p.error(p.lbrace, "multiple {else} in {if} block")
+			p.skipCodeBlock()
+			b.Else = append(b.Else, p.parseNodes(true)...)
+		}
+	}
+	if p.tok != token.SLASH {
+		p.error(b.Start, "{if} block is not closed")
+		b.End = p.loc
+		return b
+	}
+	b.End = p.parseBlockEnd(token.IF)
+	return b
+}
+
+// parseForBlock parses `{for key, value in x}...{/for}`; the `{` at lbrace has been consumed.
+func (p *Parser) parseForBlock(lbrace token.Loc) *ast.ForBlock {
+	b := &ast.ForBlock{Start: lbrace}
+	p.next() // consume `for`
+	b.Key = p.parseIdent()
+	if p.tok == token.COMMA {
+		p.next()
+		b.Value = p.parseIdent()
+	}
+	p.expect(token.IN)
+	b.X = p.parseExpr(forExpr)
+	p.expectRBrace()
+
+	b.Body = p.parseNodes(true)
+	for p.tok == token.ELSE {
+		p.error(p.lbrace, "unexpected {else} in {for} block")
+		p.skipCodeBlock()
+		b.Body = append(b.Body, p.parseNodes(true)...)
+	}
+	if p.tok != token.SLASH {
+		p.error(b.Start, "{for} block is not closed")
+		b.End = p.loc
+		return b
+	}
+	b.End = p.parseBlockEnd(token.FOR)
+	return b
+}
+
+func (p *Parser) parseIdent() *ast.Ident {
+	x := &ast.Ident{NameLoc: p.loc}
+	if p.tok == token.IDENT {
+		x.Name = p.lit
+		p.next()
+	} else {
+		p.errorExpected(p.loc, "identifier")
+	}
+	return x
+}
@@ -1,8 +1,249 @@
 package parser
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/supaleon/vanilla/internal/ast"
+	"github.com/supaleon/vanilla/internal/scanner"
+	"github.com/supaleon/vanilla/internal/token"
+)
 
 func TestName(t *testing.T) {
 	var data []byte
 	println(data == nil)
 }
+
+const component = `<script>
+    import {User} from "./user.go"
+    let user = prop(User())
+</script>
+
+<div class="{user.theme}" id=main disabled={!user.active} hidden>
+    <!--template-->
+    {if !user.disabled && user.likes > 0}
+        <span>{user.name}</span><br/>
+    {else}
+        <button>Sign In</button>
+    {/if}
+    {for index, value in user.tags}
+        <span data-index={index}>{value}</span>
+    {/for}
+</div>`
+
+func TestParseFile(t *testing.T) {
+	c, err := ParseFile(token.NewFileSet(), "Hello.html", []byte(component))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ESModule == nil || c.ESModule.Script.Name != "script" {
+		t.Fatal("missing script code block")
+	}
+	if n := len(c.Template.Nodes); n != 1 {
+		t.Fatalf("got %d top-level template nodes; want 1", n)
+	}
+	div, ok := c.Template.Nodes[0].(*ast.Element)
+	if !ok || div.Name != "div" {
+		t.Fatalf("got %T; want <div>", c.Template.Nodes[0])
+	}
+
+	attrs := []struct {
+		name  string
+		kind  ast.AttrKind
+		parts int
+	}{
+		{"class", ast.AttrInterp, 1},
+		{"id", ast.AttrStatic, 1},
+		{"disabled", ast.AttrExpr, 1},
+		{"hidden", ast.AttrStatic, 0},
+	}
+	if len(div.Attrs) != len(attrs) {
+		t.Fatalf("got %d attributes; want %d", len(div.Attrs), len(attrs))
+	}
+	for i, want := range attrs {
+		if a := div.Attrs[i]; a.Name != want.name || a.Kind != want.kind || len(a.Value) != want.parts {
+			t.Errorf("attribute %d: got %s (kind %d) with %d parts; want %s (kind %d) with %d parts",
+				i, a.Name, a.Kind, len(a.Value), want.name, want.kind, want.parts)
+		}
+	}
+
+	if t0, ok := div.Children[0].(*ast.Text); !ok || t0.Value != "\n    " {
+		t.Errorf("got %#v; want leading whitespace text", div.Children[0])
+	}
+	children := trimSpace(div.Children)
+	if n := len(children); n != 3 {
+		t.Fatalf("got %d children; want 3", n)
+	}
+	if _, ok := children[0].(*ast.Comment); !ok {
+		t.Errorf("got %T; want *ast.Comment", children[0])
+	}
+	ifBlock, ok := children[1].(*ast.IfBlock)
+	if !ok {
+		t.Fatalf("got %T; want *ast.IfBlock", children[1])
+	}
+	if got := ast.ExprString(ifBlock.Cond); got != "!user.disabled && user.likes > 0" {
+		t.Errorf("got condition %q", got)
+	}
+	if then, els := trimSpace(ifBlock.Then), trimSpace(ifBlock.Else); len(then) != 2 || len(els) != 1 {
+		t.Errorf("got %d/%d nodes in branches; want 2/1", len(then), len(els))
+	}
+	forBlock, ok := children[2].(*ast.ForBlock)
+	if !ok {
+		t.Fatalf("got %T; want *ast.ForBlock", children[2])
+	}
+	if forBlock.Key.Name != "index" || forBlock.Value.Name != "value" || ast.ExprString(forBlock.X) != "user.tags" {
+		t.Errorf("got {for %s, %s in %s}", forBlock.Key.Name, forBlock.Value.Name, ast.ExprString(forBlock.X))
+	}
+	start := token.Loc(1 + strings.Index(component, "<div"))
+	if r := div.Range(); r.Start != start || r.End != token.Loc(1+len(component)) {
+		t.Errorf("got range %v for <div>; want {%d %d}", r, start, 1+len(component))
+	}
+	if r := c.Range(); r.Start != 1 || r.End != token.Loc(1+len(component)) {
+		t.Errorf("got range %v for component; want {1 %d}", r, 1+len(component))
+	}
+}
+
+// trimSpace returns the nodes that are not whitespace-only text.
+func trimSpace(nodes []ast.Node) []ast.Node {
+	var list []ast.Node
+	for _, n := range nodes {
+		if t, ok := n.(*ast.Text); !ok || strings.TrimSpace(t.Value) != "" {
+			list = append(list, n)
+		}
+	}
+	return list
+}
+
+func TestCodeBlocks(t *testing.T) {
+	tests := []struct {
+		src  string
+		node ast.Node
+		x    string
+		text string
+	}{
+		{`{user.name}`, &ast.Interpolation{}, "user.name", ""},
+		{`{user.pro: Pro member}`, &ast.ConditionalText{}, "user.pro", "Pro member"},
+		{`{!user.pro:free}`, &ast.ConditionalText{}, "!user.pro", "free"},
+		{`{user.score %.2f}`, &ast.FormatSpec{}, "user.score", ".2f"},
+		{`{user.createTime % YY-MM-DD}`, &ast.FormatSpec{}, "user.createTime", "YY-MM-DD"},
+	}
+	for _, test := range tests {
+		for _, src := range []string{"<div>" + test.src + "</div>", `<div class="` + test.src + `"></div>`} {
+			c, err := ParseFile(token.NewFileSet(), "", []byte(src))
+			if err != nil {
+				t.Errorf("%q: unexpected error %v", src, err)
+				continue
+			}
+			div := c.Template.Nodes[0].(*ast.Element)
+			nodes := div.Children
+			if len(div.Attrs) > 0 {
+				nodes = div.Attrs[0].Value
+			}
+			if len(nodes) != 1 {
+				t.Errorf("%q: got %d nodes; want 1", src, len(nodes))
+				continue
+			}
+			var x ast.Expr
+			var text string
+			switch n := nodes[0].(type) {
+			case *ast.Interpolation:
+				x = n.X
+			case *ast.ConditionalText:
+				x, text = n.X, n.Text
+			case *ast.FormatSpec:
+				x, text = n.X, n.Format
+			}
+			if fmt.Sprintf("%T", nodes[0]) != fmt.Sprintf("%T", test.node) || ast.ExprString(x) != test.x || text != test.text {
+				t.Errorf("%q: got %T(%q, %q); want %T(%q, %q)", src, nodes[0], ast.ExprString(x), text, test.node, test.x, test.text)
+			}
+			if r := nodes[0].Range(); r.End-r.Start != token.Loc(len(test.src)) {
+				t.Errorf("%q: got range %v; want %d bytes", src, r, len(test.src))
+			}
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{`<div>`, "1:1: element <div> is not closed"},
+		{`<div></span></div>`, "1:6: unexpected end tag </span>"},
+		{`<div><span></div>`, "1:6: element <span> is not closed"},
+		{`<div>{if ok}</div>`, "1:6: {if} block is not closed"},
+		{`<div>{for i in 1..9}{/if}</div>`, "1:23: expected 'for', found if"},
+		{`<div>{for in 1..9}{/for}</div>`, "1:11: expected identifier, found in"},
+		{`<div>{if ok}{else}{else}{/if}</div>`, "1:19: multiple {else} in {if} block"},
+		{`<div>{/if}</div>`, "1:6: unexpected {/...} outside of a block"},
+		{`<div>{}</div>`, "1:7: expected operand, found '}'"},
+		{`<div></div>}`, "1:12: code block closing character '}' is missing opening character '{'"},
+		{`<div><slot name={x}/></div>`, `1:12: the name attribute of <slot> must be a slot name, aka name="footer"`},
+		{`<div><Card><p slot="a b"></p></Card></div>`, `1:15: the slot attribute of <p> must be a slot name, aka slot="footer"`},
+	}
+	for _, test := range tests {
+		_, err := ParseFile(token.NewFileSet(), "", []byte(test.src))
+		var list scanner.ErrorList
+		if !errors.As(err, &list) {
+			t.Errorf("%q: got %v; want error %q", test.src, err, test.err)
+			continue
+		}
+		if got := list[0].Error(); got != test.err {
+			t.Errorf("%q: got error %q; want %q", test.src, got, test.err)
+		}
+	}
+}
+
+func TestExprs(t *testing.T) {
+	tests := []struct {
+		src, want, err string
+	}{
+		{`{user.name}`, "user.name", ""},
+		{`{user.tags[0].name}`, "user.tags[0].name", ""},
+		{`{user.tags[i]}`, "user.tags[i]", ""},
+		{`{!user.active}`, "!user.active", ""},
+		{`{-1}`, "-1", ""},
+		{`{len(user.tags)}`, "len(user.tags)", ""},
+		{`{if a || b && c}{/if}`, "a || b && c", ""},
+		{`{if (a || b) && !c}{/if}`, "(a || b) && !c", ""},
+		{`{if len(user.tags) > 0 && user.code == "NICE"}{/if}`, "len(user.tags) > 0 && user.code == \"NICE\"", ""},
+		{`{for i, v in 1..9}{/for}`, "1..9", ""},
+		{`{for i, v in user.tags}{/for}`, "user.tags", ""},
+		{`{user["name"]}`, `user["name"]`, "1:12: bracket notation access is not supported, use a selector instead"},
+		{`{escape(len(user.tags))}`, "escape(len(user.tags))", "1:14: nested function calls are not supported"},
+		{`{user.name(1)}`, "(1)", "1:7: only built-in functions can be called"},
+		{`{a == b}`, "a == b", "1:9: logical and comparison operators are only allowed in {if} block"},
+		{`{for i in a && b}{/for}`, "a && b", "1:18: logical and comparison operators are only allowed in {if} block"},
+		{`{1..9}`, "1..9", "1:8: range expression is only allowed in {for} block"},
+		{`{if a b}{/if}`, "a", "1:12: expected '}', found b"},
+	}
+	for _, test := range tests {
+		src := "<div>" + test.src + "</div>"
+		c, err := ParseFile(token.NewFileSet(), "", []byte(src))
+		if test.err == "" && err != nil {
+			t.Errorf("%q: unexpected error %v", test.src, err)
+			continue
+		}
+		if test.err != "" {
+			var list scanner.ErrorList
+			if !errors.As(err, &list) {
+				t.Errorf("%q: got no error; want %q", test.src, test.err)
+			} else if got := list[0].Error(); got != test.err {
+				t.Errorf("%q: got error %q; want %q", test.src, got, test.err)
+			}
+		}
+		var x ast.Expr
+		switch n := c.Template.Nodes[0].(*ast.Element).Children[0].(type) {
+		case *ast.Interpolation:
+			x = n.X
+		case *ast.IfBlock:
+			x = n.Cond
+		case *ast.ForBlock:
+			x = n.X
+		}
+		if got := ast.ExprString(x); got != test.want {
+			t.Errorf("%q: got %s; want %s", test.src, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,583 @@
+// Package router derives the routes of a Vanilla project from its pages/ tree
+// and checks them at build time, so that the generated router never fails at
+// startup.
+//
+// Each Index.html file is a page routed at its directory, aka `/blog` for
+// `pages/blog/Index.html`. A directory named `[name]` is a dynamic segment
+// matching any path segment, aka `/blog/:slug` for
+// `pages/blog/[slug]/Index.html`, and a directory named `[...name]` is a
+// catch-all segment matching the rest of the path; it must be the last one.
+// A dynamic segment may declare the type of its parameter, aka `[id=int]`:
+//
+//	int   a decimal integer, passed to the loader as an int
+//	uuid  a UUID in its canonical textual form
+//	slug  lowercase letters and digits separated by single dashes
+//
+// Requests whose parameters do not match their type are not found.
+//
+// The props of a page are produced by a loader declared in the route.go file
+// of its directory:
+//
+//	func Load(r *http.Request) (T, error)
+//
+// where T is a struct, or a pointer to one, whose exported fields are assigned
+// to the props of the same name. A loader may also take the parameters of the
+// route as arguments following the request, converted to their types, aka
+// `func LoadId(r *http.Request, id int) (T, error)`.
+//
+// Each page gets a generated function building its URL from typed arguments,
+// which templates call with the built-in `url` and the Go name of the page,
+// aka `<a href={url(BlogIdIndex,post.id)}>`.
+//
+// A route.go file may also declare the middleware of its directory, applied
+// to every page of the directory and below:
+//
+//	func Middleware(next http.Handler) http.Handler
+//
+// The middleware of the directories of a page are composed in directory
+// order, the one of pages/ being the outermost.
+//
+// For static generation, the route.go file lists the parameters of each page
+// of a dynamic route to render:
+//
+//	func StaticSlug() ([]map[string]string, error)
+//
+// where each map holds the values of the parameters by name.
+//
+// Since Go cannot import the directories of dynamic segments, the functions
+// of a directory below one are declared in the route.go file of the nearest
+// static directory instead, and named after the remaining segments, aka
+// `LoadSlug` and `MiddlewareSlug` in `pages/blog/route.go` for
+// `pages/blog/[slug]/Index.html`.
+package router
+
+import (
+	gotoken "go/token"
+	"go/types"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/supaleon/vanilla/internal/checker"
+	"github.com/supaleon/vanilla/internal/token"
+)
+
+// Route is the route of a page.
+type Route struct {
+	Page       string        // path of the page relative to the project root, aka `pages/blog/[slug]/Index.html`
+	Pattern    string        // httprouter pattern, aka `/blog/:slug`
+	Params     []*Param      // parameters in path order
+	Loader     *Loader       // nil if the page has none
+	Static     *types.Func   // lists the parameters for static generation; nil if none
+	Middleware []*types.Func // middleware of the page, outermost first
+}
+
+// Param is a parameter of a route.
+type Param struct {
+	Name     string
+	Type     string // Int, UUID, Slug or "" for any segment
+	CatchAll bool
+}
+
+// Parameter types.
+const (
+	Int  = "int"
+	UUID = "uuid"
+	Slug = "slug"
+)
+
+// GoType returns the type of the Go value of the parameter, int or string.
+func (p *Param) GoType() types.Type {
+	if p.Type == Int {
+		return types.Typ[types.Int]
+	}
+	return types.Typ[types.String]
+}
+
+// Loader is the loader of the props of a page.
+type Loader struct {
+	Func   *types.Func
+	Fields []*Field // fields of the result assigned to props
+	Ptr    bool     // whether the result is a pointer to a struct
+	Params bool     // whether the parameters of the route are passed
+}
+
+// Field is a field of the result of a loader assigned to a prop.
+type Field struct {
+	Name string // field name
+	Prop string // prop name
+}
+
+// Table holds the routes of a project.
+type Table struct {
+	Routes []*Route // sorted by page path
+	byPage map[string]*Route
+}
+
+// Lookup returns the route of the page at the given path, or nil if the file
+// is not a page. t may be nil.
+func (t *Table) Lookup(page string) *Route {
+	if t == nil {
+		return nil
+	}
+	return t.byPage[page]
+}
+
+const routeFile = "route.go"
+
+// Build returns the route table of the pages among the given component paths
+// of the project at root. infos holds the result of [checker.Check] by path,
+// and loader loads the route.go files. The loader fields of a page missing
+// from infos are left unchecked and out of its [Loader], aka for a page whose
+// generated code is reused from a previous build. The returned error list is
+// sorted by position; pages whose route is invalid are left out of the table.
+func Build(root string, paths []string, infos map[string]*checker.Info, loader *checker.GoLoader) (*Table, checker.ErrorList) {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	b := &builder{
+		root:    root,
+		infos:   infos,
+		loader:  loader,
+		pkgs:    make(map[string]*types.Package),
+		dirs:    make(map[string]bool),
+		invalid: make(map[types.Object]bool),
+		t:       &Table{byPage: make(map[string]*Route)},
+	}
+	for _, p := range slices.Sorted(slices.Values(paths)) {
+		if !IsPage(p) {
+			continue
+		}
+		pattern, params, msg := parsePattern(p)
+		if msg != "" {
+			b.errors.Add(token.Position{Filename: p}, checker.InvalidRouteSegment, msg)
+			continue
+		}
+		r := &Route{Page: p, Pattern: pattern, Params: params}
+		for _, other := range b.t.Routes {
+			if conflicts(other.Pattern, r.Pattern) {
+				b.errors.Add(token.Position{Filename: p}, checker.RouteConflict, "route "+r.Pattern+" of "+p+
+					" conflicts with route "+other.Pattern+" of "+other.Page)
+				r = nil
+				break
+			}
+		}
+		if r == nil {
+			continue
+		}
+		r.Loader = b.loaderOf(r)
+		r.Static = b.staticOf(r)
+		r.Middleware = b.middlewareOf(p)
+		b.t.Routes = append(b.t.Routes, r)
+		b.t.byPage[p] = r
+	}
+	b.errors.Sort()
+	return b.t, b.errors
+}
+
+type builder struct {
+	root    string
+	infos   map[string]*checker.Info
+	loader  *checker.GoLoader
+	pkgs    map[string]*types.Package // packages of route.go files by directory; nil if failed
+	dirs    map[string]bool           // checked dynamic directories
+	invalid map[types.Object]bool     // reported middleware
+	t       *Table
+	errors  checker.ErrorList
+}
+
+// IsPage reports whether the component at the given path is a page.
+func IsPage(p string) bool {
+	return strings.HasPrefix(p, "pages/") && path.Base(p) == "Index.html"
+}
+
+// Pattern returns the httprouter pattern of the page at the given path, aka
+// `/blog/:slug` for `pages/blog/[slug]/Index.html`, or "" if the file is not
+// a page or its path is not a valid route.
+func Pattern(p string) string {
+	if !IsPage(p) {
+		return ""
+	}
+	pattern, _, msg := parsePattern(p)
+	if msg != "" {
+		return ""
+	}
+	return pattern
+}
+
+// parsePattern returns the pattern and the parameters of the page at p, or
+// an error message.
+func parsePattern(p string) (pattern string, params []*Param, msg string) {
+	dirs := segments(p)
+	var b strings.Builder
+	for i, dir := range dirs {
+		b.WriteByte('/')
+		name, typ, catchAll, dynamic := parseSegment(dir)
+		switch {
+		case !dynamic:
+			if strings.ContainsAny(dir, "[]:*=") {
+				return "", nil, "invalid route segment " + dir + ", a dynamic segment is named `[name]`"
+			}
+			b.WriteString(dir)
+			continue
+		case !isIdent(name):
+			return "", nil, "invalid route segment " + dir + ", " + name + " is not a valid parameter name"
+		case catchAll && typ != "":
+			return "", nil, "catch-all segment " + dir + " cannot declare a type"
+		case typ != "" && typ != Int && typ != UUID && typ != Slug:
+			return "", nil, "unknown type " + typ + " of route segment " + dir + ", expected int, uuid or slug"
+		case catchAll && i != len(dirs)-1:
+			return "", nil, "catch-all segment " + dir + " must be the last segment of a route"
+		case catchAll:
+			b.WriteString("*" + name)
+		default:
+			b.WriteString(":" + name)
+		}
+		params = append(params, &Param{Name: name, Type: typ, CatchAll: catchAll})
+	}
+	if b.Len() == 0 {
+		return "/", nil, ""
+	}
+	return b.String(), params, ""
+}
+
+// segments returns the directories of p below pages/.
+func segments(p string) []string {
+	dir := path.Dir(strings.TrimPrefix(p, "pages/"))
+	if dir == "." {
+		return nil
+	}
+	return strings.Split(dir, "/")
+}
+
+// parseSegment returns the parameter name and type of the dynamic segment
+// dir, aka `slug` for `[slug]`, `id` and `int` for `[id=int]`, and `path` for
+// `[...path]`.
+func parseSegment(dir string) (name, typ string, catchAll, dynamic bool) {
+	if len(dir) < 2 || dir[0] != '[' || dir[len(dir)-1] != ']' {
+		return "", "", false, false
+	}
+	name = dir[1 : len(dir)-1]
+	name, catchAll = strings.CutPrefix(name, "...")
+	name, typ, _ = strings.Cut(name, "=")
+	return name, typ, catchAll, true
+}
+
+func isIdent(s string) bool {
+	for i, r := range s {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// conflicts reports whether the httprouter patterns a and b cannot be
+// registered together.
+func conflicts(a, b string) (conflict bool) {
+	defer func() {
+		if recover() != nil {
+			conflict = true
+		}
+	}()
+	r := httprouter.New()
+	h := func(http.ResponseWriter, *http.Request, httprouter.Params) {}
+	r.Handle(http.MethodGet, a, h)
+	r.Handle(http.MethodGet, b, h)
+	return false
+}
+
+// ----------------------------------------------------------------------------
+// Loaders
+
+// lookup returns the object of the given name declared for dir in the
+// route.go file of its nearest static directory, or nil.
+func (b *builder) lookup(dir, name string) types.Object {
+	if hasDynamic(dir) && !b.dirs[dir] {
+		b.dirs[dir] = true
+		if file := path.Join(dir, routeFile); b.exists(file) {
+			b.errors.Add(token.Position{Filename: file}, checker.UnimportableRoute, "cannot import "+file+
+				" from a dynamic segment, declare "+name+" in "+path.Join(staticDir(dir), routeFile))
+		}
+	}
+	pkg := b.pkg(staticDir(dir))
+	if pkg == nil {
+		return nil
+	}
+	return pkg.Scope().Lookup(name)
+}
+
+// loaderOf returns the checked loader of the page of r, or nil.
+func (b *builder) loaderOf(r *Route) *Loader {
+	p := r.Page
+	obj := b.lookup(path.Dir(p), LoaderName(p))
+	if obj == nil {
+		return nil
+	}
+	return b.checkLoader(r, obj)
+}
+
+// staticOf returns the checked function listing the parameters of the dynamic
+// route r for static generation, or nil.
+func (b *builder) staticOf(r *Route) *types.Func {
+	if len(r.Params) == 0 {
+		return nil
+	}
+	obj := b.lookup(path.Dir(r.Page), StaticName(r.Page))
+	if obj == nil {
+		return nil
+	}
+	fn, ok := obj.(*types.Func)
+	if !ok || !isStatic(fn.Type()) {
+		b.errors.Add(b.position(obj.Pos()), checker.InvalidStaticParams, "static parameters "+obj.Name()+" of "+r.Page+
+			" must have signature func() ([]map[string]string, error)")
+		return nil
+	}
+	return fn
+}
+
+// middlewareOf returns the checked middleware of the page at p, outermost
+// first.
+func (b *builder) middlewareOf(p string) []*types.Func {
+	var list []*types.Func
+	dirs := segments(p)
+	for i := range len(dirs) + 1 {
+		dir := path.Join(append([]string{"pages"}, dirs[:i]...)...)
+		obj := b.lookup(dir, MiddlewareName(dir))
+		if obj == nil {
+			continue
+		}
+		if fn, ok := obj.(*types.Func); ok && isMiddleware(fn.Type()) {
+			list = append(list, fn)
+		} else if !b.invalid[obj] {
+			b.invalid[obj] = true
+			b.errors.Add(b.position(obj.Pos()), checker.InvalidMiddleware, "middleware "+obj.Name()+" of "+dir+
+				" must have signature func(http.Handler) http.Handler")
+		}
+	}
+	return list
+}
+
+// LoaderName returns the name of the loader of the page at the given path,
+// aka `LoadSlug` for `pages/blog/[slug]/Index.html`.
+func LoaderName(p string) string {
+	return "Load" + suffix(path.Dir(p))
+}
+
+// StaticName returns the name of the function listing the parameters of the
+// page at the given path for static generation, aka `StaticSlug` for
+// `pages/blog/[slug]/Index.html`.
+func StaticName(p string) string {
+	return "Static" + suffix(path.Dir(p))
+}
+
+// MiddlewareName returns the name of the middleware of the directory dir
+// relative to the project root, aka `MiddlewareSlug` for `pages/blog/[slug]`.
+func MiddlewareName(dir string) string {
+	return "Middleware" + suffix(dir)
+}
+
+// suffix returns the suffix of the names of the functions of dir, aka `Slug`
+// for `pages/blog/[slug]` and "" for `pages/blog`.
+func suffix(dir string) string {
+	rel := strings.TrimPrefix(strings.TrimPrefix(dir, staticDir(dir)), "/")
+	name := ""
+	if rel == "" {
+		return name
+	}
+	for _, seg := range strings.Split(rel, "/") {
+		if n, _, _, dynamic := parseSegment(seg); dynamic {
+			seg = n
+		}
+		upper := true
+		for _, r := range seg {
+			switch {
+			case unicode.IsLetter(r) || unicode.IsDigit(r):
+				if upper {
+					r = unicode.ToUpper(r)
+				}
+				name += string(r)
+				upper = false
+			default:
+				upper = true
+			}
+		}
+	}
+	return name
+}
+
+// staticDir returns the nearest directory of dir, itself included, with no
+// dynamic segment.
+func staticDir(dir string) string {
+	for hasDynamic(dir) {
+		dir = path.Dir(dir)
+	}
+	return dir
+}
+
+func hasDynamic(dir string) bool {
+	return strings.Contains(dir, "[")
+}
+
+func (b *builder) exists(file string) bool {
+	_, err := os.Stat(filepath.Join(b.root, filepath.FromSlash(file)))
+	return err == nil
+}
+
+// pkg returns the Go package of the route.go file of dir, or nil if there is
+// none or it cannot be loaded.
+func (b *builder) pkg(dir string) *types.Package {
+	if pkg, ok := b.pkgs[dir]; ok {
+		return pkg
+	}
+	var pkg *types.Package
+	if file := path.Join(dir, routeFile); b.exists(file) {
+		var err error
+		pkg, err = b.loader.Load(filepath.Join(b.root, filepath.FromSlash(dir)))
+		if err != nil {
+			b.errors.Add(token.Position{Filename: file}, checker.GoImportFailed, "cannot load "+file+": "+err.Error())
+			pkg = nil
+		}
+	}
+	b.pkgs[dir] = pkg
+	return pkg
+}
+
+// checkLoader checks the loader obj of the page at p of route r.
+func (b *builder) checkLoader(r *Route, obj types.Object) *Loader {
+	p := r.Page
+	pos := b.position(obj.Pos())
+	fn, _ := obj.(*types.Func)
+	var sig *types.Signature
+	if fn != nil {
+		sig = fn.Type().(*types.Signature)
+	}
+	if sig == nil || sig.Params().Len() == 0 || !isRequest(sig.Params().At(0).Type()) ||
+		sig.Results().Len() != 2 || !types.Identical(sig.Results().At(1).Type(), types.Universe.Lookup("error").Type()) {
+		b.errors.Add(pos, checker.InvalidLoader, "loader "+obj.Name()+" of "+p+" must have signature "+signatures(r.Params))
+		return nil
+	}
+	l := &Loader{Func: fn, Params: sig.Params().Len() > 1}
+	if l.Params {
+		valid := sig.Params().Len() == len(r.Params)+1 && !sig.Variadic()
+		for i := 0; valid && i < len(r.Params); i++ {
+			valid = types.Identical(sig.Params().At(i+1).Type(), r.Params[i].GoType())
+		}
+		if !valid {
+			b.errors.Add(pos, checker.InvalidLoader, "loader "+obj.Name()+" of "+p+" must have signature func(*http.Request"+
+				paramList(r.Params)+") (T, error) to take the parameters of "+r.Pattern)
+			return nil
+		}
+	}
+	res := sig.Results().At(0).Type()
+	if ptr, ok := res.Underlying().(*types.Pointer); ok {
+		res, l.Ptr = ptr.Elem(), true
+	}
+	st, ok := res.Underlying().(*types.Struct)
+	if !ok {
+		b.errors.Add(pos, checker.InvalidLoader, "loader "+fn.Name()+" of "+p+" must return a struct or a pointer to a struct, not "+
+			types.TypeString(res, (*types.Package).Name))
+		return nil
+	}
+	info := b.infos[p]
+	if info == nil {
+		return l
+	}
+	props := info.Props
+	valid := true
+	for f := range st.Fields() {
+		if !f.Exported() {
+			continue
+		}
+		prop := propOf(props, f.Name())
+		switch {
+		case prop == "":
+			b.errors.Add(b.position(f.Pos()), checker.InvalidLoader, "field "+f.Name()+" returned by "+fn.Name()+" is not a prop of "+p)
+			valid = false
+		case !checker.AssignableTo(f.Type(), props[prop]):
+			b.errors.Add(b.position(f.Pos()), checker.InvalidLoader, "cannot use field "+f.Name()+" ("+
+				types.TypeString(f.Type(), (*types.Package).Name)+") returned by "+fn.Name()+" as prop "+prop+" of "+p+" ("+
+				types.TypeString(props[prop], (*types.Package).Name)+")")
+			valid = false
+		default:
+			l.Fields = append(l.Fields, &Field{Name: f.Name(), Prop: prop})
+		}
+	}
+	if !valid {
+		return nil
+	}
+	return l
+}
+
+// propOf returns the name of the prop of the struct field name, aka `user`
+// for `User`, or "".
+func propOf(props map[string]types.Type, name string) string {
+	for prop := range props {
+		if prop != "" && strings.ToUpper(prop[:1])+prop[1:] == name {
+			return prop
+		}
+	}
+	return ""
+}
+
+// signatures returns the valid signatures of a loader of a route of params.
+func signatures(params []*Param) string {
+	if len(params) == 0 {
+		return "func(*http.Request) (T, error)"
+	}
+	return "func(*http.Request) (T, error) or func(*http.Request" + paramList(params) + ") (T, error)"
+}
+
+// paramList returns the parameters of a loader taking params, aka
+// `, id int`.
+func paramList(params []*Param) string {
+	var b strings.Builder
+	for _, p := range params {
+		b.WriteString(", " + p.Name + " " + p.GoType().String())
+	}
+	return b.String()
+}
+
+func isRequest(t types.Type) bool {
+	ptr, ok := t.(*types.Pointer)
+	return ok && isHTTP(ptr.Elem(), "Request")
+}
+
+// isMiddleware reports whether t is `func(http.Handler) http.Handler`.
+func isMiddleware(t types.Type) bool {
+	sig, ok := t.(*types.Signature)
+	return ok && sig.Params().Len() == 1 && isHTTP(sig.Params().At(0).Type(), "Handler") &&
+		sig.Results().Len() == 1 && isHTTP(sig.Results().At(0).Type(), "Handler") && !sig.Variadic()
+}
+
+// isStatic reports whether t is `func() ([]map[string]string, error)`.
+func isStatic(t types.Type) bool {
+	sig, ok := t.(*types.Signature)
+	if !ok || sig.Params().Len() != 0 || sig.Results().Len() != 2 {
+		return false
+	}
+	want := types.NewSlice(types.NewMap(types.Typ[types.String], types.Typ[types.String]))
+	return types.Identical(sig.Results().At(0).Type(), want) &&
+		types.Identical(sig.Results().At(1).Type(), types.Universe.Lookup("error").Type())
+}
+
+// isHTTP reports whether t is the type of the given name of net/http.
+func isHTTP(t types.Type, name string) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "net/http" && named.Obj().Name() == name
+}
+
+// position returns the position of the Go source at pos relative to the root.
+func (b *builder) position(pos gotoken.Pos) token.Position {
+	p := b.loader.FileSet().Position(pos)
+	if rel, err := filepath.Rel(b.root, p.Filename); err == nil {
+		p.Filename = filepath.ToSlash(rel)
+	}
+	return token.Position{Filename: p.Filename, Line: p.Line, Column: p.Column}
+}
@@ -0,0 +1,167 @@
+package router
+
+import (
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/supaleon/vanilla/internal/checker"
+)
+
+func TestPattern(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{"pages/Index.html", "/"},
+		{"pages/blog/Index.html", "/blog"},
+		{"pages/blog/[slug]/Index.html", "/blog/:slug"},
+		{"pages/blog/[slug]/comments/Index.html", "/blog/:slug/comments"},
+		{"pages/docs/[...path]/Index.html", "/docs/*path"},
+		{"pages/users/[id=int]/Index.html", "/users/:id"},
+		{"pages/[key=uuid]/[s=slug]/Index.html", "/:key/:s"},
+		{"pages/blog/Card.html", ""},
+		{"pages/Layout.html", ""},
+		{"pages/[...path]/x/Index.html", ""},
+		{"pages/[a-b]/Index.html", ""},
+		{"pages/a[b]/Index.html", ""},
+		{"pages/[id=float]/Index.html", ""},
+		{"pages/[...path=int]/Index.html", ""},
+	}
+	for _, test := range tests {
+		if got := Pattern(test.path); got != test.want {
+			t.Errorf("Pattern(%q) = %q; want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestLoaderName(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{"pages/Index.html", "Load"},
+		{"pages/blog/Index.html", "Load"},
+		{"pages/blog/[slug]/Index.html", "LoadSlug"},
+		{"pages/blog/[slug]/comments/Index.html", "LoadSlugComments"},
+		{"pages/docs/[...path]/Index.html", "LoadPath"},
+		{"pages/[user_id]/Index.html", "LoadUserId"},
+		{"pages/users/[id=int]/Index.html", "LoadId"},
+	}
+	for _, test := range tests {
+		if got := LoaderName(test.path); got != test.want {
+			t.Errorf("LoaderName(%q) = %q; want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	paths := []string{
+		"pages/Index.html",
+		"pages/Card.html",
+		"pages/[a]/Index.html",
+		"pages/[b]/Index.html",
+		"pages/blog/Index.html",
+		"pages/blog/[slug]/Index.html",
+		"pages/blog/[slug]/edit/Index.html",
+		"pages/docs/[...path]/Index.html",
+		"pages/docs/[...path]/more/Index.html",
+	}
+	table, list := Build(t.TempDir(), paths, nil, checker.NewGoLoader())
+	want := []string{
+		"pages/[b]/Index.html: route /:b of pages/[b]/Index.html conflicts with route /:a of pages/[a]/Index.html [VR002]",
+		"pages/blog/Index.html: route /blog of pages/blog/Index.html conflicts with route /:a of pages/[a]/Index.html [VR002]",
+		"pages/blog/[slug]/Index.html: route /blog/:slug of pages/blog/[slug]/Index.html conflicts with route /:a of pages/[a]/Index.html [VR002]",
+		"pages/blog/[slug]/edit/Index.html: route /blog/:slug/edit of pages/blog/[slug]/edit/Index.html conflicts with route /:a of pages/[a]/Index.html [VR002]",
+		"pages/docs/[...path]/Index.html: route /docs/*path of pages/docs/[...path]/Index.html conflicts with route /:a of pages/[a]/Index.html [VR002]",
+		"pages/docs/[...path]/more/Index.html: catch-all segment [...path] must be the last segment of a route [VR001]",
+	}
+	checkErrors(t, list, want)
+	var got []string
+	for _, r := range table.Routes {
+		got = append(got, r.Pattern)
+	}
+	if strings.Join(got, " ") != "/ /:a" {
+		t.Errorf("got routes %v; want [/ /:a]", got)
+	}
+	if table.Lookup("pages/Card.html") != nil {
+		t.Error("got a route for a component which is not a page")
+	}
+}
+
+func TestRouteFiles(t *testing.T) {
+	paths := []string{
+		"pages/blog/Index.html",
+		"pages/blog/[slug]/Index.html",
+		"pages/docs/[...path]/Index.html",
+		"pages/tags/[tag=slug]/Index.html",
+		"pages/users/[id=int]/Index.html",
+	}
+	infos := map[string]*checker.Info{
+		"pages/blog/Index.html": {Props: map[string]types.Type{
+			"title": types.Typ[types.String],
+			"count": types.Typ[types.Int32],
+			"tags":  types.NewSlice(types.NewInterfaceType(nil, nil)),
+		}},
+		"pages/blog/[slug]/Index.html": {Props: map[string]types.Type{
+			"title": types.Typ[types.String],
+		}},
+		"pages/users/[id=int]/Index.html": {Props: map[string]types.Type{
+			"name": types.Typ[types.String],
+		}},
+	}
+	table, list := Build("testdata", paths, infos, checker.NewGoLoader())
+	want := []string{
+		"pages/blog/route.go:17:2: field Extra returned by LoadSlug is not a prop of pages/blog/[slug]/Index.html [VR003]",
+		"pages/docs/[...path]/route.go: cannot import pages/docs/[...path]/route.go from a dynamic segment, declare LoadPath in pages/docs/route.go [VR004]",
+		"pages/docs/route.go:5:6: loader LoadPath of pages/docs/[...path]/Index.html must have signature func(*http.Request) (T, error) or func(*http.Request, path string) (T, error) [VR003]",
+		"pages/docs/route.go:9:6: middleware MiddlewarePath of pages/docs/[...path] must have signature func(http.Handler) http.Handler [VR005]",
+		"pages/tags/route.go:7:6: loader LoadTag of pages/tags/[tag=slug]/Index.html must have signature func(*http.Request, tag string) (T, error) to take the parameters of /tags/:tag [VR003]",
+		"pages/users/route.go:13:6: static parameters StaticId of pages/users/[id=int]/Index.html must have signature func() ([]map[string]string, error) [VR006]",
+	}
+	checkErrors(t, list, want)
+
+	l := table.Lookup("pages/blog/Index.html").Loader
+	if l == nil || l.Func.Name() != "Load" || l.Ptr || len(l.Fields) != 2 {
+		t.Fatalf("got loader %+v; want Load with 2 fields", l)
+	}
+	if f := l.Fields[1]; f.Name != "Count" || f.Prop != "count" {
+		t.Errorf("got field %+v; want Count of prop count", f)
+	}
+	if l := table.Lookup("pages/users/[id=int]/Index.html").Loader; l == nil || !l.Params {
+		t.Errorf("got loader %+v; want LoadId taking the parameters", l)
+	}
+	if fn := table.Lookup("pages/blog/[slug]/Index.html").Static; fn == nil || fn.Name() != "StaticSlug" {
+		t.Errorf("got static parameters %v; want StaticSlug", fn)
+	}
+	if l := table.Lookup("pages/blog/[slug]/Index.html").Loader; l != nil {
+		t.Errorf("got loader %+v of invalid LoadSlug; want nil", l)
+	}
+
+	middleware := map[string]string{
+		"pages/blog/Index.html":           "pages.Middleware blog.Middleware",
+		"pages/blog/[slug]/Index.html":    "pages.Middleware blog.Middleware",
+		"pages/docs/[...path]/Index.html": "pages.Middleware",
+	}
+	for page, want := range middleware {
+		var got []string
+		for _, fn := range table.Lookup(page).Middleware {
+			got = append(got, fn.Pkg().Name()+"."+fn.Name())
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("%s: got middleware %v; want %s", page, got, want)
+		}
+	}
+}
+
+func checkErrors(t *testing.T, list checker.ErrorList, want []string) {
+	t.Helper()
+	if len(list) != len(want) {
+		t.Errorf("got %d errors; want %d", len(list), len(want))
+	}
+	for i, err := range list {
+		if i < len(want) && err.Error() != want[i] {
+			t.Errorf("error %d: got %q; want %q", i, err.Error(), want[i])
+		} else if i >= len(want) {
+			t.Errorf("unexpected error %q", err.Error())
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package blog
+
+import "net/http"
+
+type Page struct {
+	Title string
+	Count int32
+	draft bool
+}
+
+func Load(r *http.Request) (Page, error) {
+	return Page{Title: "Blog"}, nil
+}
+
+type Post struct {
+	Title string
+	Extra string
+}
+
+func LoadSlug(r *http.Request) (*Post, error) {
+	return &Post{}, nil
+}
+
+func Middleware(next http.Handler) http.Handler {
+	return next
+}
+
+func StaticSlug() ([]map[string]string, error) {
+	return []map[string]string{{"slug": "hello"}}, nil
+}
@@ -0,0 +1,11 @@
+package docs
+
+import "net/http"
+
+func LoadPath(r *http.Request) string {
+	return r.URL.Path
+}
+
+func MiddlewarePath(next http.HandlerFunc) http.Handler {
+	return next
+}
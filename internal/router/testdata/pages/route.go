@@ -0,0 +1,7 @@
+package pages
+
+import "net/http"
+
+func Middleware(next http.Handler) http.Handler {
+	return next
+}
@@ -0,0 +1,9 @@
+package tags
+
+import "net/http"
+
+type Tag struct{}
+
+func LoadTag(r *http.Request, tag int) (Tag, error) {
+	return Tag{}, nil
+}
@@ -0,0 +1,15 @@
+package users
+
+import "net/http"
+
+type User struct {
+	Name string
+}
+
+func LoadId(r *http.Request, id int) (User, error) {
+	return User{}, nil
+}
+
+func StaticId() ([]int, error) {
+	return nil, nil
+}
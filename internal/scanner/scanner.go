@@ -79,11 +79,8 @@ const (
 func (s *Scanner) scanRawText(tag []byte) (lit string) {
 	off := s.offset
 	l := len(tag)
-	for {
-		s.next()
-		if s.ch < 0 {
-			break
-		}
+	// NB: the raw text may be empty, e.g. `<script></script>`.
+	for s.ch >= 0 {
 		if s.ch == '<' {
 			p := s.peek()
 			if p == '/' {
@@ -93,6 +90,7 @@ func (s *Scanner) scanRawText(tag []byte) (lit string) {
 				}
 			}
 		}
+		s.next()
 	}
 	lit = string(s.src[off:s.offset])
 	s.rawTag = nil
@@ -758,7 +756,7 @@ func (s *Scanner) scanText() (tok token.Token, lit string) {
 			s.next()
 			continue
 		}
-		if s.advanceToTagOpen(true) {
+		if s.advanceToTagOpen(false) {
 			lit = string(s.src[off:s.offset])
 			break
 		}
@@ -766,15 +764,20 @@ func (s *Scanner) scanText() (tok token.Token, lit string) {
 	return
 }
 
+// advanceToTagOpen reports whether s.ch starts a markup token: a start tag,
+// an end tag, a comment or a processing instruction.
+// The scanner is switched back to stateText, which dispatches the markup token.
 func (s *Scanner) advanceToTagOpen(skipWhitespace bool) (ok bool) {
 	if skipWhitespace {
 		s.skipWhitespace()
 	}
-	// <div or </div
+	// <div, </div, <!-- or <?
 	if s.ch == '<' {
-		s.state = stateTagOpen
+		if r, _ := s.peekRune(); isUnicodeLetter(r) || r == '/' || r == '!' || r == '?' {
+			s.state = stateText
+			ok = true
+		}
 	}
-	//todo
 	s.attrValDelimOpen = 0
 	return
 }
@@ -1051,7 +1054,7 @@ func (s *Scanner) scanBasicExpr() (tok token.Token, lit string) {
 	//	s.next()
 	case isDecimal(ch) || ch == '.' && isDecimal(rune(s.peek())):
 		tok, lit = s.scanNumber()
-	case isUnicodeLetter(ch):
+	case isUnicodeLetter(ch) || ch == '_':
 		lit = s.scanIdentifier()
 		if len(lit) > 1 {
 			// keywords are longer than one letter - avoid lookup otherwise
@@ -1077,6 +1080,9 @@ func (s *Scanner) scanBasicExpr() (tok token.Token, lit string) {
 	case ch == ')':
 		tok = token.RPAREN
 		s.next()
+	case ch == ',':
+		tok = token.COMMA
+		s.next()
 	}
 	return
 }
@@ -1246,7 +1252,7 @@ func (s *Scanner) scanCodeBlock() (tok token.Token, lit string) {
 		s.next()
 	case isDecimal(ch) || ch == '.' && isDecimal(rune(s.peek())):
 		tok, lit = s.scanNumber()
-	case isUnicodeLetter(ch):
+	case isUnicodeLetter(ch) || ch == '_':
 		lit = s.scanIdentifier()
 		if len(lit) > 1 {
 			// keywords are longer than one letter - avoid lookup otherwise
@@ -1278,6 +1284,7 @@ func (s *Scanner) scanCodeBlock() (tok token.Token, lit string) {
 			}
 		}
 	case ch == '>':
+		s.next()
 		if s.ch == '=' {
 			s.next()
 			tok = token.GE
@@ -1300,6 +1307,22 @@ func (s *Scanner) scanCodeBlock() (tok token.Token, lit string) {
 	case ch == '!':
 		s.next()
 		tok = token.NOT
+		if s.ch == '=' {
+			s.next()
+			tok = token.NE
+		}
+	case ch == '&' || ch == '|':
+		s.next()
+		if s.ch == ch {
+			s.next()
+			tok = token.AND
+			if ch == '|' {
+				tok = token.OR
+			}
+			break
+		}
+		s.errorf(off, "unexpected %q, expected %q", ch, string([]rune{ch, ch}))
+		lit = string(ch)
 	case ch == '[':
 		tok = token.LBRACKET
 		s.next()
@@ -1332,12 +1355,22 @@ func (s *Scanner) scanCodeBlock() (tok token.Token, lit string) {
 		lit = string(s.src[off:s.offset])
 	}
 	//println("11111========", string(s.ch), tok.IsKeyword())
-	if tok.IsOperator() && !isWhitespace(s.ch) {
+	if isSpacedOperator(tok) && !isWhitespace(s.ch) {
 		s.error(s.offset, "operator must be surrounded by space")
 	}
 	return
 }
 
+// isSpacedOperator reports whether tok is a logical or comparison operator,
+// which must be surrounded by space to avoid conflicting with HTML tags, e.g. `1<a`.
+func isSpacedOperator(tok token.Token) bool {
+	switch tok {
+	case token.LT, token.GT, token.LE, token.GE, token.EQ, token.NE, token.AND, token.OR:
+		return true
+	}
+	return false
+}
+
 func (s *Scanner) Scan() (loc token.Loc, tok token.Token, lit string) {
 	tok = token.ILLEGAL
 	if s.offset == 0 && !s.debug {
@@ -1356,13 +1389,12 @@ func (s *Scanner) Scan() (loc token.Loc, tok token.Token, lit string) {
 	}
 
 scanAgain:
+	loc = s.file.Location(s.offset)
 	if s.ch == eof {
 		tok = token.EOF
 		return
 	}
 
-	loc = s.file.Location(s.offset)
-
 	switch stat := s.state; {
 	case stat == stateTagClose:
 		// `>`
@@ -859,6 +859,22 @@ func TestAttributes(t *testing.T) {
 			},
 			lits: []string{"disabled", "=", "{", "1", "}"},
 		},
+		{
+			src: `href={url(Post,id)}`,
+			toks: []token.Token{
+				token.ATTRName,
+				token.ATTRValSep,
+				token.LBRACE,
+				token.IDENT,
+				token.LPAREN,
+				token.IDENT,
+				token.COMMA,
+				token.IDENT,
+				token.RPAREN,
+				token.RBRACE,
+			},
+			lits: []string{"href", "=", "{", "url", "(", "Post", ",", "id", ")", "}"},
+		},
 		{
 			src: `disabled={abc }`,
 			toks: []token.Token{
@@ -1004,3 +1020,106 @@ func TestAttributes(t *testing.T) {
 		}
 	}
 }
+
+func TestCodeBlocks(t *testing.T) {
+	tests := []struct {
+		src  string
+		toks []token.Token
+		lits []string
+		err  string
+	}{
+		{
+			src:  `{if a && b}`,
+			toks: []token.Token{token.LBRACE, token.IF, token.IDENT, token.AND, token.IDENT, token.RBRACE},
+			lits: []string{"{", "if", "a", "&&", "b", "}"},
+		},
+		{
+			src:  `{if a || !b}`,
+			toks: []token.Token{token.LBRACE, token.IF, token.IDENT, token.OR, token.NOT, token.IDENT, token.RBRACE},
+			lits: []string{"{", "if", "a", "||", "!", "b", "}"},
+		},
+		{
+			src:  `{if a != 1}`,
+			toks: []token.Token{token.LBRACE, token.IF, token.IDENT, token.NE, token.INT, token.RBRACE},
+			lits: []string{"{", "if", "a", "!=", "1", "}"},
+		},
+		{
+			src:  `{if a >= 1}`,
+			toks: []token.Token{token.LBRACE, token.IF, token.IDENT, token.GE, token.INT, token.RBRACE},
+			lits: []string{"{", "if", "a", ">=", "1", "}"},
+		},
+		{
+			src:  `{if a >1}`,
+			toks: []token.Token{token.LBRACE, token.IF, token.IDENT, token.GT, token.INT, token.RBRACE},
+			lits: []string{"{", "if", "a", ">", "1", "}"},
+			err:  "operator must be surrounded by space",
+		},
+		{
+			src:  `{if a & b}`,
+			toks: []token.Token{token.LBRACE, token.IF, token.IDENT, token.ILLEGAL, token.IDENT, token.RBRACE},
+			lits: []string{"{", "if", "a", "&", "b", "}"},
+			err:  `unexpected '&', expected "&&"`,
+		},
+		{
+			src:  `{for _, tag in user.tags}`,
+			toks: []token.Token{token.LBRACE, token.FOR, token.IDENT, token.COMMA, token.IDENT, token.IN, token.IDENT, token.DOT, token.IDENT, token.RBRACE},
+			lits: []string{"{", "for", "_", ",", "tag", "in", "user", ".", "tags", "}"},
+		},
+		{
+			src:  `{a}{b}`,
+			toks: []token.Token{token.LBRACE, token.IDENT, token.RBRACE, token.LBRACE, token.IDENT, token.RBRACE},
+			lits: []string{"{", "a", "}", "{", "b", "}"},
+		},
+		{
+			src:  `{a} b {c}<i>`,
+			toks: []token.Token{token.LBRACE, token.IDENT, token.RBRACE, token.TEXT, token.LBRACE, token.IDENT, token.RBRACE, token.STARTTagOpen, token.TAGName, token.TAGClose},
+			lits: []string{"{", "a", "}", "b ", "{", "c", "}", "<", "i", ">"},
+		},
+	}
+
+	for i, test := range tests {
+		var errs []string
+		src := "<div>" + test.src
+		s := New(fset.AddFile("", fset.Base(), len(src)), []byte(src), func(_ token.Position, msg string) {
+			errs = append(errs, msg)
+		})
+
+		// Consume "<div>" - 3 tokens: STARTTagOpen, TAGName, TAGClose
+		s.Scan()
+		s.Scan()
+		s.Scan()
+
+		for j, wantTok := range test.toks {
+			_, tok, lit := s.Scan()
+			if tok != wantTok {
+				t.Errorf("[%d] %q: got token %s; want %s", i, test.src, tok, wantTok)
+				break
+			}
+
+			wantLit := test.lits[j]
+			if tok.IsOperator() {
+				lit = tok.String()
+			}
+
+			if lit != wantLit {
+				t.Errorf("[%d] %q: got literal %q for token %s; want %q", i, test.src, lit, tok, wantLit)
+			}
+		}
+
+		// Check for EOF
+		_, tok, _ := s.Scan()
+		if tok != token.EOF {
+			t.Errorf("[%d] %q: got %s; want EOF", i, test.src, tok)
+		}
+
+		if test.err != "" {
+			if len(errs) == 0 {
+				t.Errorf("[%d] %q: expected error %q, but got none", i, test.src, test.err)
+			} else if errs[0] != test.err {
+				t.Errorf("[%d] %q: expected error %q, but got %q", i, test.src, test.err, errs[0])
+			}
+		} else if len(errs) > 0 {
+			t.Errorf("[%d] %q: unexpected error: %q", i, test.src, errs[0])
+		}
+	}
+}
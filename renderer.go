@@ -1 +1,375 @@
 package vanilla
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Component is a compiled component. Generated code registers each component
+// of a project with [Register] when its package is initialized.
+type Component struct {
+	Name   string // Go name, aka "BlogIndex"
+	Path   string // source path relative to the project root, aka "pages/blog/Index.html"
+	Route  string // route of a page, aka "/blog"; empty for other components
+	Layout string // name of the layout wrapping a page or a layout; empty for none
+
+	// Scripts and Styles are the URLs of the ES modules and stylesheets
+	// bundled for a page or a layout, which Render links in the <head> of
+	// the page.
+	Scripts []string
+	Styles  []string
+
+	// Render writes the HTML of the component for props to w. props is nil
+	// for the default props, or a props struct of the component or a pointer
+	// to it; otherwise Render returns a *PropsError.
+	Render func(w *Writer, props any, ctx *Context) error
+
+	// Statics holds the static HTML runs written by Render; nil if Render
+	// writes none.
+	Statics *Statics
+
+	// Load returns the props of a page for the request r, as produced by the
+	// loader declared in its route.go file; nil if the page has none.
+	Load func(r *http.Request) (any, error)
+
+	// Static lists the parameters of the pages of a dynamic route to render
+	// for static generation, each holding their values by name, as declared
+	// in its route.go file; nil if there is none.
+	Static func() ([]map[string]string, error)
+
+	// Middleware wraps the handler of a page in the middleware declared in
+	// the route.go files of its directories; nil if there is none.
+	Middleware func(next http.Handler) http.Handler
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*Component)
+)
+
+// Register makes the component c available to the renderers by name. It
+// panics if c or its Render function is nil, or if a component of the same
+// name is already registered.
+func Register(c *Component) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if c == nil || c.Render == nil {
+		panic("vanilla: Register component is nil")
+	}
+	if _, dup := registry[c.Name]; dup {
+		panic("vanilla: Register called twice for component " + c.Name)
+	}
+	registry[c.Name] = c
+}
+
+// Components returns the registered components sorted by name.
+func Components() []*Component {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	list := make([]*Component, 0, len(registry))
+	for _, c := range registry {
+		list = append(list, c)
+	}
+	slices.SortFunc(list, func(a, b *Component) int { return strings.Compare(a.Name, b.Name) })
+	return list
+}
+
+// PropsError is returned when a component is rendered with props of another
+// type than its props struct.
+type PropsError struct {
+	Component string // name of the component
+	Props     any    // the props passed
+}
+
+func (e *PropsError) Error() string {
+	return fmt.Sprintf("vanilla: cannot render %s with props of type %T", e.Component, e.Props)
+}
+
+// RenderError reports a render that failed midway. File and Line locate the
+// failure in the template as mapped by the //line directives of generated
+// code, if known.
+type RenderError struct {
+	Component string // name of the rendered component
+	File      string // template file, aka "pages/blog/Card.html"
+	Line      int
+	Err       error
+}
+
+func (e *RenderError) Error() string {
+	if e.File == "" {
+		return "vanilla: render " + e.Component + ": " + e.Err.Error()
+	}
+	return fmt.Sprintf("vanilla: render %s: %s:%d: %v", e.Component, e.File, e.Line, e.Err)
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+// ErrNotFound is returned when no component matches a name or route.
+var ErrNotFound = errors.New("vanilla: component not found")
+
+// Renderer renders the components of a project. Output is buffered, so that
+// nothing is written when a render fails. A Renderer is safe for concurrent
+// use.
+type Renderer struct {
+	byName  map[string]*Component
+	byRoute map[string]*Component
+}
+
+// NewRenderer returns a Renderer of the given components, or of the
+// registered components if none is given.
+func NewRenderer(components ...*Component) *Renderer {
+	if len(components) == 0 {
+		components = Components()
+	}
+	r := &Renderer{
+		byName:  make(map[string]*Component, len(components)),
+		byRoute: make(map[string]*Component),
+	}
+	for _, c := range components {
+		r.byName[c.Name] = c
+		if c.Route != "" {
+			r.byRoute[c.Route] = c
+		}
+	}
+	return r
+}
+
+// pages returns the pages of r sorted by name.
+func (r *Renderer) pages() []*Component {
+	var list []*Component
+	for _, c := range r.byRoute {
+		list = append(list, c)
+	}
+	slices.SortFunc(list, func(a, b *Component) int { return strings.Compare(a.Name, b.Name) })
+	return list
+}
+
+// Lookup returns the component of the given name, or the page of the given
+// route if name starts with a slash, aka "/blog". It returns nil if there is
+// none.
+func (r *Renderer) Lookup(name string) *Component {
+	if strings.HasPrefix(name, "/") {
+		return r.byRoute[name]
+	}
+	return r.byName[name]
+}
+
+// Render writes the HTML of the page or component of the given name or route
+// for props to w, wrapped in its layouts. If w is an http.ResponseWriter, its
+// Content-Type is set to HTML unless already set.
+//
+// The Scripts and Styles of the page and of its layouts are linked before the
+// closing </head> tag of the output, or else before </body> or at its end.
+//
+// The output of the layouts preceding the slot of the page, aka their
+// <head>, is written to w and flushed if w is an http.Flusher as soon as the
+// page starts rendering, so that clients can load its resources meanwhile.
+// The rest is buffered, so that nothing more is written when the render
+// fails.
+func (r *Renderer) Render(w io.Writer, name string, props any) error {
+	_, err := r.render(w, name, props, true)
+	return err
+}
+
+// RenderFragment is like Render but writes the component alone, without its
+// layouts, aka for a partial HTML response. Nothing is written when the render
+// fails.
+func (r *Renderer) RenderFragment(w io.Writer, name string, props any) error {
+	_, err := r.render(w, name, props, false)
+	return err
+}
+
+var bufPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// render renders the component of the given name, and reports whether output
+// was written to w before an error occurred.
+func (r *Renderer) render(w io.Writer, name string, props any, withLayout bool) (streamed bool, err error) {
+	c := r.Lookup(name)
+	if c == nil {
+		return false, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	var layouts []*Component // innermost first
+	for l := c.Layout; withLayout && l != ""; l = layouts[len(layouts)-1].Layout {
+		layout := r.byName[l]
+		if layout == nil {
+			return false, fmt.Errorf("%w: layout %s of %s", ErrNotFound, l, c.Name)
+		}
+		if slices.Contains(layouts, layout) {
+			return false, fmt.Errorf("vanilla: layout %s of %s wraps itself", l, c.Name)
+		}
+		layouts = append(layouts, layout)
+	}
+
+	var tags []byte
+	if withLayout {
+		tags = assetTags(c, layouts)
+	}
+
+	buf := bufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufPool.Put(buf)
+	final := false
+	flush := func() error {
+		if rw, ok := w.(http.ResponseWriter); ok {
+			h := rw.Header()
+			if h.Get("Content-Type") == "" {
+				h.Set("Content-Type", "text/html; charset=utf-8")
+			}
+		}
+		streamed = true
+		out := buf.Bytes()
+		if tags != nil {
+			if i := tagsOffset(out, final); i >= 0 {
+				out = slices.Concat(out[:i], tags, out[i:])
+				tags = nil
+			}
+		}
+		_, err := w.Write(out)
+		buf.Reset()
+		if f, ok := w.(http.Flusher); ok && err == nil {
+			f.Flush()
+		}
+		return err
+	}
+	early := flush
+	if len(layouts) == 0 {
+		early = nil
+	}
+	if err := renderTo(buf, c, layouts, props, early); err != nil {
+		return streamed, err
+	}
+	final = true
+	return streamed, flush()
+}
+
+// assetTags returns the tags linking the Scripts and Styles of c and of its
+// layouts, outermost first, without duplicates; nil if there are none.
+func assetTags(c *Component, layouts []*Component) []byte {
+	var styles, scripts []string
+	for i := len(layouts) - 1; i >= -1; i-- {
+		l := c
+		if i >= 0 {
+			l = layouts[i]
+		}
+		for _, s := range l.Styles {
+			if !slices.Contains(styles, s) {
+				styles = append(styles, s)
+			}
+		}
+		for _, s := range l.Scripts {
+			if !slices.Contains(scripts, s) {
+				scripts = append(scripts, s)
+			}
+		}
+	}
+	var b []byte
+	for _, s := range styles {
+		b = fmt.Appendf(b, `<link rel="stylesheet" href="%s">`, html.EscapeString(s))
+	}
+	for _, s := range scripts {
+		b = fmt.Appendf(b, `<script type="module" src="%s"></script>`, html.EscapeString(s))
+	}
+	return b
+}
+
+// tagsOffset returns the offset of the closing </head> tag of out, where the
+// asset tags go. If out is the final output, it falls back to the closing
+// </body> tag or to the end of out; otherwise it returns -1.
+func tagsOffset(out []byte, final bool) int {
+	if i := bytes.Index(out, []byte("</head>")); i >= 0 || !final {
+		return i
+	}
+	if i := bytes.LastIndex(out, []byte("</body>")); i >= 0 {
+		return i
+	}
+	return len(out)
+}
+
+// renderTo renders c for props to buf, wrapped in layouts. flush, if not nil,
+// is called once c writes its first bytes in the slot of the layouts.
+// Panics of generated code are recovered as a *RenderError.
+func renderTo(buf *bytes.Buffer, c *Component, layouts []*Component, props any, flush func() error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			e, ok := v.(error)
+			if !ok {
+				e = fmt.Errorf("%v", v)
+			}
+			rerr := &RenderError{Component: c.Name, Err: e}
+			rerr.File, rerr.Line = templateCaller()
+			err = rerr
+		}
+	}()
+	render := func(w *Writer) error {
+		if flush == nil {
+			return c.Render(w, props, nil)
+		}
+		pw := NewWriter(&flushWriter{buf: buf, flush: flush})
+		if err := c.Render(pw, props, nil); err != nil {
+			return err
+		}
+		return pw.Err()
+	}
+	for _, l := range layouts {
+		inner := render
+		render = func(w *Writer) error {
+			var serr error
+			slot := func(w *Writer) {
+				serr = inner(w)
+			}
+			if err := l.Render(w, nil, (*Context)(nil).WithSlot(slot)); err != nil {
+				return err
+			}
+			return serr
+		}
+	}
+	w := NewWriter(buf)
+	if err = render(w); err == nil {
+		err = w.Err()
+	}
+	return err
+}
+
+// flushWriter writes to buf, calling flush once before the first write.
+type flushWriter struct {
+	buf   *bytes.Buffer
+	flush func() error
+}
+
+func (w *flushWriter) Write(p []byte) (int, error) {
+	if flush := w.flush; flush != nil {
+		w.flush = nil
+		if err := flush(); err != nil {
+			return 0, err
+		}
+	}
+	return w.buf.Write(p)
+}
+
+// templateCaller returns the innermost template position of the stack of a
+// panicking goroutine, or "" if none.
+func templateCaller() (file string, line int) {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		f, more := frames.Next()
+		if strings.HasSuffix(f.File, ".html") {
+			return f.File, f.Line
+		}
+		if !more {
+			return "", 0
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package vanilla
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type greetProps struct {
+	Name []string
+}
+
+// greetRender renders `<p>{name[1]}</p>` as generated from pages/Greet.html.
+func greetRender(w *Writer, props any, ctx *Context) error {
+	p, ok := props.(greetProps)
+	if !ok {
+		return &PropsError{Component: "Greet", Props: props}
+	}
+	w.WriteString("<p>")
+//line pages/Greet.html:5:8
+	w.WriteEscaped(p.Name[1])
+	w.WriteString("</p>")
+	return nil
+}
+
+func layoutRender(w *Writer, props any, ctx *Context) error {
+	w.WriteString("<body>")
+	RenderSlot(w, ctx, "")
+	w.WriteString("</body>")
+	return nil
+}
+
+func newTestRenderer() *Renderer {
+	return NewRenderer(
+		&Component{Name: "Layout", Path: "pages/Layout.html", Render: layoutRender},
+		&Component{Name: "Greet", Path: "pages/Greet.html", Route: "/greet", Layout: "Layout", Render: greetRender},
+	)
+}
+
+func TestRenderer(t *testing.T) {
+	r := newTestRenderer()
+	if c := r.Lookup("/greet"); c == nil || c.Name != "Greet" {
+		t.Errorf("Lookup(/greet) = %v; want Greet", c)
+	}
+	if c := r.Lookup("Greet"); c == nil || c.Route != "/greet" {
+		t.Errorf("Lookup(Greet) = %v; want Greet", c)
+	}
+	if c := r.Lookup("/"); c != nil {
+		t.Errorf("Lookup(/) = %v; want nil", c)
+	}
+
+	props := greetProps{Name: []string{"", "<Ann>"}}
+	rec := httptest.NewRecorder()
+	if err := r.Render(rec, "/greet", props); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rec.Body.String(), "<body><p>&lt;Ann&gt;</p></body>"; got != want {
+		t.Errorf("Render: got %q; want %q", got, want)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Errorf("got Content-Type %q", got)
+	}
+	if !rec.Flushed {
+		t.Error("got the head of the layout unflushed")
+	}
+
+	var b strings.Builder
+	if err := r.RenderFragment(&b, "Greet", props); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "<p>&lt;Ann&gt;</p>"; got != want {
+		t.Errorf("RenderFragment: got %q; want %q", got, want)
+	}
+}
+
+func TestRendererErrors(t *testing.T) {
+	r := newTestRenderer()
+	var b strings.Builder
+
+	err := r.Render(&b, "/missing", nil)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v; want ErrNotFound", err)
+	}
+
+	err = r.Render(&b, "Greet", 42)
+	var perr *PropsError
+	if !errors.As(err, &perr) || perr.Component != "Greet" {
+		t.Errorf("got %v; want *PropsError", err)
+	}
+
+	// index out of range midway
+	err = r.RenderFragment(&b, "Greet", greetProps{})
+	var rerr *RenderError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("got %v; want *RenderError", err)
+	}
+	if rerr.Component != "Greet" || !strings.HasSuffix(rerr.File, "pages/Greet.html") || rerr.Line != 5 {
+		t.Errorf("got %s %s:%d; want Greet pages/Greet.html:5", rerr.Component, rerr.File, rerr.Line)
+	}
+	if b.Len() != 0 {
+		t.Errorf("got output %q of failed renders; want none", b.String())
+	}
+
+	// the head of the layout is streamed once the page starts rendering
+	err = r.Render(&b, "Greet", greetProps{})
+	if !errors.As(err, &rerr) {
+		t.Fatalf("got %v; want *RenderError", err)
+	}
+	if got, want := b.String(), "<body>"; got != want {
+		t.Errorf("got output %q; want %q", got, want)
+	}
+}
+
+func TestNestedLayouts(t *testing.T) {
+	r := NewRenderer(
+		&Component{Name: "Layout", Path: "pages/Layout.html", Render: layoutRender},
+		&Component{Name: "BlogLayout", Path: "pages/blog/Layout.html", Layout: "Layout", Render: func(w *Writer, props any, ctx *Context) error {
+			w.WriteString("<main>")
+			RenderSlot(w, ctx, "")
+			w.WriteString("</main>")
+			return nil
+		}},
+		&Component{Name: "BlogIndex", Path: "pages/blog/Index.html", Route: "/blog", Layout: "BlogLayout", Render: greetRender},
+		&Component{Name: "Loop", Path: "pages/loop/Layout.html", Layout: "Loop", Render: layoutRender},
+		&Component{Name: "LoopIndex", Path: "pages/loop/Index.html", Route: "/loop", Layout: "Loop", Render: greetRender},
+	)
+	props := greetProps{Name: []string{"", "Ann"}}
+	var b strings.Builder
+	if err := r.Render(&b, "/blog", props); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "<body><main><p>Ann</p></main></body>"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	if err := r.Render(&b, "/loop", props); err == nil {
+		t.Error("got no error of a layout wrapping itself")
+	}
+}
+
+func TestAssetTags(t *testing.T) {
+	headRender := func(w *Writer, props any, ctx *Context) error {
+		w.WriteString("<html><head><title>t</title></head><body>")
+		RenderSlot(w, ctx, "")
+		w.WriteString("</body></html>")
+		return nil
+	}
+	r := NewRenderer(
+		&Component{Name: "Layout", Path: "pages/Layout.html", Render: headRender, Scripts: []string{"/assets/Layout-A.js"}, Styles: []string{"/assets/Layout-A.css"}},
+		&Component{Name: "Greet", Path: "pages/Greet.html", Route: "/greet", Layout: "Layout", Render: greetRender, Scripts: []string{"/assets/Greet-B.js", "/assets/Layout-A.js"}},
+		&Component{Name: "Bare", Path: "pages/Bare.html", Route: "/bare", Layout: "Body", Render: greetRender, Styles: []string{"/assets/Bare-C.css?v=1&x"}},
+		&Component{Name: "Body", Path: "pages/bare/Layout.html", Render: layoutRender},
+	)
+	props := greetProps{Name: []string{"", "Ann"}}
+	tests := []struct {
+		name, want string
+	}{
+		{"/greet", `<html><head><title>t</title><link rel="stylesheet" href="/assets/Layout-A.css">` +
+			`<script type="module" src="/assets/Layout-A.js"></script><script type="module" src="/assets/Greet-B.js"></script>` +
+			`</head><body><p>Ann</p></body></html>`},
+		{"/bare", `<body><p>Ann</p><link rel="stylesheet" href="/assets/Bare-C.css?v=1&amp;x"></body>`},
+		{"Layout", `<html><head><title>t</title><link rel="stylesheet" href="/assets/Layout-A.css">` +
+			`<script type="module" src="/assets/Layout-A.js"></script></head><body></body></html>`},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		if err := r.Render(rec, test.name, props); err != nil {
+			t.Fatal(err)
+		}
+		if got := rec.Body.String(); got != test.want {
+			t.Errorf("Render(%s):\ngot  %q\nwant %q", test.name, got, test.want)
+		}
+	}
+	var b strings.Builder
+	if err := r.RenderFragment(&b, "/greet", props); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "<p>Ann</p>"; got != want {
+		t.Errorf("RenderFragment: got %q; want %q", got, want)
+	}
+}
+
+func TestRenderSlot(t *testing.T) {
+	var b strings.Builder
+	w := NewWriter(&b)
+	text := func(s string) Slot {
+		return func(w *Writer) { w.WriteString(s) }
+	}
+	ctx := (*Context)(nil).WithSlots(map[string]Slot{"": text("body"), "footer": text("foot")}).With("theme", "dark")
+	for _, test := range []struct {
+		ctx  *Context
+		name string
+		ok   bool
+		want string
+	}{
+		{ctx, "", true, "body"},
+		{ctx, "footer", true, "foot"},
+		{ctx, "header", false, ""},
+		{ctx.WithSlot(nil), "", false, ""},
+		{ctx.WithSlot(text("page")), "footer", false, ""},
+		{nil, "", false, ""},
+	} {
+		b.Reset()
+		if ok := RenderSlot(w, test.ctx, test.name); ok != test.ok || b.String() != test.want {
+			t.Errorf("RenderSlot(%q) = %v, %q; want %v, %q", test.name, ok, b.String(), test.ok, test.want)
+		}
+	}
+	if v, ok := Lookup[string](ctx, "theme"); !ok || v != "dark" {
+		t.Errorf("Lookup(theme) = %q, %v; want dark", v, ok)
+	}
+}
@@ -1,3 +1,141 @@
 package vanilla
 
-import _ "github.com/julienschmidt/httprouter"
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// NewRouter returns a router serving the pages of r on their routes, for GET
+// and HEAD requests. Routes are derived from the pages/ tree and checked when
+// the project is built, so registering them does not fail. The handler of
+// each page is wrapped in its middleware once, here.
+//
+// Each request calls the loader of the page, if any, to produce its props and
+// renders the page wrapped in its layouts. A loader error wrapping
+// [ErrNotFound] is answered with 404 Not Found, other errors with 500 Internal
+// Server Error. A render failing once the head of the layouts is sent aborts
+// the response.
+//
+// Other paths are served from the registered static files, if any, as
+// described in [FileServer].
+func NewRouter(r *Renderer) *httprouter.Router {
+	router := httprouter.New()
+	if fsys := Files(); fsys != nil {
+		router.NotFound = FileServer(fsys)
+	}
+	for _, c := range r.pages() {
+		h := r.Handler(c.Name)
+		if c.Middleware != nil {
+			h = c.Middleware(h)
+		}
+		router.Handler(http.MethodGet, c.Route, h)
+		router.Handler(http.MethodHead, c.Route, h)
+	}
+	return router
+}
+
+// Handler returns a handler rendering the page of the given name or route, as
+// described in [NewRouter].
+func (r *Renderer) Handler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c := r.Lookup(name)
+		if c == nil {
+			http.NotFound(w, req)
+			return
+		}
+		var props any
+		var err error
+		var streamed bool
+		if c.Load != nil {
+			props, err = c.Load(req)
+		}
+		if err == nil {
+			streamed, err = r.render(w, c.Name, props, true)
+		}
+		switch {
+		case err == nil:
+		case streamed:
+			// the status is sent already, do not let the page look complete
+			log.Printf("vanilla: %s %s: %v", req.Method, req.URL.Path, err)
+			panic(http.ErrAbortHandler)
+		case errors.Is(err, ErrNotFound):
+			http.NotFound(w, req)
+		default:
+			log.Printf("vanilla: %s %s: %v", req.Method, req.URL.Path, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Param returns the value of the route parameter of the given name of a page
+// request, aka the slug of `pages/blog/[slug]/Index.html`, or "". The value
+// of a catch-all parameter has no leading slash.
+func Param(r *http.Request, name string) string {
+	return strings.TrimPrefix(httprouter.ParamsFromContext(r.Context()).ByName(name), "/")
+}
+
+// IntParam returns the value of the route parameter of the given name as an
+// int, and whether it is a decimal integer, aka the id of
+// `pages/blog/[id=int]/Index.html`.
+func IntParam(r *http.Request, name string) (int, bool) {
+	s := Param(r, name)
+	if strings.HasPrefix(s, "+") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil
+}
+
+// UUIDParam returns the value of the route parameter of the given name, and
+// whether it is a UUID in its canonical textual form, aka
+// `6ba7b810-9dad-11d1-80b4-00c04fd430c8`.
+func UUIDParam(r *http.Request, name string) (string, bool) {
+	s := Param(r, name)
+	if len(s) != 36 {
+		return s, false
+	}
+	for i := range len(s) {
+		switch c := s[i]; {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return s, false
+			}
+		case '0' <= c && c <= '9', 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+		default:
+			return s, false
+		}
+	}
+	return s, true
+}
+
+// SlugParam returns the value of the route parameter of the given name, and
+// whether it is made of lowercase letters and digits separated by single
+// dashes, aka `hello-world-2`.
+func SlugParam(r *http.Request, name string) (string, bool) {
+	s := Param(r, name)
+	if s == "" || s[0] == '-' || s[len(s)-1] == '-' || strings.Contains(s, "--") {
+		return s, false
+	}
+	for i := range len(s) {
+		if c := s[i]; c != '-' && !('a' <= c && c <= 'z') && !('0' <= c && c <= '9') {
+			return s, false
+		}
+	}
+	return s, true
+}
+
+// EscapePath escapes each segment of the slash-separated path p so that it
+// can be placed inside a URL path, aka the value of a catch-all parameter.
+func EscapePath(p string) string {
+	segs := strings.Split(p, "/")
+	for i, seg := range segs {
+		segs[i] = url.PathEscape(seg)
+	}
+	return strings.Join(segs, "/")
+}
@@ -0,0 +1,57 @@
+package vanilla
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// paramRequest returns a request whose route parameter x has value v.
+func paramRequest(v string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx := context.WithValue(r.Context(), httprouter.ParamsKey, httprouter.Params{{Key: "x", Value: v}})
+	return r.WithContext(ctx)
+}
+
+func TestTypedParams(t *testing.T) {
+	for _, test := range []struct {
+		value           string
+		int, uuid, slug bool
+	}{
+		{"42", true, false, true},
+		{"-7", true, false, false},
+		{"+7", false, false, false},
+		{"4x", false, false, true},
+		{"", false, false, false},
+		{"6ba7b810-9dad-11d1-80b4-00c04fd430c8", false, true, true},
+		{"6ba7b810-9dad-11d1-80b4-00c04fd430c", false, false, true},
+		{"6ba7b810x9dad-11d1-80b4-00c04fd430c8", false, false, true},
+		{"hello-world-2", false, false, true},
+		{"Hello", false, false, false},
+		{"a--b", false, false, false},
+		{"a-", false, false, false},
+	} {
+		r := paramRequest(test.value)
+		if _, ok := IntParam(r, "x"); ok != test.int {
+			t.Errorf("IntParam(%q) = %v; want %v", test.value, ok, test.int)
+		}
+		if _, ok := UUIDParam(r, "x"); ok != test.uuid {
+			t.Errorf("UUIDParam(%q) = %v; want %v", test.value, ok, test.uuid)
+		}
+		if _, ok := SlugParam(r, "x"); ok != test.slug {
+			t.Errorf("SlugParam(%q) = %v; want %v", test.value, ok, test.slug)
+		}
+	}
+	if n, _ := IntParam(paramRequest("42"), "x"); n != 42 {
+		t.Errorf("IntParam(42) = %d", n)
+	}
+}
+
+func TestEscapePath(t *testing.T) {
+	if got, want := EscapePath("a b/c?d/"), "a%20b/c%3Fd/"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
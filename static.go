@@ -0,0 +1,147 @@
+package vanilla
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// StaticPage is a page rendered by [WriteStatic].
+type StaticPage struct {
+	Route string `json:"route"`           // route of the page, aka "/blog/:slug"
+	Path  string `json:"path,omitempty"`  // URL path, aka "/blog/hello"; empty if unknown
+	File  string `json:"file,omitempty"`  // slash-separated file written relative to the output directory, aka "blog/hello/index.html"
+	Error string `json:"error,omitempty"` // reason of a failure
+}
+
+// StaticReport lists the pages rendered by [WriteStatic].
+type StaticReport struct {
+	Pages    []*StaticPage `json:"pages"`
+	Failures []*StaticPage `json:"failures,omitempty"`
+}
+
+// WriteStatic renders every page of r into an index.html file of dir named
+// after its URL path, aka blog/hello/index.html for /blog/hello. The pages of
+// a dynamic route are rendered for each parameter set listed by the Static
+// function of their component.
+//
+// Pages are requested through [NewRouter], so that their loaders and
+// middleware run; responses other than 200 OK are failures. Failures of
+// pages are listed in the report, the returned error is about dir only.
+func WriteStatic(r *Renderer, dir string) (*StaticReport, error) {
+	router := NewRouter(r)
+	report := &StaticReport{}
+	for _, c := range r.pages() {
+		sets := []map[string]string{nil}
+		if strings.ContainsAny(c.Route, ":*") {
+			var err error
+			if c.Static == nil {
+				err = errors.New("dynamic route with no static parameters")
+			} else {
+				sets, err = c.Static()
+			}
+			if err != nil {
+				report.Failures = append(report.Failures, &StaticPage{Route: c.Route, Error: err.Error()})
+				continue
+			}
+		}
+		for _, params := range sets {
+			page := &StaticPage{Route: c.Route}
+			html, err := renderPage(router, page, params)
+			if err != nil {
+				page.Error = err.Error()
+				report.Failures = append(report.Failures, page)
+				continue
+			}
+			name := filepath.Join(dir, filepath.FromSlash(page.File))
+			if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+				return report, err
+			}
+			if err := os.WriteFile(name, html, 0o644); err != nil {
+				return report, err
+			}
+			report.Pages = append(report.Pages, page)
+		}
+	}
+	return report, nil
+}
+
+// renderPage requests the page of the given parameters from h, sets its path
+// and file, and returns its HTML.
+func renderPage(h http.Handler, page *StaticPage, params map[string]string) (html []byte, err error) {
+	page.Path, err = expandRoute(page.Route, params)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, page.Path, nil)
+	if err != nil {
+		return nil, err
+	}
+	w := &staticWriter{header: make(http.Header), code: http.StatusOK}
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("render aborted: %v", v)
+		}
+	}()
+	h.ServeHTTP(w, req)
+	if w.code != http.StatusOK {
+		return nil, fmt.Errorf("status %d %s", w.code, http.StatusText(w.code))
+	}
+	page.File = strings.TrimPrefix(path.Join(req.URL.Path, "index.html"), "/")
+	return w.buf.Bytes(), nil
+}
+
+// expandRoute returns the URL path of the httprouter pattern route for the
+// values of its parameters, aka "/blog/hello" for "/blog/:slug".
+func expandRoute(route string, params map[string]string) (string, error) {
+	segs := strings.Split(route, "/")
+	for i, seg := range segs {
+		if seg == "" || (seg[0] != ':' && seg[0] != '*') {
+			continue
+		}
+		v, ok := params[seg[1:]]
+		if !ok || v == "" {
+			return "", fmt.Errorf("missing parameter %s", seg[1:])
+		}
+		if seg[0] == '*' {
+			segs[i] = EscapePath(v)
+		} else {
+			segs[i] = url.PathEscape(v)
+		}
+	}
+	p := strings.Join(segs, "/")
+	if path.Clean(p) != p {
+		return "", fmt.Errorf("invalid path %s", p)
+	}
+	return p, nil
+}
+
+// staticWriter is the http.ResponseWriter of the pages rendered by
+// [WriteStatic].
+type staticWriter struct {
+	header http.Header
+	code   int
+	wrote  bool
+	buf    bytes.Buffer
+}
+
+func (w *staticWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *staticWriter) WriteHeader(code int) {
+	if !w.wrote {
+		w.code, w.wrote = code, true
+	}
+}
+
+func (w *staticWriter) Write(p []byte) (int, error) {
+	w.wrote = true
+	return w.buf.Write(p)
+}
@@ -0,0 +1,72 @@
+package vanilla
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteStatic(t *testing.T) {
+	name := func(w *Writer, props any, ctx *Context) error {
+		w.WriteString("<p>")
+		w.WriteEscaped(props.(string))
+		w.WriteString("</p>")
+		return nil
+	}
+	r := NewRenderer(
+		&Component{Name: "Layout", Path: "pages/Layout.html", Render: layoutRender},
+		&Component{Name: "Index", Path: "pages/Index.html", Route: "/", Layout: "Layout", Render: name,
+			Load: func(*http.Request) (any, error) { return "home", nil }},
+		&Component{Name: "NameIndex", Path: "pages/[name]/Index.html", Route: "/u/:name", Layout: "Layout", Render: name,
+			Load: func(r *http.Request) (any, error) {
+				if Param(r, "name") == "nobody" {
+					return nil, ErrNotFound
+				}
+				return Param(r, "name"), nil
+			},
+			Static: func() ([]map[string]string, error) {
+				return []map[string]string{{"name": "ann"}, {"name": "a b"}, {"name": "nobody"}, {}}, nil
+			}},
+		&Component{Name: "DocsPathIndex", Path: "pages/docs/[...path]/Index.html", Route: "/docs/*path", Render: name,
+			Static: func() ([]map[string]string, error) { return nil, errors.New("no docs") }},
+		&Component{Name: "TagIndex", Path: "pages/tag/[tag]/Index.html", Route: "/tag/:tag", Render: name},
+	)
+	dir := t.TempDir()
+	report, err := WriteStatic(r, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, p := range report.Pages {
+		got = append(got, p.Path+" "+p.File)
+	}
+	want := []string{"/ index.html", "/u/ann u/ann/index.html", "/u/a%20b u/a b/index.html"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got pages %q; want %q", got, want)
+	}
+	got = nil
+	for _, p := range report.Failures {
+		got = append(got, p.Route+" "+p.Path+": "+p.Error)
+	}
+	want = []string{
+		"/docs/*path : no docs",
+		"/u/:name /u/nobody: status 404 Not Found",
+		"/u/:name : missing parameter name",
+		"/tag/:tag : dynamic route with no static parameters",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got failures %q; want %q", got, want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "u", "a b", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "<body><p>a b</p></body>"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
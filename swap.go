@@ -0,0 +1,95 @@
+package vanilla
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+)
+
+// Statics holds the static HTML runs of a component, which its Render
+// function writes between the values of its code blocks. They are replaced
+// in a running binary by the development server when a change of the
+// component changes them only, see [SwapEnv].
+//
+// Statics is used by generated code and is not meant to be used directly.
+type Statics struct {
+	runs atomic.Pointer[[][]byte]
+}
+
+// NewStatics returns the Statics of the given runs.
+func NewStatics(runs ...string) *Statics {
+	s := &Statics{}
+	s.store(runs)
+	return s
+}
+
+// Runs returns the current runs. A render loads them once, so that it does
+// not mix runs of before and after a swap.
+func (s *Statics) Runs() [][]byte {
+	return *s.runs.Load()
+}
+
+func (s *Statics) store(runs []string) {
+	b := make([][]byte, len(runs))
+	for i, r := range runs {
+		b[i] = []byte(r)
+	}
+	s.runs.Store(&b)
+}
+
+// SwapEnv is the environment variable by which the development server asks
+// a binary of the project to listen on a local address, aka 127.0.0.1:4001,
+// for the static HTML runs of its components. A request posts the new runs
+// as a JSON object of the arrays of runs by component name; the runs of a
+// component are swapped only if their number is unchanged, which the server
+// ensures by posting them only when the rest of the generated code of the
+// component is unchanged. The listener is started when the package is
+// initialized, so that it is ready once the binary listens on $PORT.
+const SwapEnv = "VANILLA_SWAP_ADDR"
+
+func init() {
+	addr := os.Getenv(SwapEnv)
+	if addr == "" {
+		return
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "vanilla: %s: %v\n", SwapEnv, err)
+		return
+	}
+	go http.Serve(l, http.HandlerFunc(serveSwap))
+}
+
+// serveSwap swaps the runs posted by r, see [SwapEnv], all or none of them.
+func serveSwap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	var swaps map[string][]string
+	if err := json.NewDecoder(r.Body).Decode(&swaps); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for name, runs := range swaps {
+		c := registry[name]
+		if c == nil || c.Statics == nil {
+			http.Error(w, "no static runs of component "+name, http.StatusNotFound)
+			return
+		}
+		if n := len(c.Statics.Runs()); n != len(runs) {
+			http.Error(w, fmt.Sprintf("component %s has %d static runs, not %d", name, n, len(runs)), http.StatusConflict)
+			return
+		}
+	}
+	for name, runs := range swaps {
+		registry[name].Statics.store(runs)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
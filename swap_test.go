@@ -0,0 +1,53 @@
+package vanilla
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeSwap(t *testing.T) {
+	statics := NewStatics("<p>", "</p>")
+	Register(&Component{Name: "SwapTest", Render: func(w *Writer, props any, ctx *Context) error {
+		static := statics.Runs()
+		w.WriteRaw(static[0])
+		w.WriteString("x")
+		w.WriteRaw(static[1])
+		return nil
+	}, Statics: statics})
+	Register(&Component{Name: "SwapTestPlain", Render: layoutRender})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "SwapTest")
+		delete(registry, "SwapTestPlain")
+		registryMu.Unlock()
+	})
+
+	tests := []struct {
+		method, body string
+		code         int
+		want         string
+	}{
+		{"GET", "", http.StatusMethodNotAllowed, "<p>x</p>"},
+		{"POST", `{`, http.StatusBadRequest, "<p>x</p>"},
+		{"POST", `{"SwapTest": ["<b>"]}`, http.StatusConflict, "<p>x</p>"},
+		{"POST", `{"SwapTest": ["<b>", "</b>"], "SwapTestPlain": []}`, http.StatusNotFound, "<p>x</p>"},
+		{"POST", `{"SwapTest": ["<b>", "</b>"], "Missing": []}`, http.StatusNotFound, "<p>x</p>"},
+		{"POST", `{"SwapTest": ["<b>", "</b>"]}`, http.StatusNoContent, "<b>x</b>"},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		serveSwap(rec, httptest.NewRequest(test.method, "/", strings.NewReader(test.body)))
+		if rec.Code != test.code {
+			t.Errorf("%s %s: got %d, want %d", test.method, test.body, rec.Code, test.code)
+		}
+		var b strings.Builder
+		if err := NewRenderer().RenderFragment(&b, "SwapTest", nil); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.String(); got != test.want {
+			t.Errorf("%s %s: got render %q, want %q", test.method, test.body, got, test.want)
+		}
+	}
+}
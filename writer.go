@@ -0,0 +1,176 @@
+package vanilla
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+)
+
+// Writer is the output of generated Render methods. It latches the first
+// write error, so that generated code need not check every write.
+//
+// Writer is used by generated code and is not meant to be used directly.
+type Writer struct {
+	w   io.Writer
+	err error
+	buf []byte // scratch buffer for number formatting
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	if vw, ok := w.(*Writer); ok {
+		return vw
+	}
+	return &Writer{w: w, buf: make([]byte, 0, 32)}
+}
+
+// Err returns the first error that occurred while writing, if any.
+func (w *Writer) Err() error {
+	return w.err
+}
+
+// Write implements io.Writer.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	var n int
+	n, w.err = w.w.Write(p)
+	return n, w.err
+}
+
+// WriteRaw writes the bytes p as is.
+func (w *Writer) WriteRaw(p []byte) {
+	if w.err == nil {
+		_, w.err = w.w.Write(p)
+	}
+}
+
+// WriteString writes s as is.
+func (w *Writer) WriteString(s string) {
+	if w.err == nil {
+		_, w.err = io.WriteString(w.w, s)
+	}
+}
+
+// WriteEscaped writes s escaped for HTML text and attribute values.
+func (w *Writer) WriteEscaped(s string) {
+	w.WriteString(html.EscapeString(s))
+}
+
+// WriteBool writes "true" or "false".
+func (w *Writer) WriteBool(b bool) {
+	w.WriteRaw(strconv.AppendBool(w.buf[:0], b))
+}
+
+// WriteInt writes the decimal form of i.
+func (w *Writer) WriteInt(i int64) {
+	w.WriteRaw(strconv.AppendInt(w.buf[:0], i, 10))
+}
+
+// WriteUint writes the decimal form of u.
+func (w *Writer) WriteUint(u uint64) {
+	w.WriteRaw(strconv.AppendUint(w.buf[:0], u, 10))
+}
+
+// WriteFloat writes the shortest form of f of the given bit size.
+func (w *Writer) WriteFloat(f float64, bitSize int) {
+	w.WriteRaw(strconv.AppendFloat(w.buf[:0], f, 'g', -1, bitSize))
+}
+
+// WriteAny writes the value v of a JS literal type, aka an element of a
+// `prop([])` array, escaped for HTML.
+func (w *Writer) WriteAny(v any) {
+	switch v := v.(type) {
+	case nil:
+	case string:
+		w.WriteEscaped(v)
+	case bool:
+		w.WriteBool(v)
+	case int:
+		w.WriteInt(int64(v))
+	case int32:
+		w.WriteInt(int64(v))
+	case int64:
+		w.WriteInt(v)
+	case float32:
+		w.WriteFloat(float64(v), 32)
+	case float64:
+		w.WriteFloat(v, 64)
+	case fmt.Stringer:
+		w.WriteEscaped(v.String())
+	default:
+		w.WriteEscaped(fmt.Sprint(v))
+	}
+}
+
+// EscapeString escapes s for HTML; it implements the `escape` built-in
+// function of templates.
+func EscapeString(s string) string {
+	return html.EscapeString(s)
+}
+
+// Context holds the values of the enclosing <context> elements of a
+// component being rendered, aka `<context theme={theme}>`, and the content
+// of its slots.
+type Context struct {
+	outer *Context
+	name  string
+	value any
+	slots map[string]Slot
+	slot  bool // set if the context holds the slot content
+}
+
+// Slot is the content rendered in place of a <slot> of a component, aka the
+// page rendered by its layout.
+type Slot func(w *Writer)
+
+// With returns a context holding the value of the given name on top of c,
+// which may be nil.
+func (c *Context) With(name string, value any) *Context {
+	return &Context{outer: c, name: name, value: value}
+}
+
+// WithSlot returns a context holding the content s of the default slot on top
+// of c, which may be nil. A nil s hides the slot content of c.
+func (c *Context) WithSlot(s Slot) *Context {
+	if s == nil {
+		return c.WithSlots(nil)
+	}
+	return c.WithSlots(map[string]Slot{"": s})
+}
+
+// WithSlots returns a context holding the slot content by slot name on top of
+// c, which may be nil; "" names the default slot. The slot content of c is
+// hidden.
+func (c *Context) WithSlots(slots map[string]Slot) *Context {
+	return &Context{outer: c, slots: slots, slot: true}
+}
+
+// RenderSlot writes the content of the slot of the given name of the
+// innermost slot content of c, and reports whether there is one. Otherwise,
+// generated code renders the fallback content of the <slot>.
+func RenderSlot(w *Writer, c *Context, name string) bool {
+	for ; c != nil; c = c.outer {
+		if c.slot {
+			if s := c.slots[name]; s != nil {
+				s(w)
+				return true
+			}
+			return false
+		}
+	}
+	return false
+}
+
+// Lookup returns the innermost value of the given name of c if it is of type T.
+func Lookup[T any](c *Context, name string) (v T, ok bool) {
+	for ; c != nil; c = c.outer {
+		if !c.slot && c.name == name {
+			v, ok = c.value.(T)
+			return
+		}
+	}
+	return
+}